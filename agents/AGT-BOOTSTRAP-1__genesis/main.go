@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -89,11 +90,38 @@ func (b *BootstrapAgent) saveSequences() error {
 		return err
 	}
 	
-	return os.WriteFile(sequencesFile, data, 0644)
+	// AGT-NAMING-2 reads the file at any time; it must never see a partial write
+	return writeFileAtomic(sequencesFile, data)
+}
+
+// writeFileAtomic replaces path with contents via a temp file in the same directory
+func writeFileAtomic(path string, contents []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 // getNextSequence - Get next sequence number for domain
 func (b *BootstrapAgent) getNextSequence(domain string) (int, error) {
+	// AGT-NAMING-2 snapshots its sequences into the same file; reload so they aren't clobbered
+	if err := b.loadSequences(); err != nil {
+		return 0, fmt.Errorf("failed to load sequences: %v", err)
+	}
+	
 	key := fmt.Sprintf("AGT-%s", domain)
 	nextSeq := b.Sequences[key] + 1
 	b.Sequences[key] = nextSeq
@@ -163,17 +191,22 @@ func (b *BootstrapAgent) CreateAgent(spec AgentSpec) (*AgentMetadata, error) {
 		return nil, fmt.Errorf("failed to write spec.yaml: %v", err)
 	}
 	
-	// Generate basic agent code
-	agentCode := generateAgentCode(agentSpec)
-	codeFile := filepath.Join(agentDir, "main.go")
-	if err := os.WriteFile(codeFile, []byte(agentCode), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write main.go: %v", err)
+	// Generate agent config, code and module on the shared agent runtime
+	files := map[string]string{
+		"agent.yaml": generateAgentConfig(agentSpec),
+		"main.go":    generateAgentCode(agentSpec),
+		"go.mod":     generateGoMod(slug),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(agentDir, name), []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", name, err)
+		}
 	}
 	
-	// Initialize Go module
-	modInit := fmt.Sprintf("cd %s && go mod init centerfire/%s", agentDir, slug)
-	if err := runCommand(modInit); err != nil {
-		log.Printf("Warning: Failed to initialize Go module: %v", err)
+	// Only report agents that compile; a broken skeleton is removed rather than left behind
+	if err := verifyAgent(agentDir); err != nil {
+		os.RemoveAll(agentDir)
+		return nil, fmt.Errorf("generated %s does not build: %v", slug, err)
 	}
 	
 	fmt.Printf("Created agent: %s\n", slug)
@@ -196,107 +229,114 @@ func getAgentName(spec AgentSpec) string {
 	return fmt.Sprintf("%s Agent", spec.Domain)
 }
 
-// runCommand - Execute shell command (simplified)
-func runCommand(cmd string) error {
-	// This is a simplified version - in production would use proper exec
-	log.Printf("Would run: %s", cmd)
+// verifyAgent - Resolve dependencies, then vet and build the generated agent
+func verifyAgent(dir string) error {
+	steps := [][]string{
+		{"mod", "tidy"},
+		{"vet", "./..."},
+		{"build", "-o", os.DevNull, "./..."},
+	}
+	for _, args := range steps {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("go %s: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+		}
+	}
 	return nil
 }
 
-// generateAgentCode - Generate basic Go agent implementation
+// generateAgentConfig - Generate agent.yaml for the shared agent runtime
+func generateAgentConfig(spec map[string]interface{}) string {
+	domain := strings.ToLower(spec["domain"].(string))
+	agentID := spec["id"].(string)
+	
+	config := map[string]interface{}{
+		"agent_id":      agentID,
+		"cid":           spec["cid"],
+		"friendly_name": spec["name"],
+		"namespace":     fmt.Sprintf("centerfire.agents.%s", domain),
+		"language":      "go",
+		"agent_type":    "persistent",
+		"capabilities":  spec["capabilities"],
+		"communication": map[string]interface{}{
+			"redis_channels": []string{
+				fmt.Sprintf("agent.%s.request", domain),
+				fmt.Sprintf("agent.%s.response", domain),
+			},
+		},
+		"monitoring": map[string]interface{}{
+			"register_with_monitor": true,
+			"health_check_method":   "file",
+			"health_check_path":     fmt.Sprintf("/tmp/%s.health", agentID),
+		},
+	}
+	
+	data, _ := yaml.Marshal(config)
+	return "# Generated by AGT-BOOTSTRAP-1\n" + string(data)
+}
+
+// generateGoMod - Generate go.mod pointing at the shared runtime in this repository
+func generateGoMod(slug string) string {
+	return fmt.Sprintf(`module %s
+
+go 1.21
+
+require centerfire/shared/agent v0.0.0
+
+replace (
+	centerfire/shared/agent => ../../shared/agent
+	centerfire/shared/config => ../../shared/config
+	centerfire/shared/messages => ../../shared/messages
+)
+`, strings.ToLower(slug))
+}
+
+// generateAgentCode - Generate a Go agent on the shared runtime, with a stub handler per capability
 func generateAgentCode(spec map[string]interface{}) string {
 	domain := spec["domain"].(string)
 	agentID := spec["id"].(string)
-	cid := spec["cid"].(string)
+	capabilities, _ := spec["capabilities"].([]string)
+	
+	var handlers strings.Builder
+	for _, capability := range capabilities {
+		fmt.Fprintf(&handlers, "\ta.Handle(%q, notImplemented(%q))\n", capability, capability)
+	}
 	
 	return fmt.Sprintf(`package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"os/signal"
-	"strings"
-	"syscall"
-)
 
-// %sAgent - Generated agent for %s domain
-type %sAgent struct {
-	AgentID        string
-	CID           string
-	RequestChannel string
-	ResponseChannel string
-}
+	"centerfire/shared/agent"
+)
 
-// NewAgent - Create new %s agent
-func NewAgent() *%sAgent {
-	return &%sAgent{
-		AgentID:         "%s",
-		CID:            "%s",
-		RequestChannel:  "agent.%s.request",
-		ResponseChannel: "agent.%s.response",
+// %s - Generated agent for the %s domain. The shared agent runtime provides manager
+// registration, heartbeats, PID/health files, request dispatch and graceful shutdown.
+func main() {
+	// Flags override any setting; --print-config shows where each value came from
+	config, err := agent.LoadConfigArgs(os.Args[1:], "agent.yaml")
+	if err != nil {
+		log.Fatalf("Failed to load config: %%v", err)
 	}
-}
 
-// Start - Start listening for requests
-func (a *%sAgent) Start() {
-	fmt.Printf("%%s starting...\n", a.AgentID)
-	fmt.Printf("Listening on: %%s\n", a.RequestChannel)
-	
-	// Set up graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	
-	// Simulate agent running
-	for {
-		select {
-		case <-sigChan:
-			fmt.Printf("\n%%s shutting down...\n", a.AgentID)
-			return
-		default:
-			// Agent work would go here
-			// For now, just indicate it's running
-		}
+	a := agent.New(config)
+%s
+	if err := a.Run(); err != nil {
+		log.Fatalf("Agent failed: %%v", err)
 	}
 }
 
-// HandleRequest - Handle incoming request
-func (a *%sAgent) HandleRequest(request map[string]interface{}) map[string]interface{} {
-	action, ok := request["action"].(string)
-	if !ok {
-		return map[string]interface{}{
-			"error": "No action specified",
-		}
+// notImplemented answers a declared capability until its handler is written
+func notImplemented(action string) agent.HandlerFunc {
+	return func(req *agent.Request) (map[string]interface{}, error) {
+		return nil, fmt.Errorf("%%s is not implemented yet", action)
 	}
-	
-	// Route to appropriate handler
-	switch action {
-	default:
-		return map[string]interface{}{
-			"error": fmt.Sprintf("Unknown action: %%s", action),
-		}
-	}
-}
-
-func main() {
-	agent := NewAgent()
-	agent.Start()
 }
-`, 
-		strings.Title(strings.ToLower(domain)), // NamingAgent
-		domain,                                 // NAMING domain
-		strings.Title(strings.ToLower(domain)), // NamingAgent
-		domain,                                 // NAMING agent
-		strings.Title(strings.ToLower(domain)), // NamingAgent  
-		strings.Title(strings.ToLower(domain)), // NamingAgent
-		agentID,                               // AGT-NAMING-1
-		cid,                                   // cid:...
-		strings.ToLower(domain),               // naming.request
-		strings.ToLower(domain),               // naming.response
-		strings.Title(strings.ToLower(domain)), // NamingAgent
-		strings.Title(strings.ToLower(domain)), // NamingAgent
-	)
+`, agentID, domain, handlers.String())
 }
 
 // BootstrapCoreAgents - Create the core agent set needed for the system
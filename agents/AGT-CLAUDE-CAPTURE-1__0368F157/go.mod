@@ -2,9 +2,15 @@ module agt-claude-capture-1
 
 go 1.21
 
-require github.com/redis/go-redis/v9 v9.0.5
+require (
+	centerfire/shared/config v0.0.0
+	github.com/redis/go-redis/v9 v9.0.5
+)
 
 require (
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+replace centerfire/shared/config => ../../shared/config
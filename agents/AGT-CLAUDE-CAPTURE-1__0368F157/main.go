@@ -12,6 +12,7 @@ import (
 	"syscall"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -46,12 +47,12 @@ type ConversationEvent struct {
 }
 
 // NewAgent - Create new Claude Code capture agent
-func NewAgent() *ClaudeCaptureAgent {
+func NewAgent(endpoints *config.Config) *ClaudeCaptureAgent {
 	ctx, cancel := context.WithCancel(context.Background())
 	
-	// Connect to Redis container on port 6380
+	// Connect to Redis container (6380 unless configured otherwise)
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
@@ -422,6 +423,16 @@ func (a *ClaudeCaptureAgent) generateSessionID() string {
 }
 
 func main() {
-	agent := NewAgent()
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	agent := NewAgent(endpoints)
 	agent.Start()
 }
\ No newline at end of file
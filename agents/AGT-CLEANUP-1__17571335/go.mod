@@ -2,9 +2,15 @@ module centerfire/agents/cleanup
 
 go 1.25.1
 
-require github.com/redis/go-redis/v9 v9.13.0
+require (
+	centerfire/shared/config v0.0.0
+	github.com/redis/go-redis/v9 v9.13.0
+)
 
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-)
\ No newline at end of file
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
+
+replace centerfire/shared/config => ../../shared/config
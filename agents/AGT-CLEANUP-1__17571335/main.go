@@ -12,6 +12,7 @@ import (
 	"syscall"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -28,10 +29,10 @@ type CleanupAgent struct {
 }
 
 // NewAgent - Create new CLEANUP agent
-func NewAgent() *CleanupAgent {
-	// Connect to Redis container on port 6380
+func NewAgent(endpoints *config.Config) *CleanupAgent {
+	// Connect to Redis container (6380 unless configured otherwise)
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
@@ -42,8 +43,8 @@ func NewAgent() *CleanupAgent {
 		RequestChannel:  "agent.cleanup.request",
 		ResponseChannel: "agent.cleanup.response",
 		RedisClient:    rdb,
-		WeaviateURL:    "http://localhost:8080",
-		Neo4jURL:       "bolt://localhost:7687",
+		WeaviateURL:    endpoints.Get(config.WeaviateURL),
+		Neo4jURL:       endpoints.Get(config.Neo4jURI),
 		ctx:            context.Background(),
 	}
 }
@@ -270,16 +271,65 @@ func (a *CleanupAgent) DirectCleanup() error {
 	return nil
 }
 
+// RunTask - Handle the single request the manager queued for this ephemeral instance.
+// The request is read from TASK_DATA_FILE and the response written to TASK_RESULT_FILE.
+func (a *CleanupAgent) RunTask(dataFile, resultFile string) error {
+	data, err := os.ReadFile(dataFile)
+	if err != nil {
+		return fmt.Errorf("reading task data: %v", err)
+	}
+	var request map[string]interface{}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return fmt.Errorf("parsing task data: %v", err)
+	}
+
+	fmt.Printf("%s running task %s: %s\n", a.AgentID, os.Getenv("TASK_ID"), request["action"])
+	response := a.HandleRequest(request)
+
+	result, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("encoding task result: %v", err)
+	}
+	if resultFile != "" {
+		if err := os.WriteFile(resultFile, result, 0600); err != nil {
+			return fmt.Errorf("writing task result: %v", err)
+		}
+	}
+	if message, failed := response["error"]; failed {
+		return fmt.Errorf("%v", message)
+	}
+	return nil
+}
+
 func main() {
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
 	// Check for direct cleanup mode
-	if len(os.Args) > 1 && os.Args[1] == "cleanup" {
-		agent := NewAgent()
+	if args := endpoints.Args(); len(args) > 0 && args[0] == "cleanup" {
+		agent := NewAgent(endpoints)
 		if err := agent.DirectCleanup(); err != nil {
 			log.Fatalf("Direct cleanup failed: %v", err)
 		}
 		return
 	}
 	
-	agent := NewAgent()
+	// Ephemeral task mode: the manager hands over one request and collects the result
+	if dataFile := os.Getenv("TASK_DATA_FILE"); dataFile != "" {
+		agent := NewAgent(endpoints)
+		if err := agent.RunTask(dataFile, os.Getenv("TASK_RESULT_FILE")); err != nil {
+			log.Fatalf("Task failed: %v", err)
+		}
+		return
+	}
+	
+	agent := NewAgent(endpoints)
 	agent.Start()
 }
\ No newline at end of file
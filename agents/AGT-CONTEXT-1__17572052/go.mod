@@ -1,10 +1,22 @@
 module agt-context-1
 
-go 1.19
+go 1.21
 
-require github.com/redis/go-redis/v9 v9.0.5
+require (
+	centerfire/shared/agent v0.0.0
+	centerfire/shared/config v0.0.0
+)
 
 require (
+	centerfire/shared/messages v0.0.0 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/redis/go-redis/v9 v9.0.5 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
+
+replace (
+	centerfire/shared/agent => ../../shared/agent
+	centerfire/shared/config => ../../shared/config
+	centerfire/shared/messages => ../../shared/messages
 )
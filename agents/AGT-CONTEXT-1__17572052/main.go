@@ -4,29 +4,28 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
-	
-	"github.com/redis/go-redis/v9"
+
+	"centerfire/shared/agent"
+	"centerfire/shared/config"
 )
 
 // ContextAgent - Fast Weaviate GraphQL context retrieval agent
 type ContextAgent struct {
-	AgentID         string
-	CID            string
-	RequestChannel  string
-	ResponseChannel string
-	RedisClient    *redis.Client
+	runtime        *agent.Agent
 	WeaviateClient *http.Client
 	WeaviateURL    string
-	Project        string  // Project name (e.g., "centerfire")
-	Environment    string  // Environment (dev/test/prod)
+	Project        string // Project name (e.g., "centerfire")
+	Environment    string // Environment (dev/test/prod)
 	ctx            context.Context
+	cacheMu        sync.Mutex
 	queryCache     map[string]*CacheEntry
 	cacheTimeout   time.Duration
 }
@@ -41,24 +40,17 @@ type GraphQLQuery struct {
 }
 
 type GraphQLResponse struct {
-	Data   interface{} `json:"data"`
+	Data   interface{}    `json:"data"`
 	Errors []GraphQLError `json:"errors,omitempty"`
 }
 
 type GraphQLError struct {
-	Message string `json:"message"`
+	Message string        `json:"message"`
 	Path    []interface{} `json:"path,omitempty"`
 }
 
-// NewAgent - Create new CONTEXT agent
-func NewAgent() *ContextAgent {
-	// Connect to Redis container on port 6380
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
-		Password: "",
-		DB:       0,
-	})
-	
+// NewAgent - Create new CONTEXT agent from the runtime configuration
+func NewAgent(cfg *agent.Config) (*ContextAgent, error) {
 	// Create HTTP client with persistent connections for Weaviate
 	httpClient := &http.Client{
 		Timeout: 10 * time.Second,
@@ -68,79 +60,42 @@ func NewAgent() *ContextAgent {
 			IdleConnTimeout:     30 * time.Second,
 		},
 	}
-	
-	return &ContextAgent{
-		AgentID:         "AGT-CONTEXT-1",
-		CID:            "cid:centerfire.dev:agent:17572052",
-		RequestChannel:  "agent.context.request",
-		ResponseChannel: "agent.context.response",
-		RedisClient:    rdb,
+
+	runtime := agent.New(cfg)
+	a := &ContextAgent{
+		runtime:        runtime,
 		WeaviateClient: httpClient,
-		WeaviateURL:    "http://localhost:8080",
-		Project:        "centerfire",  // Default project
-		Environment:    "dev",         // Default environment
-		ctx:            context.Background(),
+		WeaviateURL:    cfg.Endpoint(config.WeaviateURL),
+		Project:        "centerfire", // Default project
+		Environment:    "dev",        // Default environment
+		ctx:            runtime.Context(),
 		queryCache:     make(map[string]*CacheEntry),
 		cacheTimeout:   5 * time.Minute,
 	}
-}
 
-// Start - Start listening for requests
-func (a *ContextAgent) Start() {
-	fmt.Printf("%s starting...\n", a.AgentID)
-	fmt.Printf("Listening on: %s\n", a.RequestChannel)
-	
-	// Test Redis connection
-	_, err := a.RedisClient.Ping(a.ctx).Result()
-	if err != nil {
-		fmt.Printf("Failed to connect to Redis: %v\n", err)
-		return
-	}
-	fmt.Printf("Connected to Redis successfully\n")
-	
-	// Test Weaviate connection
-	err = a.testWeaviateConnection()
-	if err != nil {
-		fmt.Printf("Failed to connect to Weaviate: %v\n", err)
-		return
-	}
-	fmt.Printf("Connected to Weaviate successfully\n")
-	
-	// Register with AGT-MANAGER-1 for singleton enforcement
-	err = a.registerWithManager()
-	if err != nil {
-		fmt.Printf("Failed to register with manager: %v\n", err)
-		return
+	// Callers predating the envelope send parameters at the top level; Decode moves them into params
+	handlers := map[string]func(params map[string]interface{}) (map[string]interface{}, error){
+		"search_conversations": a.handleSearchConversations,
+		"get_context":          a.handleGetContext,
+		"search_semantic":      a.handleSearchSemantic,
+		"get_session_history":  a.handleGetSessionHistory,
+	}
+	for action, handler := range handlers {
+		handler := handler
+		runtime.Handle(action, func(req *agent.Request) (map[string]interface{}, error) {
+			return handler(req.ParamsMap())
+		})
 	}
-	fmt.Printf("Registered with AGT-MANAGER-1 successfully\n")
-	
-	// Subscribe to request channel
-	pubsub := a.RedisClient.Subscribe(a.ctx, a.RequestChannel)
-	defer pubsub.Close()
-	
-	// Set up graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	
-	// Start heartbeat
-	go a.startHeartbeat()
-	
-	// Start cache cleanup
-	go a.startCacheCleanup()
-	
-	fmt.Printf("%s ready for context retrieval requests\n", a.AgentID)
-	
-	// Listen for messages
-	for {
-		select {
-		case msg := <-pubsub.Channel():
-			go a.handleRequest(msg.Payload)
-		case <-sigChan:
-			fmt.Printf("\n%s shutting down gracefully...\n", a.AgentID)
-			a.unregisterWithManager()
-			return
+
+	runtime.OnStart(func(ctx context.Context) error {
+		if err := a.testWeaviateConnection(); err != nil {
+			return fmt.Errorf("failed to connect to Weaviate: %v", err)
 		}
-	}
+		fmt.Printf("Connected to Weaviate successfully\n")
+		go a.startCacheCleanup()
+		return nil
+	})
+	return a, nil
 }
 
 // testWeaviateConnection - Test connection to Weaviate
@@ -158,61 +113,26 @@ func (a *ContextAgent) testWeaviateConnection() error {
 	return nil
 }
 
-// handleRequest - Process incoming requests
-func (a *ContextAgent) handleRequest(payload string) {
-	var request map[string]interface{}
-	if err := json.Unmarshal([]byte(payload), &request); err != nil {
-		fmt.Printf("Failed to parse request: %v\n", err)
-		return
-	}
-	
-	action, ok := request["action"].(string)
-	if !ok {
-		a.publishError("Missing action field", request)
-		return
-	}
-	
-	requestID, _ := request["request_id"].(string)
-	
-	fmt.Printf("Processing request: %s (ID: %s)\n", action, requestID)
-	
-	switch action {
-	case "search_conversations":
-		a.handleSearchConversations(request)
-	case "get_context":
-		a.handleGetContext(request)
-	case "search_semantic":
-		a.handleSearchSemantic(request)
-	case "get_session_history":
-		a.handleGetSessionHistory(request)
-	default:
-		a.publishError(fmt.Sprintf("Unknown action: %s", action), request)
-	}
-}
-
 // handleSearchConversations - Search conversation history
-func (a *ContextAgent) handleSearchConversations(request map[string]interface{}) {
-	query, ok := request["query"].(string)
+func (a *ContextAgent) handleSearchConversations(params map[string]interface{}) (map[string]interface{}, error) {
+	query, ok := params["query"].(string)
 	if !ok {
-		a.publishError("Missing query parameter", request)
-		return
+		return nil, errors.New("Missing query parameter")
 	}
 	
 	limit := 10
-	if l, ok := request["limit"].(float64); ok {
+	if l, ok := params["limit"].(float64); ok {
 		limit = int(l)
 	}
 	
 	// Check cache first
 	cacheKey := fmt.Sprintf("conv_%s_%d", query, limit)
 	if cached := a.getFromCache(cacheKey); cached != nil {
-		a.publishResponse(map[string]interface{}{
+		return map[string]interface{}{
 			"success":    true,
 			"data":       cached,
-			"request_id": request["request_id"],
 			"cached":     true,
-		})
-		return
+		}, nil
 	}
 	
 	// Build GraphQL query for conversation search
@@ -234,33 +154,30 @@ func (a *ContextAgent) handleSearchConversations(request map[string]interface{})
 	
 	result, err := a.executeGraphQLQuery(graphqlQuery)
 	if err != nil {
-		a.publishError(fmt.Sprintf("GraphQL query failed: %v", err), request)
-		return
+		return nil, fmt.Errorf("GraphQL query failed: %v", err)
 	}
 	
 	// Cache the result
 	a.setCache(cacheKey, result)
 	
-	a.publishResponse(map[string]interface{}{
+	return map[string]interface{}{
 		"success":    true,
 		"data":       result,
-		"request_id": request["request_id"],
 		"cached":     false,
-	})
+	}, nil
 }
 
 // handleGetContext - Get context for a specific session or topic
-func (a *ContextAgent) handleGetContext(request map[string]interface{}) {
-	sessionID, hasSession := request["session_id"].(string)
-	topic, hasTopic := request["topic"].(string)
+func (a *ContextAgent) handleGetContext(params map[string]interface{}) (map[string]interface{}, error) {
+	sessionID, hasSession := params["session_id"].(string)
+	topic, hasTopic := params["topic"].(string)
 	
 	if !hasSession && !hasTopic {
-		a.publishError("Missing session_id or topic parameter", request)
-		return
+		return nil, errors.New("Missing session_id or topic parameter")
 	}
 	
 	limit := 5
-	if l, ok := request["limit"].(float64); ok {
+	if l, ok := params["limit"].(float64); ok {
 		limit = int(l)
 	}
 	
@@ -306,37 +223,32 @@ func (a *ContextAgent) handleGetContext(request map[string]interface{}) {
 	
 	// Check cache
 	if cached := a.getFromCache(cacheKey); cached != nil {
-		a.publishResponse(map[string]interface{}{
+		return map[string]interface{}{
 			"success":    true,
 			"data":       cached,
-			"request_id": request["request_id"],
 			"cached":     true,
-		})
-		return
+		}, nil
 	}
 	
 	result, err := a.executeGraphQLQuery(graphqlQuery)
 	if err != nil {
-		a.publishError(fmt.Sprintf("GraphQL query failed: %v", err), request)
-		return
+		return nil, fmt.Errorf("GraphQL query failed: %v", err)
 	}
 	
 	a.setCache(cacheKey, result)
 	
-	a.publishResponse(map[string]interface{}{
+	return map[string]interface{}{
 		"success":    true,
 		"data":       result,
-		"request_id": request["request_id"],
 		"cached":     false,
-	})
+	}, nil
 }
 
 // handleSearchSemantic - Generic semantic search
-func (a *ContextAgent) handleSearchSemantic(request map[string]interface{}) {
-	concepts, ok := request["concepts"].([]interface{})
+func (a *ContextAgent) handleSearchSemantic(params map[string]interface{}) (map[string]interface{}, error) {
+	concepts, ok := params["concepts"].([]interface{})
 	if !ok {
-		a.publishError("Missing concepts parameter", request)
-		return
+		return nil, errors.New("Missing concepts parameter")
 	}
 	
 	conceptStrings := make([]string, len(concepts))
@@ -347,20 +259,18 @@ func (a *ContextAgent) handleSearchSemantic(request map[string]interface{}) {
 	}
 	
 	limit := 10
-	if l, ok := request["limit"].(float64); ok {
+	if l, ok := params["limit"].(float64); ok {
 		limit = int(l)
 	}
 	
 	cacheKey := fmt.Sprintf("semantic_%s_%d", strings.Join(conceptStrings, "_"), limit)
 	
 	if cached := a.getFromCache(cacheKey); cached != nil {
-		a.publishResponse(map[string]interface{}{
+		return map[string]interface{}{
 			"success":    true,
 			"data":       cached,
-			"request_id": request["request_id"],
 			"cached":     true,
-		})
-		return
+		}, nil
 	}
 	
 	graphqlQuery := fmt.Sprintf(`{
@@ -381,38 +291,33 @@ func (a *ContextAgent) handleSearchSemantic(request map[string]interface{}) {
 	
 	result, err := a.executeGraphQLQuery(graphqlQuery)
 	if err != nil {
-		a.publishError(fmt.Sprintf("GraphQL query failed: %v", err), request)
-		return
+		return nil, fmt.Errorf("GraphQL query failed: %v", err)
 	}
 	
 	a.setCache(cacheKey, result)
 	
-	a.publishResponse(map[string]interface{}{
+	return map[string]interface{}{
 		"success":    true,
 		"data":       result,
-		"request_id": request["request_id"],
 		"cached":     false,
-	})
+	}, nil
 }
 
 // handleGetSessionHistory - Get complete session history
-func (a *ContextAgent) handleGetSessionHistory(request map[string]interface{}) {
-	sessionID, ok := request["session_id"].(string)
+func (a *ContextAgent) handleGetSessionHistory(params map[string]interface{}) (map[string]interface{}, error) {
+	sessionID, ok := params["session_id"].(string)
 	if !ok {
-		a.publishError("Missing session_id parameter", request)
-		return
+		return nil, errors.New("Missing session_id parameter")
 	}
 	
 	cacheKey := fmt.Sprintf("session_history_%s", sessionID)
 	
 	if cached := a.getFromCache(cacheKey); cached != nil {
-		a.publishResponse(map[string]interface{}{
+		return map[string]interface{}{
 			"success":    true,
 			"data":       cached,
-			"request_id": request["request_id"],
 			"cached":     true,
-		})
-		return
+		}, nil
 	}
 	
 	graphqlQuery := fmt.Sprintf(`{
@@ -435,18 +340,16 @@ func (a *ContextAgent) handleGetSessionHistory(request map[string]interface{}) {
 	
 	result, err := a.executeGraphQLQuery(graphqlQuery)
 	if err != nil {
-		a.publishError(fmt.Sprintf("GraphQL query failed: %v", err), request)
-		return
+		return nil, fmt.Errorf("GraphQL query failed: %v", err)
 	}
 	
 	a.setCache(cacheKey, result)
 	
-	a.publishResponse(map[string]interface{}{
+	return map[string]interface{}{
 		"success":    true,
 		"data":       result,
-		"request_id": request["request_id"],
 		"cached":     false,
-	})
+	}, nil
 }
 
 // executeGraphQLQuery - Execute GraphQL query against Weaviate
@@ -483,8 +386,10 @@ func (a *ContextAgent) executeGraphQLQuery(query string) (interface{}, error) {
 	return response.Data, nil
 }
 
-// Cache management
+// Cache management; handlers run concurrently, so every access holds cacheMu
 func (a *ContextAgent) getFromCache(key string) interface{} {
+	a.cacheMu.Lock()
+	defer a.cacheMu.Unlock()
 	entry, exists := a.queryCache[key]
 	if !exists {
 		return nil
@@ -499,6 +404,8 @@ func (a *ContextAgent) getFromCache(key string) interface{} {
 }
 
 func (a *ContextAgent) setCache(key string, data interface{}) {
+	a.cacheMu.Lock()
+	defer a.cacheMu.Unlock()
 	a.queryCache[key] = &CacheEntry{
 		Data:      data,
 		Timestamp: time.Now(),
@@ -513,91 +420,31 @@ func (a *ContextAgent) startCacheCleanup() {
 		select {
 		case <-ticker.C:
 			now := time.Now()
+			a.cacheMu.Lock()
 			for key, entry := range a.queryCache {
 				if now.Sub(entry.Timestamp) > a.cacheTimeout {
 					delete(a.queryCache, key)
 				}
 			}
+			a.cacheMu.Unlock()
 		case <-a.ctx.Done():
 			return
 		}
 	}
 }
 
-// Agent lifecycle management
-func (a *ContextAgent) registerWithManager() error {
-	registrationData := map[string]interface{}{
-		"action":      "register_running",
-		"agent_name":  a.AgentID,
-		"session_id":  fmt.Sprintf("%s_%d", a.AgentID, time.Now().Unix()),
-		"pid":         os.Getpid(),
-		"agent_type":  "persistent",
-		"capabilities": []string{"search_conversations", "get_context", "search_semantic", "get_session_history"},
-		"channels":    []string{a.RequestChannel},
-	}
-	
-	data, _ := json.Marshal(registrationData)
-	return a.RedisClient.Publish(a.ctx, "agent.manager.request", string(data)).Err()
-}
-
-func (a *ContextAgent) unregisterWithManager() error {
-	unregisterData := map[string]interface{}{
-		"action":     "unregister_running",
-		"agent_name": a.AgentID,
+func main() {
+	cfg, err := agent.LoadConfigArgs(os.Args[1:], "agent.yaml")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
 	}
-	
-	data, _ := json.Marshal(unregisterData)
-	return a.RedisClient.Publish(a.ctx, "agent.manager.request", string(data)).Err()
-}
 
-func (a *ContextAgent) startHeartbeat() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ticker.C:
-			heartbeatData := map[string]interface{}{
-				"action":     "heartbeat",
-				"agent_name": a.AgentID,
-				"timestamp":  time.Now().Unix(),
-				"status":     "healthy",
-				"cache_size": len(a.queryCache),
-			}
-			
-			data, _ := json.Marshal(heartbeatData)
-			a.RedisClient.Publish(a.ctx, "agent.manager.request", string(data))
-		case <-a.ctx.Done():
-			return
-		}
+	contextAgent, err := NewAgent(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create CONTEXT agent: %v", err)
 	}
-}
 
-// Response publishing
-func (a *ContextAgent) publishResponse(response map[string]interface{}) {
-	data, _ := json.Marshal(response)
-	a.RedisClient.Publish(a.ctx, a.ResponseChannel, string(data))
-}
-
-func (a *ContextAgent) publishError(errorMsg string, request map[string]interface{}) {
-	response := map[string]interface{}{
-		"success": false,
-		"error":   errorMsg,
+	if err := contextAgent.runtime.Run(); err != nil {
+		log.Fatalf("CONTEXT agent failed: %v", err)
 	}
-	
-	if requestID, ok := request["request_id"]; ok {
-		response["request_id"] = requestID
-	}
-	
-	a.publishResponse(response)
 }
-
-func main() {
-	agent := NewAgent()
-	if agent == nil {
-		fmt.Println("Failed to create CONTEXT agent")
-		return
-	}
-	
-	agent.Start()
-}
\ No newline at end of file
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 	
+	"centerfire/shared/messages"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -24,6 +25,7 @@ type AgentResponse struct {
 	Success   bool                   `json:"success"`
 	Data      map[string]interface{} `json:"data,omitempty"`
 	Error     string                `json:"error,omitempty"`
+	ErrorCode string                `json:"error_code,omitempty"` // typed failure reason (AGT-MANAGER-1)
 	RequestID string                `json:"request_id,omitempty"`
 	Timestamp time.Time             `json:"timestamp"`
 }
@@ -45,11 +47,10 @@ type AgentStatus struct {
 	ResponseTime *int64    `json:"response_time_ms,omitempty"` // nil if offline
 }
 
-// NewAgentProxy creates a new agent proxy
-func NewAgentProxy(ctx context.Context) *AgentProxy {
-	// Connect to Redis container on port 6380
+// NewAgentProxy creates a new agent proxy talking to Redis at redisAddr
+func NewAgentProxy(ctx context.Context, redisAddr string) *AgentProxy {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     redisAddr,
 		Password: "",
 		DB:       0,
 	})
@@ -66,14 +67,6 @@ func NewAgentProxy(ctx context.Context) *AgentProxy {
 func (ap *AgentProxy) ForwardToAgent(agent, action string, data map[string]interface{}, clientID, requestID string) (*AgentResponse, error) {
 	startTime := time.Now()
 	
-	// Create agent request in the format agents expect
-	agentReq := map[string]interface{}{
-		"action":     action,
-		"params":     data,  // AGT-NAMING-1 expects "params" not "data"
-		"client_id":  clientID,
-		"request_id": requestID,
-	}
-	
 	// Determine request and response channels based on agent
 	var requestChannel, responseChannel string
 	switch agent {
@@ -87,8 +80,8 @@ func (ap *AgentProxy) ForwardToAgent(agent, action string, data map[string]inter
 		requestChannel = "agent.semantic.request"
 		responseChannel = "agent.semantic.response"
 	case "manager":
-		requestChannel = "agent.manager.request"
-		responseChannel = "agent.manager.response"
+		// AGT-MANAGER-1 speaks its own request_type protocol with per-request reply channels
+		return ap.ForwardToManager(action, data, requestID)
 	case "system":
 		requestChannel = "agent.system.request"
 		responseChannel = "agent.system.response"
@@ -99,12 +92,25 @@ func (ap *AgentProxy) ForwardToAgent(agent, action string, data map[string]inter
 		return nil, fmt.Errorf("unknown agent: %s", agent)
 	}
 	
+	// Create agent request in the shared envelope format; known payloads are rejected here
+	// rather than after a round trip
+	agentReq, err := messages.NewRequest(action, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %v", err)
+	}
+	agentReq.RequestID = requestID
+	agentReq.ClientID = clientID
+	agentReq.ReplyTo = responseChannel
+	if err := agentReq.Validate(); err != nil {
+		return nil, err
+	}
+	
 	// Subscribe to response channel before sending request
 	pubsub := ap.redisClient.Subscribe(ap.ctx, responseChannel)
 	defer pubsub.Close()
 	
 	// Send request
-	requestData, err := json.Marshal(agentReq)
+	requestData, err := agentReq.Marshal()
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
@@ -182,6 +188,71 @@ func (ap *AgentProxy) ForwardToAgent(agent, action string, data map[string]inter
 	}
 }
 
+// ForwardToManager sends a request_type request to AGT-MANAGER-1 and waits for its correlated reply
+func (ap *AgentProxy) ForwardToManager(requestType string, data map[string]interface{}, requestID string) (*AgentResponse, error) {
+	startTime := time.Now()
+	responseChannel := fmt.Sprintf("centerfire:agent:manager:response:gateway:%s", requestID)
+
+	// Manager fields (agent_name, session_data, task_data, ...) are passed through from the body
+	managerReq := make(map[string]interface{}, len(data)+3)
+	for k, v := range data {
+		managerReq[k] = v
+	}
+	managerReq["request_type"] = requestType
+	managerReq["request_id"] = requestID
+	managerReq["response_channel"] = responseChannel
+
+	// Subscribe to the private reply channel before sending
+	pubsub := ap.redisClient.Subscribe(ap.ctx, responseChannel)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ap.ctx); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s: %v", responseChannel, err)
+	}
+
+	requestData, err := json.Marshal(managerReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+	if err := ap.redisClient.Publish(ap.ctx, "centerfire:agent:manager", requestData).Err(); err != nil {
+		return nil, fmt.Errorf("failed to publish request to manager: %v", err)
+	}
+
+	timeout := time.After(ap.requestTimeout)
+	ch := pubsub.Channel()
+	for {
+		select {
+		case msg := <-ch:
+			var response map[string]interface{}
+			if err := json.Unmarshal([]byte(msg.Payload), &response); err != nil {
+				continue
+			}
+			if responseReqID, _ := response["request_id"].(string); responseReqID != requestID {
+				continue
+			}
+
+			success, _ := response["success"].(bool)
+			agentResp := &AgentResponse{
+				Success:   success,
+				Data:      response,
+				RequestID: requestID,
+				Timestamp: time.Now(),
+			}
+			if !success {
+				agentResp.Error, _ = response["error"].(string)
+				agentResp.ErrorCode, _ = response["error_code"].(string)
+			}
+
+			fmt.Printf("📨 Manager responded to %s in %dms\n", requestType, time.Since(startTime).Milliseconds())
+			return agentResp, nil
+
+		case <-timeout:
+			return nil, fmt.Errorf("timeout waiting for response from manager")
+		case <-ap.ctx.Done():
+			return nil, fmt.Errorf("request cancelled")
+		}
+	}
+}
+
 // pingAgent sends a ping request to check if agent is responsive
 func (ap *AgentProxy) pingAgent(agent string) (*AgentResponse, error) {
 	requestID := fmt.Sprintf("ping_%d", time.Now().UnixNano())
@@ -2,10 +2,20 @@ module agt-http-gateway-1
 
 go 1.25.1
 
+require (
+	centerfire/shared/config v0.0.0
+	centerfire/shared/messages v0.0.0
+	github.com/gorilla/mux v1.8.1
+	github.com/redis/go-redis/v9 v9.13.0
+	gopkg.in/yaml.v2 v2.4.0
+)
+
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	github.com/gorilla/mux v1.8.1 // indirect
-	github.com/redis/go-redis/v9 v9.13.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
+
+replace (
+	centerfire/shared/config => ../../shared/config
+	centerfire/shared/messages => ../../shared/messages
 )
@@ -15,6 +15,7 @@ import (
 	"syscall"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/gorilla/mux"
 	"github.com/redis/go-redis/v9"
 )
@@ -30,6 +31,7 @@ type HTTPGatewayAgent struct {
 	redisClient       *redis.Client
 	ctx               context.Context
 	cancel            context.CancelFunc
+	endpoints         *config.Config // Layered endpoint settings (see --print-config)
 }
 
 // APIResponse represents a standardized API response
@@ -55,7 +57,7 @@ func findAvailablePort(startPort int) int {
 }
 
 // NewHTTPGatewayAgent creates a new HTTP Gateway agent
-func NewHTTPGatewayAgent() *HTTPGatewayAgent {
+func NewHTTPGatewayAgent(endpoints *config.Config) (*HTTPGatewayAgent, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	// Get contracts directory path (relative to project root)
@@ -64,12 +66,17 @@ func NewHTTPGatewayAgent() *HTTPGatewayAgent {
 		contractsDir = absPath
 	}
 	
-	// Find an available port starting from 8090
-	availablePort := findAvailablePort(8090)
+	// Find an available port starting from gateway_port
+	startPort, err := endpoints.Int(config.GatewayPort)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	availablePort := findAvailablePort(startPort)
 	
 	// Create Redis client for manager communication
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
@@ -79,11 +86,12 @@ func NewHTTPGatewayAgent() *HTTPGatewayAgent {
 		Port:              availablePort,
 		ContractsDir:      contractsDir,
 		ContractValidator: NewContractValidator(contractsDir),
-		AgentProxy:        NewAgentProxy(ctx),
+		AgentProxy:        NewAgentProxy(ctx, endpoints.Get(config.RedisAddr)),
 		redisClient:       redisClient,
 		ctx:               ctx,
 		cancel:            cancel,
-	}
+		endpoints:         endpoints,
+	}, nil
 }
 
 // Start initializes and starts the HTTP Gateway
@@ -199,12 +207,30 @@ func (h *HTTPGatewayAgent) handleAgentRequest(w http.ResponseWriter, r *http.Req
 	if agentResponse.Success {
 		w.WriteHeader(http.StatusOK)
 	} else {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(statusForErrorCode(agentResponse.ErrorCode))
 	}
 	
 	json.NewEncoder(w).Encode(agentResponse)
 }
 
+// statusForErrorCode maps typed agent error codes to HTTP status codes
+func statusForErrorCode(code string) int {
+	switch code {
+	case "invalid_request", "unknown_request_type", "not_ephemeral":
+		return http.StatusBadRequest
+	case "agent_not_found", "not_registered", "session_not_found":
+		return http.StatusNotFound
+	case "singleton_conflict":
+		return http.StatusConflict
+	case "dependency_failure":
+		return http.StatusFailedDependency
+	case "not_leader":
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // handleAgentDiscovery returns available agents and their status
 func (h *HTTPGatewayAgent) handleAgentDiscovery(w http.ResponseWriter, r *http.Request) {
 	clientID := h.extractClientID(r)
@@ -368,7 +394,7 @@ func (h *HTTPGatewayAgent) getAgentProcessStatus() []map[string]interface{} {
 			"name":           "AGT-MANAGER-1",
 			"type":           "persistent",
 			"capabilities":   []string{"agent_management", "service_discovery"},
-			"integrations":   []string{"Redis:6380", "HTTP:8380"},
+			"integrations":   []string{"Redis:" + h.endpoints.Get(config.RedisAddr), "HTTP:" + h.endpoints.Get(config.ManagerAddr)},
 			"location":       "agents/AGT-MANAGER-1__manager1",
 			"manager_status": "online", // If we can query it, it's online!
 			"has_registry":   true,
@@ -497,13 +523,18 @@ func (h *HTTPGatewayAgent) checkAgentRunning(agentName string) (bool, string) {
 	return false, "Not running"
 }
 
+// managerURL builds a URL on AGT-MANAGER-1's HTTP discovery service
+func (h *HTTPGatewayAgent) managerURL(path string) string {
+	return "http://" + h.endpoints.Get(config.ManagerAddr) + path
+}
+
 // getManagerRegistry queries AGT-MANAGER-1 HTTP service for registered agents
 func (h *HTTPGatewayAgent) getManagerRegistry() map[string]map[string]interface{} {
 	agentMap := make(map[string]map[string]interface{})
 	
 	// Query manager's HTTP service
 	client := &http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Get("http://localhost:8380/api/services")
+	resp, err := client.Get(h.managerURL("/api/services"))
 	if err != nil {
 		fmt.Printf("Failed to query manager registry: %v\n", err)
 		return agentMap
@@ -551,7 +582,7 @@ func (h *HTTPGatewayAgent) getManagerRegistry() map[string]map[string]interface{
 // isManagerResponding checks if the manager is responding to HTTP requests
 func (h *HTTPGatewayAgent) isManagerResponding() bool {
 	client := &http.Client{Timeout: 1 * time.Second}
-	resp, err := client.Get("http://localhost:8380/api/services")
+	resp, err := client.Get(h.managerURL("/api/services"))
 	if err != nil {
 		return false
 	}
@@ -601,9 +632,9 @@ func (h *HTTPGatewayAgent) getRedisStreams() (map[string]int64, error) {
 
 func (h *HTTPGatewayAgent) getServiceEndpointsHealth() []map[string]interface{} {
 	endpoints := []map[string]string{
-		{"name": "Weaviate", "url": "http://localhost:8080/v1/meta"},
-		{"name": "Neo4j", "url": "http://localhost:7474"},
-		{"name": "ClickHouse", "url": "http://localhost:8123/ping"},
+		{"name": "Weaviate", "url": strings.TrimRight(h.endpoints.Get(config.WeaviateURL), "/") + "/v1/meta"},
+		{"name": "Neo4j", "url": h.endpoints.Get(config.Neo4jHTTPURL)},
+		{"name": "ClickHouse", "url": strings.TrimRight(h.endpoints.Get(config.ClickHouseURL), "/") + "/ping"},
 	}
 	
 	results := make([]map[string]interface{}, len(endpoints))
@@ -830,6 +861,20 @@ func (h *HTTPGatewayAgent) startHeartbeat() {
 }
 
 func main() {
-	gateway := NewHTTPGatewayAgent()
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		fmt.Printf("❌ Failed to load configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	gateway, err := NewHTTPGatewayAgent(endpoints)
+	if err != nil {
+		fmt.Printf("❌ Failed to create gateway: %v\n", err)
+		os.Exit(1)
+	}
 	gateway.Start()
 }
\ No newline at end of file
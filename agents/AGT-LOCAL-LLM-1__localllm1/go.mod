@@ -3,11 +3,14 @@ module agt-local-llm
 go 1.25.1
 
 require (
+	centerfire/shared/config v0.0.0
 	github.com/go-redis/redis/v8 v8.11.5
-	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+replace centerfire/shared/config => ../../shared/config
@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/go-redis/redis/v8"
 )
 
@@ -21,6 +22,7 @@ type LocalLLMAgent struct {
 	RedisClient *redis.Client
 	ctx         context.Context
 	agentID     string
+	ollamaURL   string
 	models      map[string]*ModelConfig
 	activeModel string
 	mutex       sync.RWMutex
@@ -63,10 +65,10 @@ type OllamaResponse struct {
 	Done     bool   `json:"done"`
 }
 
-func NewLocalLLMAgent() *LocalLLMAgent {
+func NewLocalLLMAgent(endpoints *config.Config) *LocalLLMAgent {
 	ctx := context.Background()
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
@@ -75,6 +77,7 @@ func NewLocalLLMAgent() *LocalLLMAgent {
 		RedisClient: rdb,
 		ctx:         ctx,
 		agentID:     "AGT-LOCAL-LLM-1",
+		ollamaURL:   endpoints.Get(config.OllamaURL),
 		models:      make(map[string]*ModelConfig),
 	}
 
@@ -168,7 +171,7 @@ func (lla *LocalLLMAgent) ensureModelLoaded(model *ModelConfig) error {
 	}
 
 	reqBody, _ := json.Marshal(testReq)
-	resp, err := http.Post("http://localhost:11434/api/generate", 
+	resp, err := http.Post(lla.ollamaURL+"/api/generate", 
 		"application/json", bytes.NewBuffer(reqBody))
 	
 	if err != nil {
@@ -202,7 +205,7 @@ func (lla *LocalLLMAgent) queryOllama(model string, prompt string) (string, erro
 		return "", fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	resp, err := http.Post("http://localhost:11434/api/generate",
+	resp, err := http.Post(lla.ollamaURL+"/api/generate",
 		"application/json", bytes.NewBuffer(reqBody))
 	if err != nil {
 		return "", fmt.Errorf("ollama request failed: %v", err)
@@ -378,9 +381,18 @@ func (lla *LocalLLMAgent) getModelSummary() map[string]interface{} {
 }
 
 func main() {
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
 	log.Println("Starting AGT-LOCAL-LLM-1...")
 
-	agent := NewLocalLLMAgent()
+	agent := NewLocalLLMAgent(endpoints)
 	
 	// Register with manager
 	agent.registerWithManager()
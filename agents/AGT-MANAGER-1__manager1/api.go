@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"centerfire/shared/config"
+	"github.com/gorilla/mux"
+)
+
+// managementRoute maps a REST endpoint onto a processRequest request type
+type managementRoute struct {
+	Method      string
+	Path        string
+	RequestType string
+	Status      int // HTTP status on success
+}
+
+// managementRoutes mirrors every mutating or reply-producing request type handled by processRequest
+var managementRoutes = []managementRoute{
+	{"POST", "/agents/{agent_name}/start", "start_agent", http.StatusOK},
+	{"POST", "/agents/{agent_name}/stop", "stop_agent", http.StatusOK},
+	{"DELETE", "/agents/{agent_name}", "stop_agent", http.StatusOK},
+	{"POST", "/agents/{agent_name}/restart", "restart_agent", http.StatusOK},
+	{"POST", "/agents/{agent_name}/restart-with-dependencies", "restart_with_dependencies", http.StatusOK},
+	{"POST", "/agents/{agent_name}/running", "register_running", http.StatusCreated},
+	{"DELETE", "/agents/{agent_name}/running", "unregister_running", http.StatusOK},
+	{"POST", "/agents/{agent_name}/heartbeat", "heartbeat", http.StatusOK},
+	{"POST", "/agents/{agent_name}/collision-check", "check_agent_collision", http.StatusOK},
+	{"POST", "/agents/{agent_name}/dependencies/check", "check_dependencies", http.StatusOK},
+	{"POST", "/collisions/check", "check_collisions", http.StatusOK},
+	{"POST", "/services/{service_name}/health-check", "validate_service_health", http.StatusOK},
+	{"POST", "/ephemeral/{agent_name}/spawn", "spawn_ephemeral", http.StatusAccepted},
+	{"GET", "/tasks/{task_id}", "task_status", http.StatusOK},
+	{"POST", "/registry", "register_agent", http.StatusCreated},
+	{"POST", "/sessions/{session_id}/restore", "session_restore", http.StatusOK},
+	{"GET", "/registry", "list_registry", http.StatusOK},
+	{"GET", "/registry/{agent_name}", "get_agent_definition", http.StatusOK},
+	{"GET", "/managed-agents", "list_agents", http.StatusOK},
+	{"GET", "/managed-agents/{agent_name}", "agent_status", http.StatusOK},
+}
+
+const maxManagementBodySize = 1 << 20
+
+// registerManagementRoutes wires the lifecycle endpoints; mutations require the API token
+func (am *AgentManager) registerManagementRoutes(api *mux.Router) {
+	for _, route := range managementRoutes {
+		handler := am.managementHandler(route)
+		if route.Method != http.MethodGet {
+			handler = am.requireAPIToken(handler)
+		}
+		api.HandleFunc(route.Path, handler).Methods(route.Method)
+	}
+}
+
+// APIToken guards the management API's mutating endpoints
+var APIToken = config.Setting{Key: "manager_api_token", Env: "MANAGER_API_TOKEN",
+	Usage: "token required by the management REST API's mutating endpoints", Secret: true}
+
+// requireAPIToken checks "Authorization: Bearer <token>" or "X-API-Key" against the configured
+// manager_api_token. Without one the management API refuses all mutations.
+func (am *AgentManager) requireAPIToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expected := am.endpoints.Get(APIToken)
+		if expected == "" {
+			writeAPIError(w, http.StatusServiceUnavailable, "management API disabled: MANAGER_API_TOKEN not set")
+			return
+		}
+
+		provided := r.Header.Get("X-API-Key")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			provided = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if provided == "" {
+			writeAPIError(w, http.StatusUnauthorized, "missing API token")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+			writeAPIError(w, http.StatusForbidden, "invalid API token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// managementHandler builds an AgentRequest from path and body, dispatches it and returns the reply
+func (am *AgentManager) managementHandler(route managementRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, err := parseManagementRequest(r, route)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		request.replyTo = make(chan map[string]interface{}, 1)
+
+		am.dispatchRequest(request)
+
+		var response map[string]interface{}
+		select {
+		case response = <-request.replyTo:
+		default:
+			// Fire-and-forget request types (heartbeat, unregister_running) don't reply
+			response = map[string]interface{}{
+				"status":       "accepted",
+				"success":      true,
+				"request_id":   request.RequestID,
+				"request_type": request.RequestType,
+			}
+		}
+
+		status := route.Status
+		if success, _ := response["success"].(bool); !success {
+			code, _ := response["error_code"].(ManagerErrorCode)
+			status = httpStatusForErrorCode(code)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// parseManagementRequest merges the optional JSON body with path variables
+func parseManagementRequest(r *http.Request, route managementRoute) (AgentRequest, error) {
+	var request AgentRequest
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxManagementBodySize))
+	if err != nil {
+		return request, fmt.Errorf("failed to read request body: %v", err)
+	}
+
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if route.RequestType == "register_agent" {
+			// Body is the agent definition itself
+			var def AgentDefinition
+			if err := json.Unmarshal(body, &def); err != nil {
+				return request, fmt.Errorf("invalid agent definition: %v", err)
+			}
+			request.AgentDef = &def
+		} else if err := json.Unmarshal(body, &request); err != nil {
+			return request, fmt.Errorf("invalid JSON body: %v", err)
+		}
+	}
+
+	vars := mux.Vars(r)
+	request.RequestType = route.RequestType
+	if name, ok := vars["agent_name"]; ok {
+		request.AgentName = name
+	}
+	if service, ok := vars["service_name"]; ok {
+		request.AgentName = service // validate_service_health reuses agent_name
+	}
+	if taskID, ok := vars["task_id"]; ok {
+		request.TaskID = taskID
+	}
+	if sessionID, ok := vars["session_id"]; ok {
+		if request.SessionData == nil {
+			request.SessionData = map[string]interface{}{}
+		}
+		request.SessionData["session_id"] = sessionID
+	}
+	if request.RequestID == "" {
+		request.RequestID = fmt.Sprintf("http_%d", time.Now().UnixNano())
+	}
+	request.ResponseChannel = "" // replies go back over HTTP
+
+	return request, nil
+}
+
+// httpStatusForErrorCode maps manager error codes to HTTP status codes
+func httpStatusForErrorCode(code ManagerErrorCode) int {
+	switch code {
+	case ErrCodeInvalidRequest, ErrCodeUnknownRequestType, ErrCodeNotEphemeral:
+		return http.StatusBadRequest
+	case ErrCodeAgentNotFound, ErrCodeNotRegistered, ErrCodeSessionNotFound, ErrCodeTaskNotFound:
+		return http.StatusNotFound
+	case ErrCodeSingletonConflict, ErrCodeRolloutInProgress:
+		return http.StatusConflict
+	case ErrCodeDependencyFailure:
+		return http.StatusFailedDependency
+	case ErrCodeNotLeader:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   false,
+		"error":     message,
+		"timestamp": time.Now(),
+	})
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"centerfire/shared/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// DependencyProbe checks a single service dependency, returning health and a short detail message
+type DependencyProbe func(ctx context.Context, am *AgentManager, dep ServiceDependency) (bool, string)
+
+const (
+	dependencyProbeTimeout = 5 * time.Second
+	defaultDockerSocket    = "/var/run/docker.sock"
+)
+
+var (
+	dependencyProbesMu sync.RWMutex
+	// dependencyProbes is keyed by "type:service" for specific services and "type" for fallbacks
+	dependencyProbes = map[string]DependencyProbe{}
+)
+
+// RegisterDependencyProbe adds or replaces the probe used for a dependency key.
+// Keys are "type:service" (e.g. "infrastructure:weaviate") or a bare type used as fallback.
+func RegisterDependencyProbe(key string, probe DependencyProbe) {
+	dependencyProbesMu.Lock()
+	defer dependencyProbesMu.Unlock()
+	dependencyProbes[key] = probe
+}
+
+// lookupDependencyProbe finds the most specific probe for a dependency
+func lookupDependencyProbe(dep ServiceDependency) (DependencyProbe, bool) {
+	dependencyProbesMu.RLock()
+	defer dependencyProbesMu.RUnlock()
+	if probe, ok := dependencyProbes[dep.Type+":"+dep.Service]; ok {
+		return probe, true
+	}
+	probe, ok := dependencyProbes[dep.Type]
+	return probe, ok
+}
+
+func init() {
+	RegisterDependencyProbe("infrastructure:redis", probeRedis)
+	RegisterDependencyProbe("infrastructure:weaviate", httpProbe(config.WeaviateURL, "/v1/meta"))
+	RegisterDependencyProbe("infrastructure:neo4j", httpProbe(config.Neo4jHTTPURL, "/"))
+	RegisterDependencyProbe("infrastructure:clickhouse", httpProbe(config.ClickHouseURL, "/ping"))
+	RegisterDependencyProbe("infrastructure:docker", probeDockerDaemon)
+	RegisterDependencyProbe("infrastructure", probeTCP) // unknown infrastructure: endpoint must accept connections
+	RegisterDependencyProbe("agent", probeAgent)
+	RegisterDependencyProbe("container", probeContainer)
+	RegisterDependencyProbe("tcp", probeTCP)
+	RegisterDependencyProbe("http", httpProbe(config.Setting{}, ""))
+}
+
+// probeRedis pings the dependency's Redis, reusing the manager client when endpoints match
+func probeRedis(ctx context.Context, am *AgentManager, dep ServiceDependency) (bool, string) {
+	client := am.RedisClient
+	if dep.Endpoint != "" && dep.Endpoint != am.RedisClient.Options().Addr {
+		client = redis.NewClient(&redis.Options{Addr: dep.Endpoint})
+		defer client.Close()
+	}
+	if _, err := client.Ping(ctx).Result(); err != nil {
+		return false, fmt.Sprintf("Redis ping failed: %v", err)
+	}
+	return true, "Redis ping successful"
+}
+
+// httpProbe returns a probe that GETs path on the dependency endpoint and expects a 2xx/3xx status.
+// Dependencies without an endpoint use the manager's configured value of defaultEndpoint.
+func httpProbe(defaultEndpoint config.Setting, path string) DependencyProbe {
+	return func(ctx context.Context, am *AgentManager, dep ServiceDependency) (bool, string) {
+		target, err := dependencyURL(dep.Endpoint, am.endpoints.Get(defaultEndpoint), path)
+		if err != nil {
+			return false, fmt.Sprintf("%s health check failed: %v", dep.Service, err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return false, fmt.Sprintf("%s health check failed: %v", dep.Service, err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return false, fmt.Sprintf("%s health check failed: %v", dep.Service, err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 100))
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return false, fmt.Sprintf("%s returned HTTP %d", dep.Service, resp.StatusCode)
+		}
+		return true, fmt.Sprintf("%s responding (HTTP %d): %s", dep.Service, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
+
+// dependencyURL builds an http URL from a host:port or full URL endpoint
+func dependencyURL(endpoint, defaultEndpoint, path string) (string, error) {
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	if endpoint == "" {
+		return "", fmt.Errorf("no endpoint configured")
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %s: %v", endpoint, err)
+	}
+	if (u.Path == "" || u.Path == "/") && path != "" {
+		u.Path = path
+	}
+	return u.String(), nil
+}
+
+// probeTCP checks that the dependency endpoint accepts TCP connections
+func probeTCP(ctx context.Context, am *AgentManager, dep ServiceDependency) (bool, string) {
+	address := dep.Endpoint
+	if u, err := url.Parse(address); err == nil && u.Host != "" {
+		address = u.Host
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return false, fmt.Sprintf("%s endpoint %q is not host:port", dep.Service, dep.Endpoint)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return false, fmt.Sprintf("%s TCP dial failed: %v", dep.Service, err)
+	}
+	conn.Close()
+	return true, fmt.Sprintf("%s accepting connections on %s", dep.Service, address)
+}
+
+// dockerClient returns an HTTP client speaking to the Docker Engine API over its unix socket
+func dockerClient(endpoint string) *http.Client {
+	socket := defaultDockerSocket
+	if strings.HasPrefix(endpoint, "unix://") {
+		socket = strings.TrimPrefix(endpoint, "unix://")
+	} else if strings.HasPrefix(endpoint, "/") {
+		socket = endpoint
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// probeDockerDaemon pings the Docker Engine API
+func probeDockerDaemon(ctx context.Context, am *AgentManager, dep ServiceDependency) (bool, string) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/_ping", nil)
+	resp, err := dockerClient(dep.Endpoint).Do(req)
+	if err != nil {
+		return false, fmt.Sprintf("Docker daemon check failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Sprintf("Docker daemon returned HTTP %d", resp.StatusCode)
+	}
+	return true, "Docker daemon responding"
+}
+
+// probeContainer checks a Docker container (named by the endpoint) is in running state
+func probeContainer(ctx context.Context, am *AgentManager, dep ServiceDependency) (bool, string) {
+	containerName := dep.Endpoint
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/"+url.PathEscape(containerName)+"/json", nil)
+	resp, err := dockerClient("").Do(req)
+	if err != nil {
+		return false, fmt.Sprintf("Container %s check failed: %v", containerName, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, fmt.Sprintf("Container %s not found", containerName)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Sprintf("Container %s inspect returned HTTP %d", containerName, resp.StatusCode)
+	}
+
+	var inspect struct {
+		State struct {
+			Status string `json:"Status"`
+		} `json:"State"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return false, fmt.Sprintf("Container %s inspect decode failed: %v", containerName, err)
+	}
+	if inspect.State.Status != "running" {
+		return false, fmt.Sprintf("Container %s status: %s", containerName, inspect.State.Status)
+	}
+	return true, fmt.Sprintf("Container %s is running", containerName)
+}
+
+// probeAgent validates that required agents are running and responsive
+func probeAgent(ctx context.Context, am *AgentManager, dep ServiceDependency) (bool, string) {
+	agentProcess, exists := am.state.getRunning(dep.Service)
+	if !exists {
+		return false, fmt.Sprintf("Agent %s not registered as running", dep.Service)
+	}
+	if am.isLocal(agentProcess.Host) && !am.isProcessRunning(agentProcess.PID) {
+		return false, fmt.Sprintf("Agent %s process dead (PID %d)", dep.Service, agentProcess.PID)
+	}
+	if time.Since(agentProcess.LastHeartbeat) >= am.heartbeatTimeout {
+		return false, fmt.Sprintf("Agent %s heartbeat timeout (last: %v)", dep.Service, agentProcess.LastHeartbeat)
+	}
+	return true, fmt.Sprintf("Agent %s running (PID %d, last heartbeat %v)",
+		dep.Service, agentProcess.PID, agentProcess.LastHeartbeat)
+}
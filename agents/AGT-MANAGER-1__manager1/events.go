@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Lifecycle events are appended to a capped Redis stream so observers can replay what they
+// missed, and mirrored to the responses pub/sub channel for live listeners.
+const (
+	lifecycleStream       = "centerfire:agents:lifecycle"
+	lifecycleStreamMaxLen = 10000
+	defaultEventLimit     = 100
+	maxEventLimit         = 1000
+)
+
+// LifecycleEventType names a manager lifecycle event
+type LifecycleEventType string
+
+const (
+	EventAgentStarted       LifecycleEventType = "agent_started"
+	EventAgentExited        LifecycleEventType = "agent_exited"
+	EventAgentStopped       LifecycleEventType = "agent_stopped"
+	EventAgentRestarted     LifecycleEventType = "agent_restarted"
+	EventAgentRegistered    LifecycleEventType = "agent_registered"
+	EventAgentUnregistered  LifecycleEventType = "agent_unregistered"
+	EventHeartbeatTimeout   LifecycleEventType = "heartbeat_timeout"
+	EventHealthChanged      LifecycleEventType = "health_changed"
+	EventTaskQueued         LifecycleEventType = "task_queued"
+	EventTaskStarted        LifecycleEventType = "task_started"
+	EventTaskFinished       LifecycleEventType = "task_finished"
+	EventRolloutStarted     LifecycleEventType = "rollout_started"
+	EventRolloutFinished    LifecycleEventType = "rollout_finished"
+	EventLeadershipAcquired LifecycleEventType = "leadership_acquired"
+	EventLeadershipReleased LifecycleEventType = "leadership_released"
+)
+
+// LifecycleEvent is one entry of the lifecycle stream
+type LifecycleEvent struct {
+	ID        string                 `json:"id,omitempty"` // stream entry ID, usable as a since cursor
+	Type      LifecycleEventType     `json:"type"`
+	Agent     string                 `json:"agent,omitempty"`
+	Instance  string                 `json:"instance,omitempty"`
+	PID       int                    `json:"pid,omitempty"`
+	ExitCode  *int                   `json:"exit_code,omitempty"`
+	Reason    string                 `json:"reason,omitempty"`
+	ManagerID string                 `json:"manager_id"`
+	Timestamp time.Time              `json:"timestamp"`
+	StartedAt *time.Time             `json:"started_at,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// emitEvent records a lifecycle event. Only the leader emits, so followers mirroring the same
+// requests don't duplicate history.
+func (am *AgentManager) emitEvent(event LifecycleEvent) {
+	if !am.isLeader() {
+		return
+	}
+	event.ManagerID = am.managerID
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	id, err := am.RedisClient.XAdd(am.ctx, &redis.XAddArgs{
+		Stream: lifecycleStream,
+		MaxLen: lifecycleStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"type":  string(event.Type),
+			"agent": event.Agent,
+			"event": string(eventJSON),
+		},
+	}).Result()
+	if err != nil {
+		fmt.Printf("Warning: Failed to record %s event for %s: %v\n", event.Type, event.Agent, err)
+	}
+	event.ID = id
+
+	// Live listeners on the responses channel keep receiving events keyed by "event"
+	live := map[string]interface{}{}
+	eventJSON, _ = json.Marshal(event)
+	json.Unmarshal(eventJSON, &live)
+	live["event"] = event.Type
+	liveJSON, _ := json.Marshal(live)
+	am.RedisClient.Publish(am.ctx, managerResponsesChannel, string(liveJSON))
+}
+
+// readEvents returns events after the since cursor, oldest first, optionally filtered
+func (am *AgentManager) readEvents(since string, limit int, agent, eventType string) ([]LifecycleEvent, string, error) {
+	start, err := eventCursor(since)
+	if err != nil {
+		return nil, "", err
+	}
+
+	events := make([]LifecycleEvent, 0)
+	cursor := start
+	next := since
+
+	// Filters may skip entries, so page through the stream until the limit is filled
+	for len(events) < limit {
+		messages, err := am.RedisClient.XRangeN(am.ctx, lifecycleStream, cursor, "+", int64(limit)).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		for _, msg := range messages {
+			next = msg.ID
+			if agent != "" && msg.Values["agent"] != agent {
+				continue
+			}
+			if eventType != "" && msg.Values["type"] != eventType {
+				continue
+			}
+			data, _ := msg.Values["event"].(string)
+			var event LifecycleEvent
+			if json.Unmarshal([]byte(data), &event) != nil {
+				continue
+			}
+			event.ID = msg.ID
+			events = append(events, event)
+			if len(events) == limit {
+				break
+			}
+		}
+		if len(messages) < limit {
+			break
+		}
+		cursor = "(" + next
+	}
+
+	return events, next, nil
+}
+
+// eventCursor converts a since value into an exclusive XRANGE start.
+// Accepts a stream ID, unix milliseconds or an RFC3339 timestamp; empty means from the beginning.
+func eventCursor(since string) (string, error) {
+	if since == "" {
+		return "-", nil
+	}
+	if strings.Contains(since, "-") && !strings.Contains(since, "T") {
+		return "(" + since, nil // stream ID from a previous response
+	}
+	if ms, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return fmt.Sprintf("%d", ms+1), nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return fmt.Sprintf("%d", t.UnixMilli()+1), nil
+	}
+	return "", fmt.Errorf("invalid since %q: use an event id, unix milliseconds or RFC3339", since)
+}
+
+// handleEventsHTTP serves GET /api/events?since=&limit=&agent=&type=
+func (am *AgentManager) handleEventsHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := defaultEventLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxEventLimit {
+		limit = maxEventLimit
+	}
+
+	events, next, err := am.readEvents(query.Get("since"), limit, query.Get("agent"), query.Get("type"))
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid since") {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+		} else {
+			writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("failed to read events: %v", err))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"stream":    lifecycleStream,
+		"events":    events,
+		"count":     len(events),
+		"next":      next, // pass back as since to continue
+		"timestamp": time.Now(),
+	})
+}
@@ -3,6 +3,8 @@ module centerfire/agents/manager
 go 1.25.1
 
 require (
+	centerfire/shared/config v0.0.0
+	github.com/alicebob/miniredis/v2 v2.35.0
 	github.com/gorilla/mux v1.8.1
 	github.com/redis/go-redis/v9 v9.13.0
 )
@@ -10,4 +12,8 @@ require (
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+replace centerfire/shared/config => ../../shared/config
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	leaderKey        = "centerfire:agents:manager:leader"
+	leaderLeaseTTL   = 15 * time.Second
+	leaderRenewEvery = 5 * time.Second
+)
+
+// renewLeaseScript extends the lease only if this manager still holds it
+var renewLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeaseScript deletes the lease only if this manager still holds it
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// leaderElection tracks this manager's role in the Redis lease
+type leaderElection struct {
+	leader atomic.Bool
+}
+
+// localHostname is recorded with every PID the manager stores, since a PID only identifies a
+// process on the host it came from. Empty when the hostname cannot be read.
+func localHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
+// newManagerID generates an instance ID unique across hosts, processes and restarts
+func newManagerID(hostname string) string {
+	if hostname == "" {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("manager-%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
+}
+
+// isLocal reports whether a PID recorded on host can be checked in this host's process table
+func (am *AgentManager) isLocal(host string) bool {
+	return host != "" && host == am.hostname
+}
+
+// agentAlive decides whether a registered agent is still there. Managers may run on several
+// hosts, so a PID from another host, or from an unknown one, is never looked up in the local
+// process table; for those agents heartbeat freshness alone decides.
+func (am *AgentManager) agentAlive(process AgentProcess) bool {
+	if am.isLocal(process.Host) {
+		return am.isProcessRunning(process.PID)
+	}
+	return time.Since(process.LastHeartbeat) <= am.heartbeatTimeout
+}
+
+// isLeader reports whether this manager currently holds the leader lease
+func (am *AgentManager) isLeader() bool {
+	return am.election.leader.Load()
+}
+
+// currentLeader returns the manager ID holding the lease, if any
+func (am *AgentManager) currentLeader() string {
+	leader, err := am.RedisClient.Get(am.ctx, leaderKey).Result()
+	if err != nil {
+		return ""
+	}
+	return leader
+}
+
+// role describes this manager for discovery responses
+func (am *AgentManager) role() string {
+	if am.isLeader() {
+		return "leader"
+	}
+	return "follower"
+}
+
+// startLeaderElection campaigns for the lease once synchronously, then keeps renewing in background
+func (am *AgentManager) startLeaderElection() {
+	am.campaign()
+
+	ticker := time.NewTicker(leaderRenewEvery)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				am.campaign()
+			case <-am.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// campaign renews the lease if held, otherwise tries to acquire it, and handles role transitions
+func (am *AgentManager) campaign() {
+	wasLeader := am.isLeader()
+	holding := false
+
+	if wasLeader {
+		renewed, err := renewLeaseScript.Run(am.ctx, am.RedisClient, []string{leaderKey}, am.managerID, leaderLeaseTTL.Milliseconds()).Int()
+		holding = err == nil && renewed == 1
+		if err != nil {
+			fmt.Printf("%s: Leader lease renewal failed: %v\n", am.AgentID, err)
+		}
+	}
+	if !holding {
+		acquired, err := am.RedisClient.SetNX(am.ctx, leaderKey, am.managerID, leaderLeaseTTL).Result()
+		holding = err == nil && acquired
+	}
+
+	am.election.leader.Store(holding)
+
+	switch {
+	case holding && !wasLeader:
+		fmt.Printf("%s: %s acquired leadership\n", am.AgentID, am.managerID)
+		am.emitEvent(LifecycleEvent{Type: EventLeadershipAcquired, Reason: "lease_acquired"})
+		am.adoptRunningAgents()
+	case !holding && wasLeader:
+		// Managed children keep running; the new leader adopts them by PID and heartbeat
+		fmt.Printf("%s: %s lost leadership to %s\n", am.AgentID, am.managerID, am.currentLeader())
+	}
+}
+
+// releaseLeadership gives up the lease on shutdown so a follower can take over immediately
+func (am *AgentManager) releaseLeadership() {
+	if !am.isLeader() {
+		return
+	}
+	am.emitEvent(LifecycleEvent{Type: EventLeadershipReleased, Reason: "shutdown"})
+	releaseLeaseScript.Run(am.ctx, am.RedisClient, []string{leaderKey}, am.managerID)
+	am.election.leader.Store(false)
+}
+
+// adoptRunningAgents takes over agents left by a previous leader instead of relaunching them.
+// Agents on this host must still have a live PID; agents elsewhere are adopted on trust and
+// dropped by the heartbeat monitor if they stay silent.
+func (am *AgentManager) adoptRunningAgents() {
+	adopted := 0
+
+	// Self-registered agents persisted by storeAgentInRedis
+	iter := am.RedisClient.Scan(am.ctx, 0, "centerfire:agents:running:*", 100).Iterator()
+	for iter.Next(am.ctx) {
+		key := iter.Val()
+		data, err := am.RedisClient.Get(am.ctx, key).Result()
+		if err != nil {
+			continue
+		}
+		var record map[string]interface{}
+		if json.Unmarshal([]byte(data), &record) != nil {
+			continue
+		}
+		agentName := strings.TrimPrefix(key, "centerfire:agents:running:")
+		pid := pidFromRecord(record)
+		host, _ := record["host"].(string)
+
+		if am.adoptProcess(agentName, host, pid, time.Time{}) {
+			adopted++
+		}
+	}
+
+	// Instances launched by the previous leader, recorded by registerAgentInstance
+	iter = am.RedisClient.Scan(am.ctx, 0, "centerfire:agents:active:*", 100).Iterator()
+	for iter.Next(am.ctx) {
+		instanceKey := iter.Val()
+		agentName := strings.TrimPrefix(instanceKey, "centerfire:agents:active:")
+		instances, err := am.RedisClient.HGetAll(am.ctx, instanceKey).Result()
+		if err != nil {
+			continue
+		}
+
+		for instanceID, data := range instances {
+			var instanceInfo map[string]interface{}
+			if json.Unmarshal([]byte(data), &instanceInfo) != nil {
+				continue
+			}
+			if instanceInfo["manager_id"] == am.managerID {
+				continue
+			}
+
+			pid := pidFromRecord(instanceInfo)
+			host, _ := instanceInfo["host"].(string)
+			lastHeartbeat := time.Time{}
+			if hb, ok := instanceInfo["heartbeat"].(float64); ok {
+				lastHeartbeat = time.Unix(int64(hb), 0)
+			}
+
+			if time.Since(lastHeartbeat) > am.heartbeatTimeout || (am.isLocal(host) && !am.isProcessRunning(pid)) {
+				// Dead or silent instance from the old leader
+				am.RedisClient.HDel(am.ctx, instanceKey, instanceID)
+				continue
+			}
+
+			// Re-home the instance record under this manager so unregister/heartbeat find it
+			instanceInfo["manager_id"] = am.managerID
+			instanceInfo["instance_id"] = am.instanceID(agentName)
+			instanceJSON, _ := json.Marshal(instanceInfo)
+			am.RedisClient.HSet(am.ctx, instanceKey, am.instanceID(agentName), string(instanceJSON))
+			am.RedisClient.HDel(am.ctx, instanceKey, instanceID)
+
+			if am.adoptProcess(agentName, host, pid, lastHeartbeat) {
+				adopted++
+			}
+		}
+	}
+
+	fmt.Printf("%s: Adopted %d running agents from previous leader\n", am.AgentID, adopted)
+}
+
+// adoptProcess tracks a running agent without relaunching it. A PID on this host must be live;
+// one from another host cannot be checked here.
+func (am *AgentManager) adoptProcess(agentName, host string, pid int, lastHeartbeat time.Time) bool {
+	if _, tracked := am.state.getRunning(agentName); tracked {
+		return false
+	}
+	if am.isLocal(host) && !am.isProcessRunning(pid) {
+		return false
+	}
+	if lastHeartbeat.IsZero() {
+		// Grace period: expect the next heartbeat within the normal timeout
+		lastHeartbeat = time.Now()
+	}
+
+	agentType := PersistentAgent
+	if agentDef, exists := am.state.getDefinition(agentName); exists {
+		agentType = agentDef.Type
+	}
+
+	am.state.putRunning(agentName, &AgentProcess{
+		Name:          agentName,
+		Host:          host,
+		PID:           pid,
+		Running:       true,
+		StartTime:     time.Now(),
+		LastHeartbeat: lastHeartbeat,
+		AgentType:     agentType,
+	})
+	fmt.Printf("%s: Adopted %s (PID %d on %s)\n", am.AgentID, agentName, pid, host)
+	return true
+}
+
+func pidFromRecord(record map[string]interface{}) int {
+	switch pid := record["pid"].(type) {
+	case float64:
+		return int(pid)
+	case int:
+		return pid
+	}
+	return 0
+}
+
+// followerReplicatedRequests are applied by followers to keep read-only discovery current
+var followerReplicatedRequests = map[string]bool{
+	"register_running":   true,
+	"unregister_running": true,
+	"heartbeat":          true,
+}
+
+// followerReadOnlyRequests can be answered by followers when asked directly rather than over pub/sub
+var followerReadOnlyRequests = map[string]bool{
+	"ping":                 true,
+	"list_agents":          true,
+	"agent_status":         true,
+	"list_registry":        true,
+	"get_agent_definition": true,
+	"task_status":          true,
+}
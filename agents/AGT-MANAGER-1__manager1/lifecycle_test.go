@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"centerfire/shared/config"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// fakeAgentSource is a stand-in agent: it idles until the manager signals it
+const fakeAgentSource = `package main
+
+import "time"
+
+func main() {
+	time.Sleep(time.Hour)
+}
+`
+
+// newTestManager returns a leader manager on an in-memory Redis with one fake agent per name.
+// Agents are real processes built and launched through launchAgent, as in production.
+func newTestManager(t *testing.T, names ...string) *AgentManager {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not on PATH; fake agents cannot be built")
+	}
+
+	// Keep the shared build cache but put the fake agents' binaries in the test's own directory
+	if cache, err := exec.Command("go", "env", "GOCACHE").Output(); err == nil {
+		t.Setenv("GOCACHE", strings.TrimSpace(string(cache)))
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	directory := t.TempDir()
+	if err := os.WriteFile(filepath.Join(directory, "main.go"), []byte(fakeAgentSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	redisServer := miniredis.RunT(t)
+	t.Setenv("REDIS_ADDR", redisServer.Addr())
+	endpoints, err := config.Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := NewAgentManager(endpoints)
+	am.heartbeatTimeout = time.Nanosecond // every registration is overdue, so each check confirms by PID
+	am.election.leader.Store(true)
+	for _, name := range names {
+		am.state.putDefinition(&AgentDefinition{Name: name, Directory: directory, Type: PersistentAgent})
+	}
+
+	t.Cleanup(func() {
+		// Automatic restarts scheduled by checkAgentHealth give up once the manager is not leader
+		am.election.leader.Store(false)
+		for name, process := range am.state.listManaged() {
+			am.stopAgentProcess(name, process)
+		}
+		am.RedisClient.Close()
+	})
+	return am
+}
+
+// startFakeAgent starts name through the request path and returns the PID it was launched with
+func startFakeAgent(t *testing.T, am *AgentManager, name string) int {
+	t.Helper()
+	am.handleStartAgent(AgentRequest{
+		RequestType: "start_agent",
+		AgentName:   name,
+		SessionData: map[string]interface{}{"dependency_check": false},
+	})
+	process, ok := am.state.getManaged(name)
+	if !ok || !process.Running {
+		t.Errorf("%s: not running after start_agent", name)
+		return 0
+	}
+	return process.PID
+}
+
+// countEvents counts lifecycle events of each type in the stream
+func countEvents(t *testing.T, am *AgentManager) map[string]int {
+	t.Helper()
+	messages, err := am.RedisClient.XRange(am.ctx, lifecycleStream, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, message := range messages {
+		eventType, _ := message.Values["type"].(string)
+		counts[eventType]++
+	}
+	return counts
+}
+
+// Start, register, heartbeat, crash and stop real agent processes from many goroutines while the
+// health check and readers run. Run with -race.
+func TestManagerConcurrentAgentLifecycle(t *testing.T) {
+	const (
+		agents = 3
+		rounds = 4
+	)
+	names := make([]string, agents)
+	for i := range names {
+		names[i] = fmt.Sprintf("AGT-FAKE-%d", i)
+	}
+	am := newTestManager(t, names...)
+
+	done := make(chan struct{})
+	var background sync.WaitGroup
+
+	// Health checks and readers run for the whole test
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			am.checkAgentHealth()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	go func() {
+		defer background.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			am.handleListAgents(AgentRequest{RequestType: "list_agents"})
+			for name, process := range am.state.listRunning() {
+				am.getAgentServiceInfo(name, &process)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		name := name
+		pids := make(chan int, rounds)
+
+		// Heartbeats for whichever instance registered last
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pid := 0
+			for {
+				select {
+				case next, ok := <-pids:
+					if !ok {
+						return
+					}
+					pid = next
+				default:
+				}
+				am.handleHeartbeat(AgentRequest{
+					RequestType: "heartbeat",
+					AgentName:   name,
+					SessionData: map[string]interface{}{"pid": pid},
+				})
+				time.Sleep(2 * time.Millisecond)
+			}
+		}()
+
+		// Lifecycle: start, self-register, then either crash or get stopped
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(pids)
+			for n := 0; n < rounds; n++ {
+				pid := startFakeAgent(t, am, name)
+				if pid == 0 {
+					return
+				}
+				am.handleRegisterRunning(AgentRequest{
+					RequestType: "register_running",
+					AgentName:   name,
+					SessionData: map[string]interface{}{"pid": pid, "host": am.hostname},
+				})
+				pids <- pid
+				time.Sleep(20 * time.Millisecond)
+
+				if n%2 == 0 {
+					// Crash: the monitor reaps it and the health check drops the registration
+					syscall.Kill(pid, syscall.SIGKILL)
+					deadline := time.Now().Add(5 * time.Second)
+					for time.Now().Before(deadline) {
+						if process, ok := am.state.getManaged(name); !ok || process.PID != pid || !process.Running {
+							break
+						}
+						time.Sleep(5 * time.Millisecond)
+					}
+				} else {
+					am.handleStopAgent(AgentRequest{RequestType: "stop_agent", AgentName: name})
+				}
+				am.handleUnregisterRunning(AgentRequest{
+					RequestType: "unregister_running",
+					AgentName:   name,
+					SessionData: map[string]interface{}{"pid": pid},
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	background.Wait()
+
+	for _, name := range names {
+		if process, ok := am.state.getManaged(name); ok && process.Running {
+			t.Errorf("%s: managed PID %d still marked running after its last stop", name, process.PID)
+		}
+		if process, ok := am.state.getRunning(name); ok {
+			t.Errorf("%s: registration for PID %d outlived the process", name, process.PID)
+		}
+	}
+
+	// Every launched process was reaped by its monitor
+	counts := countEvents(t, am)
+	if want := agents * rounds; counts[string(EventAgentStarted)] != want || counts[string(EventAgentExited)] != want {
+		t.Errorf("got %d agent_started and %d agent_exited events, want %d of each",
+			counts[string(EventAgentStarted)], counts[string(EventAgentExited)], want)
+	}
+}
+
+// A crashed agent's registration is dropped by the health check, but a registration for a newer
+// instance of the same agent is kept.
+func TestManagerHealthCheckDropsOnlyDeadInstance(t *testing.T) {
+	name := "AGT-FAKE-CRASH"
+	am := newTestManager(t, name)
+	if am.hostname == "" {
+		t.Skip("hostname unknown; registrations cannot be checked by PID")
+	}
+
+	oldPID := startFakeAgent(t, am, name)
+	am.handleRegisterRunning(AgentRequest{
+		RequestType: "register_running",
+		AgentName:   name,
+		SessionData: map[string]interface{}{"pid": oldPID, "host": am.hostname},
+	})
+	old, _ := am.state.getManaged(name)
+	syscall.Kill(oldPID, syscall.SIGKILL)
+	<-old.exited
+
+	if process, ok := am.state.getManaged(name); !ok || process.Running {
+		t.Fatalf("monitor did not mark PID %d stopped", oldPID)
+	}
+
+	// The replacement starts and registers while the health check runs concurrently
+	var wg sync.WaitGroup
+	var newPID int
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		newPID = startFakeAgent(t, am, name)
+		am.handleRegisterRunning(AgentRequest{
+			RequestType: "register_running",
+			AgentName:   name,
+			SessionData: map[string]interface{}{"pid": newPID, "host": am.hostname},
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 20; n++ {
+			am.checkAgentHealth()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	wg.Wait()
+	am.checkAgentHealth()
+
+	// PIDs on this host are checked directly, so the live replacement keeps its registration
+	if process, ok := am.state.getRunning(name); !ok || process.PID != newPID {
+		t.Errorf("registration is %+v (ok=%t), want PID %d", process, ok, newPID)
+	}
+}
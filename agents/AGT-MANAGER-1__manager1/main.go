@@ -4,14 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 	
+	"centerfire/shared/config"
 	"github.com/gorilla/mux"
 	"github.com/redis/go-redis/v9"
 )
@@ -20,19 +23,25 @@ type AgentManager struct {
 	AgentID     string
 	RedisClient *redis.Client
 	ctx         context.Context
-	agents      map[string]*AgentProcess
+	state       *agentState // Guarded agents, running agents and registry maps
 	managerID   string // Unique manager instance ID
-	agentRegistry map[string]*AgentDefinition // Agent registry for ephemeral lifecycle
-	runningAgents map[string]*AgentProcess // PID-based tracking of running agents
+	hostname    string // Host this manager runs on; PIDs are only checked for agents on it
 	heartbeatInterval time.Duration // How often to expect heartbeats
 	heartbeatTimeout  time.Duration // When to consider an agent dead
 	httpServer *http.Server // HTTP server for service discovery
+	probes     *probeRegistry // Readiness/liveness probe results per agent
+	election   leaderElection // Redis lease; only the leader starts/restarts agents
+	tasks      *taskPools     // Ephemeral task queue workers per agent type
+	draining   atomic.Bool    // Set on shutdown so task workers stop leasing
+	rollouts   *rolloutTracker // In-progress blue/green restarts
+	endpoints  *config.Config  // Layered endpoint settings (see --print-config)
 }
 
 type AgentProcess struct {
 	Name         string
 	Directory    string
 	Process      *exec.Cmd
+	Host         string    // host the PID belongs to; empty when unknown
 	PID          int       // Process ID for monitoring
 	Running      bool
 	StartTime    time.Time
@@ -40,6 +49,10 @@ type AgentProcess struct {
 	SessionID    string
 	AgentType    AgentType // persistent or ephemeral
 	TaskID       string    // for ephemeral agents
+	ControlChannel string  // per-process control channel of agents on the shared runtime, from register_running
+	Cgroup       string    // cgroup v2 path when limits are enforced there
+	InstanceID   string    // field in centerfire:agents:active:<agent> for this process
+	exited       chan struct{} // closed by the monitor once the process has been reaped
 }
 
 type AgentType string
@@ -57,8 +70,11 @@ type AgentDefinition struct {
 	Description string    `json:"description"`
 	AutoShutdown bool     `json:"auto_shutdown"` // for ephemeral agents
 	MaxRuntime  int64     `json:"max_runtime"`   // seconds, 0 = unlimited
+	PoolSize    int       `json:"pool_size,omitempty"`    // ephemeral task workers, 0 = 1
+	MaxAttempts int       `json:"max_attempts,omitempty"` // runs per task when the agent crashes, 0 = 3
 	Dependencies []ServiceDependency `json:"dependencies"` // service dependencies
 	HealthCheck  *HealthCheckConfig   `json:"health_check,omitempty"` // health validation
+	Sandbox      *SandboxConfig       `json:"sandbox,omitempty"`      // resource limits and isolation
 }
 
 type ServiceDependency struct {
@@ -71,10 +87,14 @@ type ServiceDependency struct {
 }
 
 type HealthCheckConfig struct {
+	Type        string `json:"type,omitempty"`     // "exec", "http" or "redis" (default: exec, or http when url is set)
 	Command     string `json:"command"`      // health check command
+	URL         string `json:"url,omitempty"`      // http probe target
+	Endpoint    string `json:"endpoint,omitempty"` // redis probe address, defaults to manager's Redis
 	Interval    int    `json:"interval"`     // seconds between health checks
 	Timeout     int    `json:"timeout"`      // seconds before timeout
 	Retries     int    `json:"retries"`      // number of failed checks before unhealthy
+	StartPeriod int    `json:"start_period,omitempty"` // seconds after start during which failures keep the agent "starting"
 }
 
 type AgentRequest struct {
@@ -87,28 +107,36 @@ type AgentRequest struct {
 	AgentDef    *AgentDefinition       `json:"agent_def,omitempty"`    // for registering agents
 	DependencyCheck bool               `json:"dependency_check,omitempty"` // validate dependencies
 	ForceRestart    bool               `json:"force_restart,omitempty"`    // ignore dependency failures
+	RequestID       string             `json:"request_id,omitempty"`       // echoed in the reply for correlation
+	ResponseChannel string             `json:"response_channel,omitempty"` // reply here instead of the shared responses channel
+
+	replyTo chan map[string]interface{} // in-process reply sink for REST callers
 }
 
-func NewAgentManager() *AgentManager {
+func NewAgentManager(endpoints *config.Config) *AgentManager {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
 
-	// Generate unique manager ID for instance tracking
-	managerID := fmt.Sprintf("manager-%d", time.Now().Unix())
+	// Generate unique manager ID for instance tracking and leader election
+	hostname := localHostname()
+	managerID := newManagerID(hostname)
 
 	am := &AgentManager{
 		AgentID:     "AGT-MANAGER-1",
 		RedisClient: rdb,
 		ctx:         context.Background(),
-		agents:      make(map[string]*AgentProcess),
+		state:       newAgentState(),
 		managerID:   managerID,
-		agentRegistry: make(map[string]*AgentDefinition),
-		runningAgents: make(map[string]*AgentProcess),
+		hostname:    hostname,
 		heartbeatInterval: 30 * time.Second, // Expect heartbeat every 30 seconds
 		heartbeatTimeout:  90 * time.Second, // Consider dead after 90 seconds
+		probes:            newProbeRegistry(),
+		tasks:             newTaskPools(),
+		rollouts:          newRolloutTracker(),
+		endpoints:         endpoints,
 	}
 	
 	// Initialize agent registry with known agents
@@ -129,6 +157,10 @@ func (am *AgentManager) Start() {
 	}
 	fmt.Println("Connected to Redis successfully")
 
+	// Campaign for leadership before acting on any request
+	am.startLeaderElection()
+	fmt.Printf("%s: Manager %s running as %s\n", am.AgentID, am.managerID, am.role())
+
 	// Start HTTP server for service discovery
 	am.startHTTPServer()
 
@@ -139,12 +171,18 @@ func (am *AgentManager) Start() {
 	// Start heartbeat monitoring
 	am.startHeartbeatMonitor()
 
+	// Start readiness/liveness probes from each agent's HealthCheckConfig
+	am.startProbeRunner()
+
+	// Start worker pools consuming each ephemeral agent's task stream
+	am.startTaskWorkers()
+
 	// Set up graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	fmt.Printf("%s ready - listening for agent management requests\n", am.AgentID)
-	fmt.Printf("%s HTTP discovery service: http://localhost:8380/api/services\n", am.AgentID)
+	fmt.Printf("%s HTTP discovery service: http://%s/api/services\n", am.AgentID, am.endpoints.Get(config.ManagerAddr))
 
 	for {
 		select {
@@ -174,10 +212,42 @@ func (am *AgentManager) processRequest(payload string) {
 	var request AgentRequest
 	if err := json.Unmarshal([]byte(payload), &request); err != nil {
 		fmt.Printf("Error parsing request: %v\n", err)
+		am.replyToUnparseable(payload, err)
 		return
 	}
 
+	am.dispatchRequest(request)
+}
+
+// dispatchRequest routes a parsed request to its handler; shared by pub/sub and the REST API
+func (am *AgentManager) dispatchRequest(request AgentRequest) {
+	// Followers only mirror registrations for read-only discovery; the leader answers and acts
+	if !am.isLeader() {
+		switch {
+		case followerReplicatedRequests[request.RequestType]:
+		case followerReadOnlyRequests[request.RequestType] && request.replyTo != nil:
+			// Direct (REST) reads are served locally; over pub/sub the leader answers
+		case request.RequestType == "register_agent" && request.AgentDef != nil:
+			am.state.putDefinition(request.AgentDef)
+			return
+		default:
+			fmt.Printf("%s: Follower ignoring %s (leader: %s)\n", am.AgentID, request.RequestType, am.currentLeader())
+			if request.replyTo != nil {
+				// Direct callers (REST) get told where the leader is; pub/sub callers hear from the leader itself
+				am.replyError(request, ErrCodeNotLeader, "this manager is a follower", map[string]interface{}{
+					"leader": am.currentLeader(),
+				})
+			}
+			return
+		}
+	}
+
 	switch request.RequestType {
+	case "ping":
+		am.reply(request, map[string]interface{}{
+			"status": "ok",
+			"role":   am.role(),
+		})
 	case "restart_agent":
 		am.handleRestartAgent(request)
 	case "stop_agent":
@@ -204,6 +274,8 @@ func (am *AgentManager) processRequest(payload string) {
 		am.handleRegisterAgent(request)
 	case "spawn_ephemeral":
 		am.handleSpawnEphemeral(request)
+	case "task_status":
+		am.handleTaskStatus(request)
 	case "list_registry":
 		am.handleListRegistry(request)
 	case "get_agent_definition":
@@ -216,6 +288,7 @@ func (am *AgentManager) processRequest(payload string) {
 		am.handleRestartWithDependencies(request)
 	default:
 		fmt.Printf("Unknown request type: %s\n", request.RequestType)
+		am.replyError(request, ErrCodeUnknownRequestType, fmt.Sprintf("Unknown request type: %s", request.RequestType), nil)
 	}
 }
 
@@ -223,24 +296,51 @@ func (am *AgentManager) handleRestartAgent(request AgentRequest) {
 	agentName := request.AgentName
 	fmt.Printf("%s: Restarting agent %s\n", am.AgentID, agentName)
 
+	if am.rejectDuringRollout(request) {
+		return
+	}
+
+	// Persistent agents that are up get a blue/green restart so requests never hit a gap. The
+	// hand-over needs the running instance's control channel, which only the shared runtime offers.
+	agentDef, registered := am.state.getDefinition(agentName)
+	blue, managed := am.state.getManaged(agentName)
+	running, _ := am.state.getRunning(agentName)
+	controllable := running.PID == blue.PID && running.ControlChannel != ""
+	if registered && managed && blue.Running && controllable && agentDef.Type == PersistentAgent && request.Action != "recreate" {
+		rollout, started := am.rollouts.begin(agentName, blue.PID)
+		if !started {
+			am.replyError(request, ErrCodeRolloutInProgress, fmt.Sprintf("agent %s already has a rollout in progress", agentName), map[string]interface{}{
+				"rollout": rollout,
+			})
+			return
+		}
+		go am.rollingRestart(request, agentDef, blue)
+		am.reply(request, map[string]interface{}{
+			"status":  "rolling",
+			"agent":   agentName,
+			"rollout": rollout,
+		})
+		return
+	}
+
+	unlock := am.state.lockAgent(agentName)
+	defer unlock()
+
 	// Stop existing agent
-	if process, exists := am.agents[agentName]; exists {
-		am.stopAgentProcess(process)
+	if process, exists := am.state.getManaged(agentName); exists {
+		am.stopAgentProcess(agentName, process)
 	}
 
 	// Start agent with session awareness
 	if err := am.startAgent(agentName, request.SessionData); err != nil {
 		fmt.Printf("Error restarting %s: %v\n", agentName, err)
-		am.publishResponse(map[string]interface{}{
-			"status": "error",
-			"agent":  agentName,
-			"error":  err.Error(),
-		})
+		am.replyError(request, errorCode(err), err.Error(), nil)
 		return
 	}
 
 	fmt.Printf("%s: Agent %s restarted successfully\n", am.AgentID, agentName)
-	am.publishResponse(map[string]interface{}{
+	am.emitEvent(LifecycleEvent{Type: EventAgentRestarted, Agent: agentName, Reason: "requested"})
+	am.reply(request, map[string]interface{}{
 		"status": "restarted",
 		"agent":  agentName,
 	})
@@ -250,16 +350,30 @@ func (am *AgentManager) handleStopAgent(request AgentRequest) {
 	agentName := request.AgentName
 	fmt.Printf("%s: Stopping agent %s\n", am.AgentID, agentName)
 
-	if process, exists := am.agents[agentName]; exists {
-		am.stopAgentProcess(process)
-		delete(am.agents, agentName)
+	if am.rejectDuringRollout(request) {
+		return
+	}
+
+	unlock := am.state.lockAgent(agentName)
+	defer unlock()
+
+	if process, exists := am.state.getManaged(agentName); exists {
+		am.stopAgentProcess(agentName, process)
+		am.state.removeManagedIf(agentName, process.PID)
+		am.emitEvent(LifecycleEvent{
+			Type:     EventAgentStopped,
+			Agent:    agentName,
+			Instance: process.InstanceID,
+			PID:      process.PID,
+			Reason:   "requested",
+		})
 		
-		am.publishResponse(map[string]interface{}{
+		am.reply(request, map[string]interface{}{
 			"status": "stopped",
 			"agent":  agentName,
 		})
 	} else {
-		am.publishResponse(map[string]interface{}{
+		am.reply(request, map[string]interface{}{
 			"status": "not_found",
 			"agent":  agentName,
 		})
@@ -270,16 +384,19 @@ func (am *AgentManager) handleStartAgent(request AgentRequest) {
 	agentName := request.AgentName
 	fmt.Printf("%s: Starting agent %s\n", am.AgentID, agentName)
 
+	if am.rejectDuringRollout(request) {
+		return
+	}
+
+	unlock := am.state.lockAgent(agentName)
+	defer unlock()
+
 	if err := am.startAgent(agentName, request.SessionData); err != nil {
-		am.publishResponse(map[string]interface{}{
-			"status": "error",
-			"agent":  agentName,
-			"error":  err.Error(),
-		})
+		am.replyError(request, errorCode(err), err.Error(), nil)
 		return
 	}
 
-	am.publishResponse(map[string]interface{}{
+	am.reply(request, map[string]interface{}{
 		"status": "started",
 		"agent":  agentName,
 	})
@@ -288,7 +405,7 @@ func (am *AgentManager) handleStartAgent(request AgentRequest) {
 func (am *AgentManager) handleListAgents(request AgentRequest) {
 	agents := make([]map[string]interface{}, 0)
 	
-	for name, process := range am.agents {
+	for name, process := range am.state.listManaged() {
 		agents = append(agents, map[string]interface{}{
 			"name":       name,
 			"running":    process.Running,
@@ -299,7 +416,7 @@ func (am *AgentManager) handleListAgents(request AgentRequest) {
 		})
 	}
 
-	am.publishResponse(map[string]interface{}{
+	am.reply(request, map[string]interface{}{
 		"status": "ok",
 		"agents": agents,
 	})
@@ -307,8 +424,8 @@ func (am *AgentManager) handleListAgents(request AgentRequest) {
 
 func (am *AgentManager) handleAgentStatus(request AgentRequest) {
 	agentName := request.AgentName
-	if process, exists := am.agents[agentName]; exists {
-		am.publishResponse(map[string]interface{}{
+	if process, exists := am.state.getManaged(agentName); exists {
+		am.reply(request, map[string]interface{}{
 			"status":     "ok",
 			"agent":      agentName,
 			"running":    process.Running,
@@ -316,9 +433,11 @@ func (am *AgentManager) handleAgentStatus(request AgentRequest) {
 			"session_id": process.SessionID,
 			"type":       process.AgentType,
 			"task_id":    process.TaskID,
+			"pid":        process.PID,
+			"rollout":    am.rolloutStatus(agentName),
 		})
 	} else {
-		am.publishResponse(map[string]interface{}{
+		am.reply(request, map[string]interface{}{
 			"status": "not_found",
 			"agent":  agentName,
 		})
@@ -348,7 +467,7 @@ func (am *AgentManager) handleCheckCollisions(request AgentRequest) {
 		}
 	}
 
-	am.publishResponse(map[string]interface{}{
+	am.reply(request, map[string]interface{}{
 		"status":     "ok",
 		"collisions": collisions,
 		"manager_id": am.managerID,
@@ -363,31 +482,33 @@ func (am *AgentManager) handleCheckAgentCollision(request AgentRequest) {
 	singletonAgents := am.getSingletonAgents()
 	collision := false
 	
-	if singletonAgents[agentName] {
+	if _, rolling := am.rollouts.get(agentName); rolling {
+		// The replacement instance of a blue/green restart is expected to overlap the old one
+		fmt.Printf("%s: %s is mid-rollout, allowing overlapping instance\n", am.AgentID, agentName)
+	} else if singletonAgents[agentName] {
 		// Check if agent is registered and validate PID
-		if agentProcess, exists := am.runningAgents[agentName]; exists {
-			// Validate that the PID is still running
-			if am.isProcessRunning(agentProcess.PID) {
+		if agentProcess, exists := am.state.getRunning(agentName); exists {
+			// Validate that the PID is still running (or, off this host, still heartbeating)
+			if am.agentAlive(agentProcess) {
 				collision = true
 				fmt.Printf("%s: Collision detected for %s (PID %d still running)\n", am.AgentID, agentName, agentProcess.PID)
 			} else {
 				// Process is dead, clean up stale registration
 				fmt.Printf("%s: Cleaning up stale registration for %s (PID %d not running)\n", am.AgentID, agentName, agentProcess.PID)
-				delete(am.runningAgents, agentName)
+				am.state.removeRunningIf(agentName, agentProcess.PID)
 			}
 		}
 	}
 	
-	// Send response to specific agent's response channel
-	responseChannel := fmt.Sprintf("centerfire:agent:manager:response:%s", agentName)
-	response := map[string]interface{}{
-		"collision":  collision,
-		"agent":      agentName,
-		"manager_id": am.managerID,
+	// Send response to the caller's channel, defaulting to the agent's own response channel
+	if request.ResponseChannel == "" {
+		request.ResponseChannel = fmt.Sprintf("centerfire:agent:manager:response:%s", agentName)
 	}
-	
-	responseData, _ := json.Marshal(response)
-	am.RedisClient.Publish(am.ctx, responseChannel, string(responseData))
+	am.reply(request, map[string]interface{}{
+		"status":    "ok",
+		"collision": collision,
+		"agent":     agentName,
+	})
 }
 
 // handleRegisterRunning - Register agent as running with PID tracking
@@ -395,68 +516,123 @@ func (am *AgentManager) handleRegisterRunning(request AgentRequest) {
 	agentName := request.AgentName
 	
 	// Extract PID from request data
-	pid := 0
-	if request.SessionData != nil {
-		if p, ok := request.SessionData["pid"].(float64); ok {
-			pid = int(p)
-		} else if p, ok := request.SessionData["pid"].(int); ok {
-			pid = p
-		}
-	}
+	pid := pidFromRecord(request.SessionData)
 	
 	fmt.Printf("%s: Registering %s as running (PID: %d)\n", am.AgentID, agentName, pid)
+
+	// A blue/green replacement registers before it is switched in; hold it until it is ready
+	if am.rollouts.observeRegistration(agentName, pid, request.SessionData) {
+		if request.ResponseChannel != "" || request.RequestID != "" {
+			am.reply(request, map[string]interface{}{
+				"status":  "registered",
+				"agent":   agentName,
+				"pid":     pid,
+				"rollout": RolloutWaiting,
+			})
+		}
+		return
+	}
 	
 	// Create agent process record
-	am.runningAgents[agentName] = &AgentProcess{
-		Name:          agentName,
-		PID:           pid,
-		Running:       true,
-		StartTime:     time.Now(),
-		LastHeartbeat: time.Now(),
-		AgentType:     PersistentAgent, // Assume persistent for externally started agents
-	}
+	host, _ := request.SessionData["host"].(string)
+	control, _ := request.SessionData["control"].(string)
+	am.state.putRunning(agentName, &AgentProcess{
+		Name:           agentName,
+		Host:           host,
+		PID:            pid,
+		Running:        true,
+		StartTime:      time.Now(),
+		LastHeartbeat:  time.Now(),
+		AgentType:      PersistentAgent, // Assume persistent for externally started agents
+		ControlChannel: control,
+	})
 	
+	// Probe from a clean "starting" state for the new instance
+	if agentDef, exists := am.state.getDefinition(agentName); exists && agentDef.HealthCheck != nil {
+		am.probes.reset(agentName, agentDef.HealthCheck.probeType())
+	}
+
 	// Store full session data in Redis for persistence across manager restarts
-	am.storeAgentInRedis(agentName, request.SessionData)
+	if am.isLeader() {
+		am.storeAgentInRedis(agentName, request.SessionData)
+		am.emitEvent(LifecycleEvent{Type: EventAgentRegistered, Agent: agentName, PID: pid, Reason: "self_registered"})
+
+		// Acknowledge callers that asked for a reply
+		if request.ResponseChannel != "" || request.RequestID != "" {
+			am.reply(request, map[string]interface{}{
+				"status": "registered",
+				"agent":  agentName,
+				"pid":    pid,
+			})
+		}
+	}
 }
 
 // handleUnregisterRunning - Unregister agent from running state
 func (am *AgentManager) handleUnregisterRunning(request AgentRequest) {
 	agentName := request.AgentName
 	fmt.Printf("%s: Unregistering %s from running state\n", am.AgentID, agentName)
-	delete(am.runningAgents, agentName)
+
+	pid := pidFromRecord(request.SessionData)
+	if pid != 0 {
+		// Only the instance that registered may unregister
+		if !am.state.removeRunningIf(agentName, pid) {
+			return
+		}
+	} else if am.rollouts.ignoresUnregister(agentName) {
+		// The draining instance of a blue/green restart must not unregister its replacement
+		fmt.Printf("%s: Ignoring unregister of %s during drain\n", am.AgentID, agentName)
+		return
+	} else {
+		am.state.removeRunning(agentName)
+	}
+	am.emitEvent(LifecycleEvent{Type: EventAgentUnregistered, Agent: agentName, PID: pid, Reason: "self_unregistered"})
 }
 
 func (am *AgentManager) handleSessionRestore(request AgentRequest) {
-	sessionID := request.SessionData["session_id"].(string)
+	sessionID, _ := request.SessionData["session_id"].(string)
+	if sessionID == "" {
+		am.replyError(request, ErrCodeInvalidRequest, "session_data.session_id is required", nil)
+		return
+	}
 	fmt.Printf("%s: Restoring session %s\n", am.AgentID, sessionID)
 
 	// Get session data from Redis
 	sessionKey := fmt.Sprintf("centerfire.dev.sessions:%s", sessionID)
 	sessionJSON, err := am.RedisClient.Get(am.ctx, sessionKey).Result()
 	if err != nil {
-		am.publishResponse(map[string]interface{}{
-			"status":     "error",
+		am.replyError(request, ErrCodeSessionNotFound, "Session not found", map[string]interface{}{
 			"session_id": sessionID,
-			"error":      "Session not found",
 		})
 		return
 	}
 
 	var sessionData map[string]interface{}
-	json.Unmarshal([]byte(sessionJSON), &sessionData)
+	if err := json.Unmarshal([]byte(sessionJSON), &sessionData); err != nil {
+		am.replyError(request, ErrCodeInvalidRequest, fmt.Sprintf("session %s is not valid JSON: %v", sessionID, err), nil)
+		return
+	}
 
 	// Restart agents with session context
-	agentList := sessionData["agents"].([]interface{})
+	agentList, ok := sessionData["agents"].([]interface{})
+	if !ok {
+		am.replyError(request, ErrCodeInvalidRequest, fmt.Sprintf("session %s has no agents list", sessionID), nil)
+		return
+	}
 	for _, agent := range agentList {
-		agentName := agent.(string)
+		agentName, ok := agent.(string)
+		if !ok || agentName == "" {
+			continue
+		}
+		unlock := am.state.lockAgent(agentName)
 		am.startAgent(agentName, map[string]interface{}{
 			"session_id":      sessionID,
 			"restore_context": true,
 		})
+		unlock()
 	}
 
-	am.publishResponse(map[string]interface{}{
+	am.reply(request, map[string]interface{}{
 		"status":     "restored",
 		"session_id": sessionID,
 		"agents":     agentList,
@@ -465,8 +641,12 @@ func (am *AgentManager) handleSessionRestore(request AgentRequest) {
 
 // Agent Registry Management
 func (am *AgentManager) initializeAgentRegistry() {
+	redisAddr := am.endpoints.Get(config.RedisAddr)
+	weaviateURL := am.endpoints.Get(config.WeaviateURL)
+	redisHost, redisPort, _ := net.SplitHostPort(redisAddr)
+
 	// Register known persistent agents
-	am.agentRegistry["AGT-NAMING-2"] = &AgentDefinition{
+	am.state.putDefinition(&AgentDefinition{
 		Name:        "AGT-NAMING-2",
 		Directory:   "/Users/larrydiffey/projects/CenterfireIntelligence/agents/AGT-NAMING-2__naming2",
 		Type:        PersistentAgent,
@@ -475,17 +655,20 @@ func (am *AgentManager) initializeAgentRegistry() {
 		AutoShutdown: false,
 		MaxRuntime:  0, // unlimited
 		Dependencies: []ServiceDependency{
-			{Service: "redis", Type: "infrastructure", Endpoint: "localhost:6380", Critical: true, RetryCount: 3, RetryDelay: 5},
+			{Service: "redis", Type: "infrastructure", Endpoint: redisAddr, Critical: true, RetryCount: 3, RetryDelay: 5},
 		},
 		HealthCheck: &HealthCheckConfig{
-			Command: "redis-cli -h localhost -p 6380 ping",
+			Type:     ProbeRedis,
+			Command:  fmt.Sprintf("redis-cli -h %s -p %s ping", redisHost, redisPort),
+			Endpoint: redisAddr,
 			Interval: 30,
 			Timeout: 5,
 			Retries: 3,
+			StartPeriod: 30,
 		},
-	}
+	})
 	
-	am.agentRegistry["AGT-SEMANTIC-1"] = &AgentDefinition{
+	am.state.putDefinition(&AgentDefinition{
 		Name:        "AGT-SEMANTIC-1",
 		Directory:   "/Users/larrydiffey/projects/CenterfireIntelligence/agents/AGT-SEMANTIC-1__01K4EAF1",
 		Type:        PersistentAgent,
@@ -494,19 +677,21 @@ func (am *AgentManager) initializeAgentRegistry() {
 		AutoShutdown: false,
 		MaxRuntime:  0,
 		Dependencies: []ServiceDependency{
-			{Service: "redis", Type: "infrastructure", Endpoint: "localhost:6380", Critical: true, RetryCount: 3, RetryDelay: 5},
-			{Service: "weaviate", Type: "infrastructure", Endpoint: "localhost:8080", Critical: true, RetryCount: 3, RetryDelay: 10},
+			{Service: "redis", Type: "infrastructure", Endpoint: redisAddr, Critical: true, RetryCount: 3, RetryDelay: 5},
+			{Service: "weaviate", Type: "infrastructure", Endpoint: weaviateURL, Critical: true, RetryCount: 3, RetryDelay: 10},
 			{Service: "AGT-NAMING-1", Type: "agent", Endpoint: "centerfire:agent:naming", Critical: true, RetryCount: 2, RetryDelay: 3},
 		},
 		HealthCheck: &HealthCheckConfig{
-			Command: "curl -s http://localhost:8080/v1/meta",
+			Type:     ProbeHTTP,
+			URL:      strings.TrimRight(weaviateURL, "/") + "/v1/meta",
 			Interval: 60,
 			Timeout: 10,
 			Retries: 2,
+			StartPeriod: 60,
 		},
-	}
+	})
 	
-	am.agentRegistry["AGT-STRUCT-2"] = &AgentDefinition{
+	am.state.putDefinition(&AgentDefinition{
 		Name:        "AGT-STRUCT-2",
 		Directory:   "/Users/larrydiffey/projects/CenterfireIntelligence/agents/AGT-STRUCT-2__struct2",
 		Type:        PersistentAgent,
@@ -515,12 +700,12 @@ func (am *AgentManager) initializeAgentRegistry() {
 		AutoShutdown: false,
 		MaxRuntime:  0,
 		Dependencies: []ServiceDependency{
-			{Service: "redis", Type: "infrastructure", Endpoint: "localhost:6380", Critical: true, RetryCount: 3, RetryDelay: 5},
+			{Service: "redis", Type: "infrastructure", Endpoint: redisAddr, Critical: true, RetryCount: 3, RetryDelay: 5},
 			{Service: "AGT-NAMING-2", Type: "agent", Endpoint: "centerfire:agent:naming", Critical: true, RetryCount: 2, RetryDelay: 3},
 		},
-	}
+	})
 	
-	am.agentRegistry["AGT-MANAGER-1"] = &AgentDefinition{
+	am.state.putDefinition(&AgentDefinition{
 		Name:        "AGT-MANAGER-1",
 		Directory:   "/Users/larrydiffey/projects/CenterfireIntelligence/agents/AGT-MANAGER-1__manager1",
 		Type:        PersistentAgent,
@@ -529,12 +714,12 @@ func (am *AgentManager) initializeAgentRegistry() {
 		AutoShutdown: false,
 		MaxRuntime:  0,
 		Dependencies: []ServiceDependency{
-			{Service: "redis", Type: "infrastructure", Endpoint: "localhost:6380", Critical: true, RetryCount: 5, RetryDelay: 3},
+			{Service: "redis", Type: "infrastructure", Endpoint: redisAddr, Critical: true, RetryCount: 5, RetryDelay: 3},
 		},
-	}
+	})
 	
 	// Add AGT-STACK-1 for container orchestration
-	am.agentRegistry["AGT-STACK-1"] = &AgentDefinition{
+	am.state.putDefinition(&AgentDefinition{
 		Name:        "AGT-STACK-1",
 		Directory:   "/Users/larrydiffey/projects/CenterfireIntelligence/agents/AGT-STACK-1__stack1",
 		Type:        PersistentAgent,
@@ -543,19 +728,20 @@ func (am *AgentManager) initializeAgentRegistry() {
 		AutoShutdown: false,
 		MaxRuntime:  0,
 		Dependencies: []ServiceDependency{
-			{Service: "redis", Type: "infrastructure", Endpoint: "localhost:6380", Critical: true, RetryCount: 3, RetryDelay: 5},
+			{Service: "redis", Type: "infrastructure", Endpoint: redisAddr, Critical: true, RetryCount: 3, RetryDelay: 5},
 			{Service: "docker", Type: "infrastructure", Endpoint: "docker ps", Critical: true, RetryCount: 3, RetryDelay: 5},
 		},
 		HealthCheck: &HealthCheckConfig{
-			Command: "docker ps --format 'table {{.Names}}'\t{{.Status}}'",
+			Type:     ProbeExec,
+			Command: "docker ps --format '{{.Names}}\t{{.Status}}'",
 			Interval: 60,
 			Timeout: 10,
 			Retries: 2,
 		},
-	}
+	})
 	
 	// Register known ephemeral agents
-	am.agentRegistry["AGT-CLEANUP-1"] = &AgentDefinition{
+	am.state.putDefinition(&AgentDefinition{
 		Name:        "AGT-CLEANUP-1",
 		Directory:   "/Users/larrydiffey/projects/CenterfireIntelligence/agents/AGT-CLEANUP-1__17571335",
 		Type:        EphemeralAgent,
@@ -564,12 +750,17 @@ func (am *AgentManager) initializeAgentRegistry() {
 		AutoShutdown: true,
 		MaxRuntime:  300, // 5 minutes max runtime
 		Dependencies: []ServiceDependency{
-			{Service: "weaviate", Type: "infrastructure", Endpoint: "localhost:8080", Critical: true, RetryCount: 2, RetryDelay: 10},
-			{Service: "neo4j", Type: "infrastructure", Endpoint: "localhost:7474", Critical: false, RetryCount: 2, RetryDelay: 5},
+			{Service: "weaviate", Type: "infrastructure", Endpoint: weaviateURL, Critical: true, RetryCount: 2, RetryDelay: 10},
+			{Service: "neo4j", Type: "infrastructure", Endpoint: am.endpoints.Get(config.Neo4jHTTPURL), Critical: false, RetryCount: 2, RetryDelay: 5},
 		},
-	}
+		Sandbox: &SandboxConfig{
+			MaxCPUSeconds: 300,
+			MaxMemoryMB:   512,
+			MaxOpenFiles:  256,
+		},
+	})
 	
-	am.agentRegistry["AGT-SEMDOC-1"] = &AgentDefinition{
+	am.state.putDefinition(&AgentDefinition{
 		Name:        "AGT-SEMDOC-1",
 		Directory:   "/Users/larrydiffey/projects/CenterfireIntelligence/agents/AGT-SEMDOC-1__01K4EAF1",
 		Type:        EphemeralAgent,
@@ -577,9 +768,9 @@ func (am *AgentManager) initializeAgentRegistry() {
 		Description: "Documentation generation service",
 		AutoShutdown: true,
 		MaxRuntime:  600, // 10 minutes max runtime
-	}
+	})
 	
-	am.agentRegistry["AGT-CODING-1"] = &AgentDefinition{
+	am.state.putDefinition(&AgentDefinition{
 		Name:        "AGT-CODING-1",
 		Directory:   "/Users/larrydiffey/projects/CenterfireIntelligence/agents/AGT-CODING-1__01K4EAF1",
 		Type:        EphemeralAgent,
@@ -587,27 +778,32 @@ func (am *AgentManager) initializeAgentRegistry() {
 		Description: "Code generation and analysis service",
 		AutoShutdown: true,
 		MaxRuntime:  1800, // 30 minutes max runtime
-	}
+	})
 	
-	fmt.Printf("%s: Agent registry initialized with %d agent definitions\n", am.AgentID, len(am.agentRegistry))
+	fmt.Printf("%s: Agent registry initialized with %d agent definitions\n", am.AgentID, len(am.state.listDefinitions()))
 }
 
 // Agent Registry Request Handlers
 func (am *AgentManager) handleRegisterAgent(request AgentRequest) {
 	if request.AgentDef == nil {
-		am.publishResponse(map[string]interface{}{
-			"status": "error",
-			"error":  "No agent definition provided",
-		})
+		am.replyError(request, ErrCodeInvalidRequest, "No agent definition provided", nil)
 		return
 	}
 	
 	agentDef := request.AgentDef
 	fmt.Printf("%s: Registering agent %s (type: %s)\n", am.AgentID, agentDef.Name, agentDef.Type)
 	
-	am.agentRegistry[agentDef.Name] = agentDef
+	existed := am.state.putDefinition(agentDef)
+
+	// Newly registered agents with a health check get their own probe loop
+	if !existed && agentDef.HealthCheck != nil {
+		go am.probeLoop(agentDef.Name, agentDef.HealthCheck)
+	}
+
+	// Ephemeral agents get a task stream and worker pool
+	am.ensureTaskPool(agentDef)
 	
-	am.publishResponse(map[string]interface{}{
+	am.reply(request, map[string]interface{}{
 		"status":     "registered",
 		"agent_name": agentDef.Name,
 		"agent_type": agentDef.Type,
@@ -619,57 +815,49 @@ func (am *AgentManager) handleSpawnEphemeral(request AgentRequest) {
 	taskID := request.TaskID
 	
 	if taskID == "" {
-		taskID = fmt.Sprintf("task_%d", time.Now().Unix())
+		taskID = fmt.Sprintf("task_%d", time.Now().UnixNano())
 	}
 	
-	fmt.Printf("%s: Spawning ephemeral agent %s for task %s\n", am.AgentID, agentName, taskID)
+	fmt.Printf("%s: Queueing task %s for ephemeral agent %s\n", am.AgentID, taskID, agentName)
 	
 	// Check if agent is registered
-	agentDef, exists := am.agentRegistry[agentName]
+	agentDef, exists := am.state.getDefinition(agentName)
 	if !exists {
-		am.publishResponse(map[string]interface{}{
-			"status":   "error",
-			"error":    fmt.Sprintf("Agent %s not registered", agentName),
-			"task_id":  taskID,
+		am.replyError(request, ErrCodeNotRegistered, fmt.Sprintf("Agent %s not registered", agentName), map[string]interface{}{
+			"task_id": taskID,
 		})
 		return
 	}
 	
 	// Ensure agent is ephemeral
 	if agentDef.Type != EphemeralAgent {
-		am.publishResponse(map[string]interface{}{
-			"status":  "error",
-			"error":   fmt.Sprintf("Agent %s is not ephemeral (type: %s)", agentName, agentDef.Type),
+		am.replyError(request, ErrCodeNotEphemeral, fmt.Sprintf("Agent %s is not ephemeral (type: %s)", agentName, agentDef.Type), map[string]interface{}{
 			"task_id": taskID,
 		})
 		return
 	}
 	
-	// Create unique instance name for ephemeral agent
-	instanceName := fmt.Sprintf("%s_%s", agentName, taskID)
-	
-	// Start ephemeral agent
-	if err := am.startEphemeralAgent(instanceName, agentDef, taskID, request.TaskData); err != nil {
-		am.publishResponse(map[string]interface{}{
-			"status":  "error",
-			"error":   err.Error(),
+	// Queue the task; a pool worker runs it and reports the result to the submitter
+	record, err := am.enqueueTask(agentDef, request, taskID)
+	if err != nil {
+		am.replyError(request, errorCode(err), err.Error(), map[string]interface{}{
 			"task_id": taskID,
 		})
 		return
 	}
 	
-	am.publishResponse(map[string]interface{}{
-		"status":       "spawned",
-		"agent_name":   agentName,
-		"instance_name": instanceName,
-		"task_id":      taskID,
+	am.reply(request, map[string]interface{}{
+		"status":     "queued",
+		"agent_name": agentName,
+		"task_id":    taskID,
+		"task":       record,
 	})
 }
 
 func (am *AgentManager) handleListRegistry(request AgentRequest) {
 	registry := make([]map[string]interface{}, 0)
 	
-	for name, def := range am.agentRegistry {
+	for name, def := range am.state.listDefinitions() {
 		registry = append(registry, map[string]interface{}{
 			"name":         name,
 			"type":         def.Type,
@@ -677,10 +865,11 @@ func (am *AgentManager) handleListRegistry(request AgentRequest) {
 			"description":  def.Description,
 			"auto_shutdown": def.AutoShutdown,
 			"max_runtime":  def.MaxRuntime,
+			"pool_size":    def.poolSize(),
 		})
 	}
 	
-	am.publishResponse(map[string]interface{}{
+	am.reply(request, map[string]interface{}{
 		"status":   "ok",
 		"registry": registry,
 	})
@@ -688,8 +877,8 @@ func (am *AgentManager) handleListRegistry(request AgentRequest) {
 
 func (am *AgentManager) handleGetAgentDefinition(request AgentRequest) {
 	agentName := request.AgentName
-	if def, exists := am.agentRegistry[agentName]; exists {
-		am.publishResponse(map[string]interface{}{
+	if def, exists := am.state.getDefinition(agentName); exists {
+		am.reply(request, map[string]interface{}{
 			"status":       "ok",
 			"agent_name":   agentName,
 			"type":         def.Type,
@@ -697,9 +886,10 @@ func (am *AgentManager) handleGetAgentDefinition(request AgentRequest) {
 			"description":  def.Description,
 			"auto_shutdown": def.AutoShutdown,
 			"max_runtime":  def.MaxRuntime,
+			"pool_size":    def.poolSize(),
 		})
 	} else {
-		am.publishResponse(map[string]interface{}{
+		am.reply(request, map[string]interface{}{
 			"status": "not_found",
 			"agent":  agentName,
 		})
@@ -718,15 +908,25 @@ func (am *AgentManager) getSingletonAgents() map[string]bool {
 }
 
 func (am *AgentManager) isAgentRunning(agentName string) (bool, string, error) {
+	activeInstances, err := am.activeInstances(agentName)
+	if err != nil {
+		return false, "", err
+	}
+	if len(activeInstances) > 0 {
+		return true, fmt.Sprintf("Active instances: %v", activeInstances), nil
+	}
+
+	return false, "", nil
+}
+
+// activeInstances lists the instance IDs in centerfire:agents:active:<agent> with a recent
+// heartbeat, deleting the stale ones
+func (am *AgentManager) activeInstances(agentName string) ([]string, error) {
 	// Check Redis for active agent instances
 	instanceKey := fmt.Sprintf("centerfire:agents:active:%s", agentName)
 	instances, err := am.RedisClient.HGetAll(am.ctx, instanceKey).Result()
 	if err != nil && err != redis.Nil {
-		return false, "", err
-	}
-
-	if len(instances) == 0 {
-		return false, "", nil
+		return nil, err
 	}
 
 	// Check if any instances are still alive by trying to verify heartbeat
@@ -745,23 +945,24 @@ func (am *AgentManager) isAgentRunning(agentName string) (bool, string, error) {
 			}
 		}
 	}
+	return activeInstances, nil
+}
 
-	if len(activeInstances) > 0 {
-		return true, fmt.Sprintf("Active instances: %v", activeInstances), nil
-	}
-
-	return false, "", nil
+// instanceID is the field name of this manager's instance record in centerfire:agents:active:<agent>
+func (am *AgentManager) instanceID(agentName string) string {
+	return fmt.Sprintf("%s-%s", am.managerID, agentName)
 }
 
-func (am *AgentManager) registerAgentInstance(agentName string, sessionID string) error {
+func (am *AgentManager) registerAgentInstance(agentName, instanceID, sessionID string, pid int) error {
 	instanceKey := fmt.Sprintf("centerfire:agents:active:%s", agentName)
-	instanceID := fmt.Sprintf("%s-%s", am.managerID, agentName)
 	
 	instanceData := map[string]interface{}{
 		"agent_name":    agentName,
 		"instance_id":   instanceID,
 		"manager_id":    am.managerID,
 		"session_id":    sessionID,
+		"host":          am.hostname,
+		"pid":           pid,
 		"started_at":    time.Now().Unix(),
 		"heartbeat":     time.Now().Unix(),
 	}
@@ -770,15 +971,17 @@ func (am *AgentManager) registerAgentInstance(agentName string, sessionID string
 	return am.RedisClient.HSet(am.ctx, instanceKey, instanceID, string(instanceJSON)).Err()
 }
 
-func (am *AgentManager) unregisterAgentInstance(agentName string) error {
+func (am *AgentManager) unregisterAgentInstance(agentName, instanceID string) error {
 	instanceKey := fmt.Sprintf("centerfire:agents:active:%s", agentName)
-	instanceID := fmt.Sprintf("%s-%s", am.managerID, agentName)
 	return am.RedisClient.HDel(am.ctx, instanceKey, instanceID).Err()
 }
 
 func (am *AgentManager) updateHeartbeat(agentName string) error {
 	instanceKey := fmt.Sprintf("centerfire:agents:active:%s", agentName)
-	instanceID := fmt.Sprintf("%s-%s", am.managerID, agentName)
+	instanceID := am.instanceID(agentName)
+	if process, exists := am.state.getManaged(agentName); exists && process.InstanceID != "" {
+		instanceID = process.InstanceID
+	}
 	
 	// Get existing data
 	data, err := am.RedisClient.HGet(am.ctx, instanceKey, instanceID).Result()
@@ -803,7 +1006,7 @@ func (am *AgentManager) startAgent(agentName string, sessionData map[string]inte
 			return fmt.Errorf("error checking agent status: %v", err)
 		}
 		if isRunning {
-			return fmt.Errorf("agent %s is already running (singleton constraint): %s", agentName, details)
+			return newManagerError(ErrCodeSingletonConflict, "agent %s is already running (singleton constraint): %s", agentName, details)
 		}
 	}
 	
@@ -819,36 +1022,51 @@ func (am *AgentManager) startAgent(agentName string, sessionData map[string]inte
 	
 	if dependencyCheck {
 		if err := am.validateAgentDependencies(agentName); err != nil {
-			return fmt.Errorf("dependency validation failed for %s: %v", agentName, err)
+			return newManagerError(ErrCodeDependencyFailure, "dependency validation failed for %s: %v", agentName, err)
 		}
 	}
 
 	// Get agent definition from registry
-	agentDef, exists := am.agentRegistry[agentName]
+	agentDef, exists := am.state.getDefinition(agentName)
 	if !exists {
-		return fmt.Errorf("unknown agent: %s (not in registry)", agentName)
+		return newManagerError(ErrCodeNotRegistered, "unknown agent: %s (not in registry)", agentName)
 	}
-	
-	directory := agentDef.Directory
 
-	// Create command to run the agent
-	cmd := exec.Command("go", "run", "*.go")
-	cmd.Dir = directory
-	
+	_, err := am.launchAgent(agentDef, agentName, am.instanceID(agentName), sessionData)
+	return err
+}
+
+// launchAgent starts the agent process, tracks it under key and registers its singleton instance record.
+// key differs from the agent name only for the replacement instance of a blue/green restart, which
+// starts in standby. Callers check the singleton constraint first (startAgent, checkRolloutBlue).
+func (am *AgentManager) launchAgent(agentDef *AgentDefinition, key, instanceID string, sessionData map[string]interface{}) (AgentProcess, error) {
+	agentName := agentDef.Name
+
 	// Set environment variables for session context
-	cmd.Env = os.Environ()
+	extraEnv := []string{}
+	if key != agentName {
+		// The replacement must not take requests until the rollout hands them over
+		extraEnv = append(extraEnv, "AGENT_STANDBY=true")
+	}
 	if sessionData != nil {
 		if sessionID, ok := sessionData["session_id"].(string); ok {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("SESSION_ID=%s", sessionID))
+			extraEnv = append(extraEnv, fmt.Sprintf("SESSION_ID=%s", sessionID))
 		}
 		if restore, ok := sessionData["restore_context"].(bool); ok && restore {
-			cmd.Env = append(cmd.Env, "RESTORE_CONTEXT=true")
+			extraEnv = append(extraEnv, "RESTORE_CONTEXT=true")
 		}
 	}
 
+	// Create sandboxed command to run the agent
+	cmd, err := am.buildAgentCommand(agentDef, key, extraEnv)
+	if err != nil {
+		return AgentProcess{}, newManagerError(ErrCodeStartFailed, "failed to prepare %s: %v", agentName, err)
+	}
+	directory := cmd.Dir
+
 	// Start the process
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start %s: %v", agentName, err)
+		return AgentProcess{}, newManagerError(ErrCodeStartFailed, "failed to start %s: %v", agentName, err)
 	}
 
 	// Track the process
@@ -859,87 +1077,84 @@ func (am *AgentManager) startAgent(agentName string, sessionData map[string]inte
 		}
 	}
 
-	am.agents[agentName] = &AgentProcess{
-		Name:      agentName,
-		Directory: directory,
-		Process:   cmd,
-		Running:   true,
-		StartTime: time.Now(),
-		SessionID: sessionID,
-		AgentType: agentDef.Type,
-		TaskID:    "", // regular agents don't have task IDs
+	process := &AgentProcess{
+		Name:       agentName,
+		Directory:  directory,
+		Process:    cmd.Cmd,
+		PID:        cmd.Process.Pid,
+		Running:    true,
+		StartTime:  time.Now(),
+		SessionID:  sessionID,
+		AgentType:  agentDef.Type,
+		TaskID:     "", // regular agents don't have task IDs
+		Cgroup:     cmd.Cgroup,
+		InstanceID: instanceID,
+		exited:     make(chan struct{}),
+	}
+	am.state.putManaged(key, process)
+
+	if agentDef.HealthCheck != nil && key == agentName {
+		am.probes.reset(agentName, agentDef.HealthCheck.probeType())
 	}
 
 	// Register agent instance in Redis for collision detection
-	if err := am.registerAgentInstance(agentName, sessionID); err != nil {
+	if err := am.registerAgentInstance(agentName, instanceID, sessionID, process.PID); err != nil {
 		fmt.Printf("Warning: Failed to register agent instance %s: %v\n", agentName, err)
 	}
 
+	reason := "start"
+	if key != agentName {
+		reason = "rollout_replacement"
+	}
+	am.emitEvent(LifecycleEvent{
+		Type:     EventAgentStarted,
+		Agent:    agentName,
+		Instance: instanceID,
+		PID:      process.PID,
+		Reason:   reason,
+		Details: map[string]interface{}{
+			"session_id": sessionID,
+			"agent_type": agentDef.Type,
+		},
+	})
+
 	// Monitor process in background
-	go am.monitorAgent(agentName)
+	go am.monitorAgent(agentName, process)
 
-	return nil
+	return *process, nil
 }
 
-func (am *AgentManager) stopAgentProcess(process *AgentProcess) {
+// stopAgentProcess terminates a managed process and waits for its monitor to finish cleanup
+func (am *AgentManager) stopAgentProcess(name string, process AgentProcess) {
 	if process.Process != nil && process.Running {
-		process.Process.Process.Signal(syscall.SIGTERM)
-		// Give it time to shutdown gracefully
-		time.Sleep(time.Second * 2)
-		if process.Process.ProcessState == nil {
-			process.Process.Process.Kill()
-		}
-		process.Running = false
-	}
-}
+		// Signal the whole process group, giving it time to shutdown gracefully
+		terminateProcessGroup(process.Process, time.Second*2, func() bool {
+			select {
+			case <-process.exited:
+				return true
+			default:
+				return false
+			}
+		})
+		am.state.markManagedStopped(name, process.PID)
 
-// Ephemeral agent management
-func (am *AgentManager) startEphemeralAgent(instanceName string, agentDef *AgentDefinition, taskID string, taskData map[string]interface{}) error {
-	// Create command to run the agent
-	cmd := exec.Command("go", "run", "*.go")
-	cmd.Dir = agentDef.Directory
-	
-	// Set environment variables for ephemeral context
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, fmt.Sprintf("AGENT_TYPE=ephemeral"))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("TASK_ID=%s", taskID))
-	if taskData != nil {
-		taskJSON, _ := json.Marshal(taskData)
-		cmd.Env = append(cmd.Env, fmt.Sprintf("TASK_DATA=%s", string(taskJSON)))
-	}
-	
-	// Start the process
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start ephemeral %s: %v", instanceName, err)
-	}
-	
-	// Track the ephemeral process
-	am.agents[instanceName] = &AgentProcess{
-		Name:      agentDef.Name,
-		Directory: agentDef.Directory,
-		Process:   cmd,
-		Running:   true,
-		StartTime: time.Now(),
-		SessionID: "", // ephemeral agents don't have sessions
-		AgentType: EphemeralAgent,
-		TaskID:    taskID,
+		select {
+		case <-process.exited:
+		case <-time.After(5 * time.Second):
+			fmt.Printf("Warning: %s (PID %d) did not exit after kill\n", name, process.PID)
+		}
 	}
-	
-	// Monitor ephemeral agent with timeout
-	go am.monitorEphemeralAgent(instanceName, agentDef)
-	
-	return nil
 }
 
-func (am *AgentManager) monitorAgent(agentName string) {
-	process := am.agents[agentName]
-	if process == nil {
-		return
-	}
+func (am *AgentManager) monitorAgent(agentName string, process *AgentProcess) {
+	defer close(process.exited)
 
 	// Wait for process to complete
 	err := process.Process.Wait()
-	process.Running = false
+	// A blue/green replacement is tracked under its own key until it is switched in
+	am.state.markManagedStopped(agentName, process.PID)
+	am.state.markManagedStopped(greenKey(agentName), process.PID)
+	removeCgroup(process.Cgroup)
 
 	fmt.Printf("%s: Agent %s exited", am.AgentID, agentName)
 	if err != nil {
@@ -948,94 +1163,35 @@ func (am *AgentManager) monitorAgent(agentName string) {
 	fmt.Println()
 
 	// Unregister agent instance from Redis
-	if err := am.unregisterAgentInstance(agentName); err != nil {
+	if err := am.unregisterAgentInstance(agentName, process.InstanceID); err != nil {
 		fmt.Printf("Warning: Failed to unregister agent instance %s: %v\n", agentName, err)
 	}
 
-	// Publish agent exit event
-	am.publishResponse(map[string]interface{}{
-		"event":      "agent_exited",
-		"agent":      agentName,
-		"session_id": process.SessionID,
-		"exit_time":  time.Now(),
-		"agent_type": process.AgentType,
-	})
-}
-
-func (am *AgentManager) monitorEphemeralAgent(instanceName string, agentDef *AgentDefinition) {
-	process := am.agents[instanceName]
-	if process == nil {
-		return
-	}
-	
-	fmt.Printf("%s: Monitoring ephemeral agent %s (task: %s)\n", am.AgentID, instanceName, process.TaskID)
-	
-	// Set up timeout if max runtime is specified
-	var timeoutChan <-chan time.Time
-	if agentDef.MaxRuntime > 0 {
-		timeoutChan = time.After(time.Duration(agentDef.MaxRuntime) * time.Second)
-		fmt.Printf("%s: Set %d second timeout for %s\n", am.AgentID, agentDef.MaxRuntime, instanceName)
-	}
-	
-	// Monitor process completion or timeout
-	done := make(chan error, 1)
-	go func() {
-		done <- process.Process.Wait()
-	}()
-	
-	select {
-	case err := <-done:
-		// Process completed normally
-		process.Running = false
-		fmt.Printf("%s: Ephemeral agent %s completed task %s", am.AgentID, instanceName, process.TaskID)
-		if err != nil {
-			fmt.Printf(" with error: %v", err)
-		}
-		fmt.Println()
-		
-	case <-timeoutChan:
-		// Process timed out - force kill
-		fmt.Printf("%s: Ephemeral agent %s timed out after %d seconds - killing\n", am.AgentID, instanceName, agentDef.MaxRuntime)
-		process.Process.Process.Kill()
-		process.Running = false
-		
-		// Publish timeout event
-		am.publishResponse(map[string]interface{}{
-			"event":      "ephemeral_timeout",
-			"agent":      agentDef.Name,
-			"instance":   instanceName,
-			"task_id":    process.TaskID,
-			"max_runtime": agentDef.MaxRuntime,
-			"exit_time":  time.Now(),
-		})
-	}
-	
-	// Cleanup ephemeral agent
-	delete(am.agents, instanceName)
-	
-	// Publish ephemeral completion event
-	am.publishResponse(map[string]interface{}{
-		"event":     "ephemeral_completed",
-		"agent":     agentDef.Name,
-		"instance":  instanceName,
-		"task_id":   process.TaskID,
-		"exit_time": time.Now(),
+	// Record agent exit event
+	exitCode := process.Process.ProcessState.ExitCode()
+	startedAt := process.StartTime
+	am.emitEvent(LifecycleEvent{
+		Type:      EventAgentExited,
+		Agent:     agentName,
+		Instance:  process.InstanceID,
+		PID:       process.PID,
+		ExitCode:  &exitCode,
+		Reason:    process.Process.ProcessState.String(),
+		StartedAt: &startedAt,
+		Details: map[string]interface{}{
+			"session_id": process.SessionID,
+			"agent_type": process.AgentType,
+		},
 	})
-	
-	fmt.Printf("%s: Ephemeral agent %s cleaned up\n", am.AgentID, instanceName)
-}
-
-func (am *AgentManager) publishResponse(response map[string]interface{}) {
-	responseJSON, _ := json.Marshal(response)
-	am.RedisClient.Publish(am.ctx, "centerfire:agent:manager:responses", string(responseJSON))
 }
 
 func (am *AgentManager) shutdown() {
 	fmt.Printf("%s: Shutting down all managed agents...\n", am.AgentID)
+	am.draining.Store(true)
 	
-	for name, process := range am.agents {
+	for name, process := range am.state.listManaged() {
 		fmt.Printf("Stopping %s...\n", name)
-		am.stopAgentProcess(process)
+		am.stopAgentProcess(name, process)
 	}
 	
 	// Shutdown HTTP server
@@ -1045,6 +1201,7 @@ func (am *AgentManager) shutdown() {
 		am.httpServer.Shutdown(ctx)
 	}
 	
+	am.releaseLeadership()
 	am.RedisClient.Close()
 	fmt.Printf("%s: Shutdown complete\n", am.AgentID)
 }
@@ -1065,24 +1222,35 @@ func CreateRestartCommand(agentName string, sessionID string) string {
 }
 
 func main() {
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
+	endpoints, err := config.Load("", os.Args[1:], APIToken)
+	if err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	args := endpoints.Args()
+	if len(args) > 0 {
+		switch args[0] {
 		case "restart":
-			if len(os.Args) < 3 {
+			if len(args) < 2 {
 				fmt.Println("Usage: go run main.go restart <agent-name> [session-id]")
 				return
 			}
 			sessionID := ""
-			if len(os.Args) > 3 {
-				sessionID = os.Args[3]
+			if len(args) > 2 {
+				sessionID = args[2]
 			}
-			cmd := CreateRestartCommand(os.Args[2], sessionID)
+			cmd := CreateRestartCommand(args[1], sessionID)
 			fmt.Printf("Execute: %s\n", cmd)
 			return
 		}
 	}
 
-	manager := NewAgentManager()
+	manager := NewAgentManager(endpoints)
 	manager.Start()
 }
 
@@ -1128,9 +1296,16 @@ func (am *AgentManager) storeAgentInRedis(agentName string, sessionData map[stri
 // handleHeartbeat processes heartbeat messages from agents
 func (am *AgentManager) handleHeartbeat(request AgentRequest) {
 	agentName := request.AgentName
+
+	if am.rollouts.observeHeartbeat(agentName, pidFromRecord(request.SessionData)) {
+		return // replacement instance not switched in yet
+	}
 	
-	if agentProcess, exists := am.runningAgents[agentName]; exists {
-		agentProcess.LastHeartbeat = time.Now()
+	if agentProcess, exists := am.state.touchHeartbeat(agentName, time.Now()); exists {
+		if am.isLeader() {
+			// Keep the singleton instance record fresh for collision checks
+			am.updateHeartbeat(agentName)
+		}
 		fmt.Printf("%s: Heartbeat received from %s (PID: %d)\n", am.AgentID, agentName, agentProcess.PID)
 	} else {
 		fmt.Printf("%s: Heartbeat from unregistered agent %s\n", am.AgentID, agentName)
@@ -1157,34 +1332,60 @@ func (am *AgentManager) startHeartbeatMonitor() {
 func (am *AgentManager) checkAgentHealth() {
 	now := time.Now()
 	
-	for agentName, agentProcess := range am.runningAgents {
+	for agentName, agentProcess := range am.state.listRunning() {
 		// Check heartbeat timeout
 		if now.Sub(agentProcess.LastHeartbeat) > am.heartbeatTimeout {
 			fmt.Printf("%s: Agent %s heartbeat timeout (last: %v)\n", 
 				am.AgentID, agentName, agentProcess.LastHeartbeat)
 			
-			// Double-check with PID validation
-			if !am.isProcessRunning(agentProcess.PID) {
+			// Only the leader acts on dead agents; followers just report
+			if !am.isLeader() {
+				continue
+			}
+
+			// Double-check with PID validation where the PID is from this host
+			if !am.agentAlive(agentProcess) {
 				fmt.Printf("%s: Confirming %s is dead (PID %d), removing registration\n",
 					am.AgentID, agentName, agentProcess.PID)
-				delete(am.runningAgents, agentName)
+				if !am.state.removeRunningIf(agentName, agentProcess.PID) {
+					continue // re-registered with a new PID since the snapshot
+				}
 				
 				// Clean up Redis
 				am.RedisClient.Del(am.ctx, fmt.Sprintf("centerfire:agents:running:%s", agentName))
+				am.emitEvent(LifecycleEvent{
+					Type:   EventHeartbeatTimeout,
+					Agent:  agentName,
+					PID:    agentProcess.PID,
+					Reason: "process_dead",
+					Details: map[string]interface{}{
+						"last_heartbeat": agentProcess.LastHeartbeat,
+						"agent_type":     agentProcess.AgentType,
+					},
+				})
 				
 				// TODO: For persistent agents, trigger diagnostic agent to investigate
 				if agentProcess.AgentType == PersistentAgent {
 					fmt.Printf("%s: ALERT - Persistent agent %s died, diagnostic needed\n", am.AgentID, agentName)
 					// Attempt dependency-aware restart after a delay
-					go func() {
+					go func(agentName string) {
 						time.Sleep(time.Second * 10) // Wait 10 seconds before attempting restart
+						unlock := am.state.lockAgent(agentName)
+						defer unlock()
+						if !am.isLeader() {
+							return // lost the lease while waiting; the new leader decides
+						}
+						if _, reregistered := am.state.getRunning(agentName); reregistered {
+							return // agent came back on its own
+						}
 						fmt.Printf("%s: Attempting automatic restart of %s\n", am.AgentID, agentName)
 						if err := am.startAgent(agentName, nil); err != nil {
 							fmt.Printf("%s: Automatic restart failed for %s: %v\n", am.AgentID, agentName, err)
 						} else {
 							fmt.Printf("%s: Successfully restarted %s\n", am.AgentID, agentName)
+							am.emitEvent(LifecycleEvent{Type: EventAgentRestarted, Agent: agentName, Reason: "heartbeat_timeout"})
 						}
-					}()
+					}(agentName)
 				}
 			} else {
 				fmt.Printf("%s: Agent %s missed heartbeat but PID %d still running\n",
@@ -1214,22 +1415,35 @@ func (am *AgentManager) startHTTPServer() {
 	
 	// Health endpoint
 	api.HandleFunc("/health", am.handleHealth).Methods("GET")
+
+	// Lifecycle event history for observers catching up after reconnect
+	api.HandleFunc("/events", am.handleEventsHTTP).Methods("GET")
+
+	// Authenticated lifecycle management endpoints
+	am.registerManagementRoutes(api)
 	
 	// Root endpoint
 	router.HandleFunc("/", am.handleRoot).Methods("GET")
 	
+	// Listen on the manager_addr port on all interfaces; other components dial manager_addr itself
+	_, port, err := net.SplitHostPort(am.endpoints.Get(config.ManagerAddr))
+	if err != nil {
+		fmt.Printf("%s: Invalid manager_addr %q: %v\n", am.AgentID, am.endpoints.Get(config.ManagerAddr), err)
+		return
+	}
+
 	// Create HTTP server
 	am.httpServer = &http.Server{
-		Addr:         ":8380",
+		Addr:         ":" + port,
 		Handler:      router,
 		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		WriteTimeout: 2 * time.Minute, // restarts wait on dependency retries and graceful stops
 		IdleTimeout:  30 * time.Second,
 	}
 	
 	// Start server in background
 	go func() {
-		fmt.Printf("%s: HTTP discovery service starting on port 8380\n", am.AgentID)
+		fmt.Printf("%s: HTTP discovery service starting on port %s\n", am.AgentID, port)
 		if err := am.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			fmt.Printf("%s: HTTP server error: %v\n", am.AgentID, err)
 		}
@@ -1241,9 +1455,9 @@ func (am *AgentManager) handleServicesDiscovery(w http.ResponseWriter, r *http.R
 	services := make(map[string]interface{})
 	
 	// Find HTTP Gateway service
-	for agentName, agentProcess := range am.runningAgents {
+	for agentName, agentProcess := range am.state.listRunning() {
 		if agentName == "AGT-HTTP-GATEWAY-1" {
-			services["http-gateway"] = am.getAgentServiceInfo(agentName, agentProcess)
+			services["http-gateway"] = am.getAgentServiceInfo(agentName, &agentProcess)
 		}
 	}
 	
@@ -1273,8 +1487,8 @@ func (am *AgentManager) handleServiceDiscovery(w http.ResponseWriter, r *http.Re
 		agentName = serviceName
 	}
 	
-	if agentProcess, exists := am.runningAgents[agentName]; exists {
-		serviceInfo := am.getAgentServiceInfo(agentName, agentProcess)
+	if agentProcess, exists := am.state.getRunning(agentName); exists {
+		serviceInfo := am.getAgentServiceInfo(agentName, &agentProcess)
 		response := map[string]interface{}{
 			"success":    true,
 			"service":    serviceInfo,
@@ -1300,9 +1514,17 @@ func (am *AgentManager) getAgentServiceInfo(agentName string, agentProcess *Agen
 	key := fmt.Sprintf("centerfire:agents:running:%s", agentName)
 	stored, err := am.RedisClient.Get(am.ctx, key).Result()
 	
+	status := "online"
+	health := am.agentHealthState(agentName, agentProcess)
+	if health != HealthUnknown {
+		status = string(health)
+	}
+	probeStatus, _ := am.probes.get(agentName)
+
 	serviceInfo := map[string]interface{}{
 		"name":          agentName,
-		"status":        "online",
+		"status":        status,
+		"health":        probeStatus,
 		"pid":           agentProcess.PID,
 		"start_time":    agentProcess.StartTime,
 		"last_heartbeat": agentProcess.LastHeartbeat,
@@ -1332,7 +1554,7 @@ func (am *AgentManager) getAgentServiceInfo(agentName string, agentProcess *Agen
 func (am *AgentManager) handleAgentsStatus(w http.ResponseWriter, r *http.Request) {
 	agents := make(map[string]interface{})
 	
-	for name, process := range am.runningAgents {
+	for name, process := range am.state.listRunning() {
 		agents[name] = map[string]interface{}{
 			"name":           name,
 			"status":         "online",
@@ -1341,6 +1563,7 @@ func (am *AgentManager) handleAgentsStatus(w http.ResponseWriter, r *http.Reques
 			"last_heartbeat": process.LastHeartbeat,
 			"type":           process.AgentType,
 			"task_id":        process.TaskID,
+			"health":         am.agentHealthState(name, &process),
 		}
 	}
 	
@@ -1361,7 +1584,7 @@ func (am *AgentManager) handleAgentStatusHTTP(w http.ResponseWriter, r *http.Req
 	vars := mux.Vars(r)
 	agentName := vars["agent_name"]
 	
-	if agentProcess, exists := am.runningAgents[agentName]; exists {
+	if agentProcess, exists := am.state.getRunning(agentName); exists {
 		agentInfo := map[string]interface{}{
 			"name":           agentName,
 			"status":         "online",
@@ -1370,6 +1593,7 @@ func (am *AgentManager) handleAgentStatusHTTP(w http.ResponseWriter, r *http.Req
 			"last_heartbeat": agentProcess.LastHeartbeat,
 			"type":           agentProcess.AgentType,
 			"task_id":        agentProcess.TaskID,
+			"health":         am.agentHealthState(agentName, &agentProcess),
 		}
 		
 		response := map[string]interface{}{
@@ -1397,7 +1621,9 @@ func (am *AgentManager) handleHealth(w http.ResponseWriter, r *http.Request) {
 		"success":      true,
 		"status":       "healthy",
 		"manager_id":   am.managerID,
-		"agents_count": len(am.runningAgents),
+		"role":         am.role(),
+		"leader":       am.currentLeader(),
+		"agents_count": am.state.countRunning(),
 		"uptime":       time.Since(time.Unix(0, 0)), // Rough uptime
 		"timestamp":    time.Now(),
 	}
@@ -1419,6 +1645,11 @@ func (am *AgentManager) handleRoot(w http.ResponseWriter, r *http.Request) {
 			"service_discovery": "/api/services/{service_name}",
 			"agents":             "/api/agents",
 			"agent_status":       "/api/agents/{agent_name}",
+			"agent_lifecycle":    "POST /api/agents/{agent_name}/{start|stop|restart|restart-with-dependencies}",
+			"spawn_ephemeral":    "POST /api/ephemeral/{agent_name}/spawn",
+			"registry":           "GET|POST /api/registry",
+			"managed_agents":     "/api/managed-agents",
+			"events":             "/api/events?since={event_id|unix_ms|RFC3339}",
 		},
 		"timestamp": time.Now(),
 	}
@@ -1434,13 +1665,9 @@ func (am *AgentManager) handleCheckDependencies(request AgentRequest) {
 	agentName := request.AgentName
 	fmt.Printf("%s: Checking dependencies for agent %s\n", am.AgentID, agentName)
 	
-	agentDef, exists := am.agentRegistry[agentName]
+	agentDef, exists := am.state.getDefinition(agentName)
 	if !exists {
-		am.publishResponse(map[string]interface{}{
-			"status": "error",
-			"agent":  agentName,
-			"error":  "Agent not found in registry",
-		})
+		am.replyError(request, ErrCodeNotRegistered, "Agent not found in registry", nil)
 		return
 	}
 	
@@ -1455,7 +1682,7 @@ func (am *AgentManager) handleCheckDependencies(request AgentRequest) {
 		}
 	}
 	
-	am.publishResponse(map[string]interface{}{
+	am.reply(request, map[string]interface{}{
 		"status":       "ok",
 		"agent":        agentName,
 		"all_healthy":  allHealthy,
@@ -1470,7 +1697,7 @@ func (am *AgentManager) handleValidateServiceHealth(request AgentRequest) {
 	
 	// Find agents that depend on this service
 	affectedAgents := make([]string, 0)
-	for agentName, agentDef := range am.agentRegistry {
+	for agentName, agentDef := range am.state.listDefinitions() {
 		for _, dep := range agentDef.Dependencies {
 			if dep.Service == serviceName {
 				affectedAgents = append(affectedAgents, agentName)
@@ -1481,7 +1708,7 @@ func (am *AgentManager) handleValidateServiceHealth(request AgentRequest) {
 	
 	// Check service health using first matching dependency config
 	var healthResult map[string]interface{}
-	for _, agentDef := range am.agentRegistry {
+	for _, agentDef := range am.state.listDefinitions() {
 		for _, dep := range agentDef.Dependencies {
 			if dep.Service == serviceName {
 				healthResult = am.checkServiceDependency(dep)
@@ -1501,7 +1728,7 @@ func (am *AgentManager) handleValidateServiceHealth(request AgentRequest) {
 		}
 	}
 	
-	am.publishResponse(map[string]interface{}{
+	am.reply(request, map[string]interface{}{
 		"status":          "ok",
 		"service":         serviceName,
 		"health":          healthResult,
@@ -1514,13 +1741,9 @@ func (am *AgentManager) handleRestartWithDependencies(request AgentRequest) {
 	agentName := request.AgentName
 	fmt.Printf("%s: Dependency-aware restart for agent %s\n", am.AgentID, agentName)
 	
-	agentDef, exists := am.agentRegistry[agentName]
+	agentDef, exists := am.state.getDefinition(agentName)
 	if !exists {
-		am.publishResponse(map[string]interface{}{
-			"status": "error",
-			"agent":  agentName,
-			"error":  "Agent not found in registry",
-		})
+		am.replyError(request, ErrCodeNotRegistered, "Agent not found in registry", nil)
 		return
 	}
 	
@@ -1537,7 +1760,7 @@ func (am *AgentManager) handleRestartWithDependencies(request AgentRequest) {
 		}
 		
 		if len(criticalFailures) > 0 {
-			am.publishResponse(map[string]interface{}{
+			am.reply(request, map[string]interface{}{
 				"status":            "dependency_failure",
 				"agent":             agentName,
 				"critical_failures": criticalFailures,
@@ -1552,7 +1775,8 @@ func (am *AgentManager) handleRestartWithDependencies(request AgentRequest) {
 	am.handleRestartAgent(request)
 }
 
-// checkServiceDependency validates a specific service dependency
+// checkServiceDependency validates a specific service dependency.
+// The check is attempted once plus RetryCount retries, RetryDelay seconds apart.
 func (am *AgentManager) checkServiceDependency(dep ServiceDependency) map[string]interface{} {
 	result := map[string]interface{}{
 		"service":  dep.Service,
@@ -1562,138 +1786,56 @@ func (am *AgentManager) checkServiceDependency(dep ServiceDependency) map[string
 		"healthy":  false,
 		"attempts": 0,
 	}
-	
-	// Attempt to validate dependency with retries
-	for attempt := 1; attempt <= dep.RetryCount; attempt++ {
+
+	maxAttempts := 1 + dep.RetryCount
+	if dep.RetryCount < 0 {
+		maxAttempts = 1
+	}
+
+	var checkResult string
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		result["attempts"] = attempt
-		
-		healthy, checkResult := am.performDependencyCheck(dep)
+
+		var healthy bool
+		healthy, checkResult = am.performDependencyCheck(dep)
 		if healthy {
 			result["healthy"] = true
 			result["check_result"] = checkResult
 			return result
 		}
-		
-		if attempt < dep.RetryCount {
-			fmt.Printf("%s: Dependency check failed for %s (attempt %d/%d), retrying in %ds\n", 
-				am.AgentID, dep.Service, attempt, dep.RetryCount, dep.RetryDelay)
-			time.Sleep(time.Duration(dep.RetryDelay) * time.Second)
+
+		if attempt < maxAttempts {
+			fmt.Printf("%s: Dependency check failed for %s (attempt %d/%d): %s, retrying in %ds\n",
+				am.AgentID, dep.Service, attempt, maxAttempts, checkResult, dep.RetryDelay)
+			select {
+			case <-time.After(time.Duration(dep.RetryDelay) * time.Second):
+			case <-am.ctx.Done():
+				result["error"] = "Dependency check cancelled"
+				return result
+			}
 		}
 	}
-	
-	result["error"] = fmt.Sprintf("Failed after %d attempts", dep.RetryCount)
+
+	result["check_result"] = checkResult
+	result["error"] = fmt.Sprintf("Failed after %d attempts: %s", maxAttempts, checkResult)
 	return result
 }
 
-// performDependencyCheck executes the actual dependency validation
+// performDependencyCheck executes the actual dependency validation using the probe registry
 func (am *AgentManager) performDependencyCheck(dep ServiceDependency) (bool, string) {
-	switch dep.Type {
-	case "infrastructure":
-		return am.checkInfrastructureDependency(dep)
-	case "agent":
-		return am.checkAgentDependency(dep)
-	case "container":
-		return am.checkContainerDependency(dep)
-	default:
-		return false, fmt.Sprintf("Unknown dependency type: %s", dep.Type)
+	probe, ok := lookupDependencyProbe(dep)
+	if !ok {
+		return false, fmt.Sprintf("No probe registered for %s dependency %s", dep.Type, dep.Service)
 	}
-}
 
-// checkInfrastructureDependency validates infrastructure services (Redis, Weaviate, etc.)
-func (am *AgentManager) checkInfrastructureDependency(dep ServiceDependency) (bool, string) {
-	switch dep.Service {
-	case "redis":
-		// Check Redis connection
-		_, err := am.RedisClient.Ping(am.ctx).Result()
-		if err != nil {
-			return false, fmt.Sprintf("Redis ping failed: %v", err)
-		}
-		return true, "Redis ping successful"
-		
-	case "weaviate":
-		// Check Weaviate endpoint
-		cmd := exec.Command("curl", "-s", "-f", "http://localhost:8080/v1/meta")
-		output, err := cmd.Output()
-		if err != nil {
-			return false, fmt.Sprintf("Weaviate health check failed: %v", err)
-		}
-		return true, fmt.Sprintf("Weaviate responding: %s", string(output)[:100])
-		
-	case "docker":
-		// Check Docker daemon
-		cmd := exec.Command("docker", "ps")
-		_, err := cmd.Output()
-		if err != nil {
-			return false, fmt.Sprintf("Docker daemon check failed: %v", err)
-		}
-		return true, "Docker daemon responding"
-		
-	case "neo4j":
-		// Check Neo4j endpoint
-		cmd := exec.Command("curl", "-s", "-f", "http://localhost:7474")
-		_, err := cmd.Output()
-		if err != nil {
-			return false, fmt.Sprintf("Neo4j health check failed: %v", err)
-		}
-		return true, "Neo4j responding"
-		
-	case "clickhouse":
-		// Check ClickHouse endpoint
-		cmd := exec.Command("curl", "-s", "-f", "http://localhost:8123/ping")
-		_, err := cmd.Output()
-		if err != nil {
-			return false, fmt.Sprintf("ClickHouse health check failed: %v", err)
-		}
-		return true, "ClickHouse responding"
-		
-	default:
-		return false, fmt.Sprintf("Unknown infrastructure service: %s", dep.Service)
-	}
-}
-
-// checkAgentDependency validates that required agents are running and responsive
-func (am *AgentManager) checkAgentDependency(dep ServiceDependency) (bool, string) {
-	// Check if agent is registered as running
-	if agentProcess, exists := am.runningAgents[dep.Service]; exists {
-		// Validate PID is still running
-		if am.isProcessRunning(agentProcess.PID) {
-			// Check recent heartbeat
-			if time.Since(agentProcess.LastHeartbeat) < am.heartbeatTimeout {
-				return true, fmt.Sprintf("Agent %s running (PID %d, last heartbeat %v)", 
-					dep.Service, agentProcess.PID, agentProcess.LastHeartbeat)
-			} else {
-				return false, fmt.Sprintf("Agent %s heartbeat timeout (last: %v)", 
-					dep.Service, agentProcess.LastHeartbeat)
-			}
-		} else {
-			return false, fmt.Sprintf("Agent %s process dead (PID %d)", dep.Service, agentProcess.PID)
-		}
-	}
-	
-	return false, fmt.Sprintf("Agent %s not registered as running", dep.Service)
-}
-
-// checkContainerDependency validates Docker containers are running
-func (am *AgentManager) checkContainerDependency(dep ServiceDependency) (bool, string) {
-	// Use endpoint as container name
-	containerName := dep.Endpoint
-	cmd := exec.Command("docker", "inspect", containerName, "--format", "{{.State.Status}}")
-	output, err := cmd.Output()
-	if err != nil {
-		return false, fmt.Sprintf("Container %s not found: %v", containerName, err)
-	}
-	
-	status := strings.TrimSpace(string(output))
-	if status == "running" {
-		return true, fmt.Sprintf("Container %s is running", containerName)
-	} else {
-		return false, fmt.Sprintf("Container %s status: %s", containerName, status)
-	}
+	ctx, cancel := context.WithTimeout(am.ctx, dependencyProbeTimeout)
+	defer cancel()
+	return probe(ctx, am, dep)
 }
 
 // validateAgentDependencies checks all critical dependencies for an agent
 func (am *AgentManager) validateAgentDependencies(agentName string) error {
-	agentDef, exists := am.agentRegistry[agentName]
+	agentDef, exists := am.state.getDefinition(agentName)
 	if !exists {
 		return fmt.Errorf("agent %s not found in registry", agentName)
 	}
@@ -1731,8 +1873,8 @@ func (am *AgentManager) validateAgentDependencies(agentName string) error {
 func (am *AgentManager) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 		
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
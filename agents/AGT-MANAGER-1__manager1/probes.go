@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// HealthState is the probe-derived readiness of an agent
+type HealthState string
+
+const (
+	HealthStarting  HealthState = "starting"  // no successful probe yet, within start period
+	HealthReady     HealthState = "ready"     // last probe succeeded
+	HealthDegraded  HealthState = "degraded"  // failing, but fewer than Retries consecutive failures
+	HealthUnhealthy HealthState = "unhealthy" // Retries consecutive failures, restart candidate
+	HealthUnknown   HealthState = "unknown"   // no health check configured
+)
+
+// Probe types supported by HealthCheckConfig.Type
+const (
+	ProbeExec  = "exec"
+	ProbeHTTP  = "http"
+	ProbeRedis = "redis"
+)
+
+// ProbeStatus tracks probe results for one agent
+type ProbeStatus struct {
+	Agent               string      `json:"agent"`
+	State               HealthState `json:"state"`
+	ProbeType           string      `json:"probe_type"`
+	ConsecutiveFailures int         `json:"consecutive_failures"`
+	LastCheck           time.Time   `json:"last_check"`
+	LastSuccess         time.Time   `json:"last_success"`
+	LastResult          string      `json:"last_result,omitempty"`
+	LastError           string      `json:"last_error,omitempty"`
+	StartedAt           time.Time   `json:"started_at"`
+	RestartFailures     int         `json:"restart_failures,omitempty"`
+	NextRestart         time.Time   `json:"next_restart,omitempty"`
+}
+
+// Backoff between attempts to restart an unhealthy agent whose last restart failed
+const (
+	restartBackoffBase = 10 * time.Second
+	restartBackoffMax  = 5 * time.Minute
+)
+
+// probeRegistry holds per-agent probe status shared by the probe goroutines and readers
+type probeRegistry struct {
+	mu       sync.RWMutex
+	statuses map[string]*ProbeStatus
+}
+
+func newProbeRegistry() *probeRegistry {
+	return &probeRegistry{statuses: make(map[string]*ProbeStatus)}
+}
+
+// reset marks an agent as starting, e.g. right after (re)launch
+func (pr *probeRegistry) reset(agentName, probeType string) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.statuses[agentName] = &ProbeStatus{
+		Agent:     agentName,
+		State:     HealthStarting,
+		ProbeType: probeType,
+		StartedAt: time.Now(),
+	}
+}
+
+// get returns a copy of the agent's probe status
+func (pr *probeRegistry) get(agentName string) (ProbeStatus, bool) {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+	status, ok := pr.statuses[agentName]
+	if !ok {
+		return ProbeStatus{Agent: agentName, State: HealthUnknown}, false
+	}
+	return *status, true
+}
+
+// record applies a probe result and returns the previous and new state
+func (pr *probeRegistry) record(agentName string, config *HealthCheckConfig, result string, err error) (HealthState, HealthState) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	status, ok := pr.statuses[agentName]
+	if !ok {
+		status = &ProbeStatus{Agent: agentName, State: HealthStarting, ProbeType: config.probeType(), StartedAt: time.Now()}
+		pr.statuses[agentName] = status
+	}
+	previous := status.State
+	status.LastCheck = time.Now()
+
+	if err == nil {
+		status.ConsecutiveFailures = 0
+		status.LastSuccess = status.LastCheck
+		status.LastResult = result
+		status.LastError = ""
+		status.State = HealthReady
+		return previous, status.State
+	}
+
+	status.ConsecutiveFailures++
+	status.LastError = err.Error()
+
+	retries := config.Retries
+	if retries <= 0 {
+		retries = 1
+	}
+	startPeriod := time.Duration(config.StartPeriod) * time.Second
+
+	switch {
+	case status.LastSuccess.IsZero() && time.Since(status.StartedAt) < startPeriod:
+		// Failures during the start period don't count against the agent
+		status.State = HealthStarting
+	case status.ConsecutiveFailures >= retries:
+		status.State = HealthUnhealthy
+	default:
+		status.State = HealthDegraded
+	}
+	return previous, status.State
+}
+
+// restartFailed records a failed restart of an unhealthy agent and schedules the next attempt,
+// doubling the wait each time. A successful restart resets the status, and with it the backoff.
+func (pr *probeRegistry) restartFailed(agentName string) time.Duration {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	status, ok := pr.statuses[agentName]
+	if !ok {
+		status = &ProbeStatus{Agent: agentName, State: HealthUnhealthy, StartedAt: time.Now()}
+		pr.statuses[agentName] = status
+	}
+	status.RestartFailures++
+
+	backoff := restartBackoffMax
+	if shift := status.RestartFailures - 1; shift < 6 {
+		backoff = min(restartBackoffBase<<shift, restartBackoffMax)
+	}
+	status.NextRestart = time.Now().Add(backoff)
+	return backoff
+}
+
+// restartDue reports whether an agent is still unhealthy after a failed restart and its next
+// attempt is due
+func (pr *probeRegistry) restartDue(agentName string) bool {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+	status, ok := pr.statuses[agentName]
+	return ok && status.State == HealthUnhealthy && status.RestartFailures > 0 && !time.Now().Before(status.NextRestart)
+}
+
+// probeType infers the probe type for configs that predate the Type field
+func (hc *HealthCheckConfig) probeType() string {
+	if hc.Type != "" {
+		return hc.Type
+	}
+	if hc.URL != "" {
+		return ProbeHTTP
+	}
+	return ProbeExec
+}
+
+// runProbe executes one health check for an agent
+func (am *AgentManager) runProbe(config *HealthCheckConfig) (string, error) {
+	timeout := time.Duration(config.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(am.ctx, timeout)
+	defer cancel()
+
+	switch config.probeType() {
+	case ProbeExec:
+		if config.Command == "" {
+			return "", fmt.Errorf("exec probe has no command")
+		}
+		output, err := exec.CommandContext(ctx, "/bin/sh", "-c", config.Command).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("command failed: %v", err)
+		}
+		return truncate(string(output), 200), nil
+
+	case ProbeHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.URL, nil)
+		if err != nil {
+			return "", fmt.Errorf("invalid probe URL %s: %v", config.URL, err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("GET %s failed: %v", config.URL, err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return "", fmt.Errorf("GET %s returned %d", config.URL, resp.StatusCode)
+		}
+		return fmt.Sprintf("HTTP %d", resp.StatusCode), nil
+
+	case ProbeRedis:
+		client := am.RedisClient
+		if config.Endpoint != "" {
+			client = redis.NewClient(&redis.Options{Addr: config.Endpoint})
+			defer client.Close()
+		}
+		pong, err := client.Ping(ctx).Result()
+		if err != nil {
+			return "", fmt.Errorf("redis ping failed: %v", err)
+		}
+		return pong, nil
+
+	default:
+		return "", fmt.Errorf("unknown probe type: %s", config.Type)
+	}
+}
+
+// startProbeRunner launches a probe loop for every registered agent with a health check
+func (am *AgentManager) startProbeRunner() {
+	for agentName, agentDef := range am.state.listDefinitions() {
+		if agentDef.HealthCheck == nil {
+			continue
+		}
+		go am.probeLoop(agentName, agentDef.HealthCheck)
+	}
+}
+
+func (am *AgentManager) probeLoop(agentName string, config *HealthCheckConfig) {
+	interval := time.Duration(config.Interval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !am.isAgentTracked(agentName) {
+				// A failed restart leaves nothing to probe; keep retrying it
+				if am.probes.restartDue(agentName) {
+					am.handleUnhealthyAgent(agentName)
+				}
+				continue
+			}
+			// Pick up health check changes from re-registration
+			if agentDef, exists := am.state.getDefinition(agentName); exists && agentDef.HealthCheck != nil {
+				config = agentDef.HealthCheck
+			}
+			am.probeAgent(agentName, config)
+		case <-am.ctx.Done():
+			return
+		}
+	}
+}
+
+// probeAgent runs a single probe and acts on state transitions
+func (am *AgentManager) probeAgent(agentName string, config *HealthCheckConfig) {
+	result, err := am.runProbe(config)
+	previous, current := am.probes.record(agentName, config, result, err)
+
+	if previous == current {
+		if current == HealthUnhealthy && am.probes.restartDue(agentName) {
+			am.handleUnhealthyAgent(agentName)
+		}
+		return
+	}
+	fmt.Printf("%s: Agent %s health %s -> %s\n", am.AgentID, agentName, previous, current)
+	details := map[string]interface{}{"from": previous, "to": current}
+	if err != nil {
+		details["error"] = truncate(err.Error(), 200)
+	}
+	am.emitEvent(LifecycleEvent{Type: EventHealthChanged, Agent: agentName, Reason: string(current), Details: details})
+
+	if current == HealthUnhealthy {
+		am.handleUnhealthyAgent(agentName)
+	}
+}
+
+// handleUnhealthyAgent restarts persistent agents the manager launched itself. A failed restart
+// is retried with backoff for as long as the agent stays unhealthy.
+func (am *AgentManager) handleUnhealthyAgent(agentName string) {
+	agentDef, exists := am.state.getDefinition(agentName)
+	if !exists || agentDef.Type != PersistentAgent || !am.isLeader() {
+		return
+	}
+
+	unlock := am.state.lockAgent(agentName)
+	defer unlock()
+
+	process, managed := am.state.getManaged(agentName)
+	if !managed {
+		// Externally started agents are reported but not restarted by the probe runner
+		fmt.Printf("%s: ALERT - Agent %s is unhealthy but not managed by this manager\n", am.AgentID, agentName)
+		return
+	}
+
+	fmt.Printf("%s: Restarting unhealthy agent %s\n", am.AgentID, agentName)
+	am.stopAgentProcess(agentName, process)
+	if err := am.startAgent(agentName, map[string]interface{}{"session_id": process.SessionID}); err != nil {
+		backoff := am.probes.restartFailed(agentName)
+		fmt.Printf("%s: Restart of unhealthy agent %s failed: %v (retrying in %s)\n", am.AgentID, agentName, err, backoff)
+		return
+	}
+	am.emitEvent(LifecycleEvent{Type: EventAgentRestarted, Agent: agentName, Reason: "unhealthy"})
+}
+
+// isAgentTracked reports whether the agent is running under or registered with this manager
+func (am *AgentManager) isAgentTracked(agentName string) bool {
+	if process, exists := am.state.getManaged(agentName); exists && process.Running {
+		return true
+	}
+	_, exists := am.state.getRunning(agentName)
+	return exists
+}
+
+// agentHealthState returns the probe state, falling back to heartbeat-based liveness
+func (am *AgentManager) agentHealthState(agentName string, agentProcess *AgentProcess) HealthState {
+	if status, ok := am.probes.get(agentName); ok {
+		return status.State
+	}
+	if agentProcess != nil && !agentProcess.LastHeartbeat.IsZero() && time.Since(agentProcess.LastHeartbeat) > am.heartbeatTimeout {
+		return HealthUnhealthy
+	}
+	return HealthUnknown
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
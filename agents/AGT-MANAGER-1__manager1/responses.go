@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const managerResponsesChannel = "centerfire:agent:manager:responses"
+
+// ManagerErrorCode classifies failed manager operations so callers can react without parsing messages
+type ManagerErrorCode string
+
+const (
+	ErrCodeInvalidRequest     ManagerErrorCode = "invalid_request"
+	ErrCodeUnknownRequestType ManagerErrorCode = "unknown_request_type"
+	ErrCodeAgentNotFound      ManagerErrorCode = "agent_not_found"
+	ErrCodeNotRegistered      ManagerErrorCode = "not_registered"
+	ErrCodeNotEphemeral       ManagerErrorCode = "not_ephemeral"
+	ErrCodeSingletonConflict  ManagerErrorCode = "singleton_conflict"
+	ErrCodeDependencyFailure  ManagerErrorCode = "dependency_failure"
+	ErrCodeStartFailed        ManagerErrorCode = "start_failed"
+	ErrCodeSessionNotFound    ManagerErrorCode = "session_not_found"
+	ErrCodeNotLeader          ManagerErrorCode = "not_leader"
+	ErrCodeTaskNotFound       ManagerErrorCode = "task_not_found"
+	ErrCodeTaskFailed         ManagerErrorCode = "task_failed"
+	ErrCodeTaskTimeout        ManagerErrorCode = "task_timeout"
+	ErrCodeRolloutInProgress  ManagerErrorCode = "rollout_in_progress"
+	ErrCodeRolloutFailed      ManagerErrorCode = "rollout_failed"
+	ErrCodeInternal           ManagerErrorCode = "internal_error"
+)
+
+// managerError is an error carrying a ManagerErrorCode
+type managerError struct {
+	Code    ManagerErrorCode
+	Message string
+}
+
+func (e *managerError) Error() string {
+	return e.Message
+}
+
+func newManagerError(code ManagerErrorCode, format string, args ...interface{}) error {
+	return &managerError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// errorCode extracts the code from an error, defaulting to internal_error
+func errorCode(err error) ManagerErrorCode {
+	var me *managerError
+	if errors.As(err, &me) {
+		return me.Code
+	}
+	return ErrCodeInternal
+}
+
+// statusErrorCodes maps legacy non-success statuses to their error code
+var statusErrorCodes = map[string]ManagerErrorCode{
+	"error":              ErrCodeInternal,
+	"not_found":          ErrCodeAgentNotFound,
+	"dependency_failure": ErrCodeDependencyFailure,
+	"failed":             ErrCodeTaskFailed,
+	"timeout":            ErrCodeTaskTimeout,
+}
+
+// reply publishes a response correlated with the request: request_id is echoed and the
+// caller's response_channel is used when supplied, otherwise the shared responses channel.
+func (am *AgentManager) reply(request AgentRequest, response map[string]interface{}) {
+	if request.RequestID != "" {
+		response["request_id"] = request.RequestID
+	}
+	response["request_type"] = request.RequestType
+	if _, ok := response["manager_id"]; !ok {
+		response["manager_id"] = am.managerID
+	}
+	response["timestamp"] = time.Now()
+
+	status, _ := response["status"].(string)
+	code, failed := statusErrorCodes[status]
+	if failed {
+		if _, ok := response["error_code"]; !ok {
+			response["error_code"] = code
+		}
+	}
+	response["success"] = !failed
+
+	if request.replyTo != nil {
+		select {
+		case request.replyTo <- response:
+		default: // a reply was already delivered for this request
+		}
+		return
+	}
+
+	channel := managerResponsesChannel
+	if request.ResponseChannel != "" {
+		channel = request.ResponseChannel
+	}
+
+	responseJSON, _ := json.Marshal(response)
+	am.RedisClient.Publish(am.ctx, channel, string(responseJSON))
+}
+
+// replyError publishes a typed error response for the request
+func (am *AgentManager) replyError(request AgentRequest, code ManagerErrorCode, message string, extra map[string]interface{}) {
+	response := map[string]interface{}{
+		"status":     "error",
+		"error":      message,
+		"error_code": code,
+	}
+	if request.AgentName != "" {
+		response["agent"] = request.AgentName
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+	am.reply(request, response)
+}
+
+// replyToUnparseable salvages request_id/response_channel from a malformed payload to report the error
+func (am *AgentManager) replyToUnparseable(payload string, parseErr error) {
+	var partial map[string]interface{}
+	if json.Unmarshal([]byte(payload), &partial) != nil {
+		return // not even JSON; nobody to correlate with
+	}
+
+	request := AgentRequest{}
+	if id, ok := partial["request_id"].(string); ok {
+		request.RequestID = id
+	}
+	if channel, ok := partial["response_channel"].(string); ok && strings.TrimSpace(channel) != "" {
+		request.ResponseChannel = channel
+	}
+	if request.RequestID == "" && request.ResponseChannel == "" {
+		return
+	}
+	if rt, ok := partial["request_type"].(string); ok {
+		request.RequestType = rt
+	}
+
+	am.replyError(request, ErrCodeInvalidRequest, fmt.Sprintf("invalid request: %v", parseErr), nil)
+}
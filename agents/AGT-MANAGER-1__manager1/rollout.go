@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Blue/green restart: the replacement ("green") instance is launched in standby next to the
+// running one ("blue"). It must register and answer a ping on its own control channel before it
+// takes over. The hand-over pauses blue's request transports and only then resumes green's, so
+// the two never consume requests at the same time. Green then takes over the running/singleton
+// registration and blue is drained. If green never becomes ready, or the hand-over fails, green is
+// stopped and blue keeps serving.
+
+// RolloutPhase is the progress of a blue/green restart
+type RolloutPhase string
+
+const (
+	RolloutStarting   RolloutPhase = "starting"
+	RolloutWaiting    RolloutPhase = "waiting_ready"
+	RolloutDraining   RolloutPhase = "draining"
+	RolloutCompleted  RolloutPhase = "completed"
+	RolloutRolledBack RolloutPhase = "rolled_back"
+)
+
+const (
+	defaultRolloutReadyTimeout = 60 * time.Second
+	rolloutDrainGrace          = 10 * time.Second // SIGTERM to SIGKILL for the old instance
+	rolloutSettle              = 2 * time.Second  // lets the old instance's unregister arrive before we finish
+	rolloutPollInterval        = time.Second
+	rolloutProbeInterval       = 2 * time.Second
+	rolloutControlTimeout      = 5 * time.Second // wait for an instance to answer a control command
+)
+
+// Rollout is the state of one in-progress blue/green restart
+type Rollout struct {
+	Agent         string                 `json:"agent"`
+	Phase         RolloutPhase           `json:"phase"`
+	BluePID       int                    `json:"blue_pid"`
+	GreenPID      int                    `json:"green_pid,omitempty"`
+	BlueControl   string                 `json:"blue_control,omitempty"`
+	GreenControl  string                 `json:"green_control,omitempty"`
+	StartedAt     time.Time              `json:"started_at"`
+	RegisteredAt  time.Time              `json:"registered_at,omitempty"`
+	LastHeartbeat time.Time              `json:"last_heartbeat,omitempty"`
+	Ready         bool                   `json:"ready"`
+	Error         string                 `json:"error,omitempty"`
+	registration  map[string]interface{} // green's register_running session data, applied at switch
+}
+
+// rolloutTracker holds active rollouts so registration/heartbeat handlers can route green's messages
+type rolloutTracker struct {
+	mu     sync.Mutex
+	active map[string]*Rollout
+}
+
+func newRolloutTracker() *rolloutTracker {
+	return &rolloutTracker{active: make(map[string]*Rollout)}
+}
+
+// begin records a new rollout, refusing if one is already running for the agent
+func (rt *rolloutTracker) begin(agentName string, bluePID int) (Rollout, bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if existing, exists := rt.active[agentName]; exists {
+		return *existing, false
+	}
+	rollout := &Rollout{
+		Agent:     agentName,
+		Phase:     RolloutStarting,
+		BluePID:   bluePID,
+		StartedAt: time.Now(),
+	}
+	rt.active[agentName] = rollout
+	return *rollout, true
+}
+
+func (rt *rolloutTracker) get(agentName string) (Rollout, bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rollout, exists := rt.active[agentName]
+	if !exists {
+		return Rollout{}, false
+	}
+	return *rollout, true
+}
+
+func (rt *rolloutTracker) update(agentName string, fn func(*Rollout)) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rollout, exists := rt.active[agentName]; exists {
+		fn(rollout)
+	}
+}
+
+func (rt *rolloutTracker) end(agentName string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	delete(rt.active, agentName)
+}
+
+// observeRegistration captures register_running from a pending green instance.
+// It returns false for any other PID so normal registration proceeds.
+func (rt *rolloutTracker) observeRegistration(agentName string, pid int, sessionData map[string]interface{}) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rollout, exists := rt.active[agentName]
+	if !exists || pid == 0 || pid != rollout.GreenPID || rollout.Phase != RolloutWaiting {
+		return false
+	}
+	rollout.RegisteredAt = time.Now()
+	rollout.LastHeartbeat = rollout.RegisteredAt
+	rollout.registration = sessionData
+	rollout.GreenControl, _ = sessionData["control"].(string)
+	return true
+}
+
+// observeHeartbeat captures heartbeats from a pending green instance
+func (rt *rolloutTracker) observeHeartbeat(agentName string, pid int) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rollout, exists := rt.active[agentName]
+	if !exists || pid == 0 || pid != rollout.GreenPID || rollout.Phase != RolloutWaiting {
+		return false
+	}
+	rollout.LastHeartbeat = time.Now()
+	return true
+}
+
+// ignoresUnregister reports whether an unregister without PID most likely came from the draining blue instance
+func (rt *rolloutTracker) ignoresUnregister(agentName string) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rollout, exists := rt.active[agentName]
+	return exists && rollout.Phase == RolloutDraining
+}
+
+// rolloutReadyTimeout allows the start period plus enough probe rounds to declare readiness
+func rolloutReadyTimeout(agentDef *AgentDefinition) time.Duration {
+	hc := agentDef.HealthCheck
+	if hc == nil {
+		return defaultRolloutReadyTimeout
+	}
+	timeout := time.Duration(hc.StartPeriod+hc.Interval*(hc.Retries+1)) * time.Second
+	if timeout < defaultRolloutReadyTimeout {
+		return defaultRolloutReadyTimeout
+	}
+	return timeout
+}
+
+// greenKey is the managed-process key used for the replacement until it takes over
+func greenKey(agentName string) string {
+	return agentName + "#green"
+}
+
+// rollingRestart runs the blue/green sequence and reports the outcome to the requester
+func (am *AgentManager) rollingRestart(request AgentRequest, agentDef *AgentDefinition, blue AgentProcess) {
+	agentName := agentDef.Name
+	defer am.rollouts.end(agentName)
+
+	unlock := am.state.lockAgent(agentName)
+	defer unlock()
+
+	// The caller already got the "rolling" acknowledgement; the outcome goes to its channel
+	request.replyTo = nil
+
+	// Blue may have changed before the lock was ours, and green is the only second instance the
+	// singleton constraint allows
+	if err := am.checkRolloutBlue(agentName, blue); err != nil {
+		am.finishRollout(request, RolloutRolledBack, err.Error())
+		return
+	}
+	running, _ := am.state.getRunning(agentName)
+	am.rollouts.update(agentName, func(r *Rollout) { r.BlueControl = running.ControlChannel })
+
+	sessionData := request.SessionData
+	if sessionData == nil {
+		sessionData = map[string]interface{}{"session_id": blue.SessionID}
+	}
+
+	fmt.Printf("%s: Rolling restart of %s (blue PID %d)\n", am.AgentID, agentName, blue.PID)
+	am.emitEvent(LifecycleEvent{Type: EventRolloutStarted, Agent: agentName, Instance: blue.InstanceID, PID: blue.PID, Reason: "restart_requested"})
+	green, err := am.launchAgent(agentDef, greenKey(agentName), fmt.Sprintf("%s-%d", am.instanceID(agentName), time.Now().UnixNano()), sessionData)
+	if err != nil {
+		am.finishRollout(request, RolloutRolledBack, fmt.Sprintf("failed to start replacement: %v", err))
+		return
+	}
+	am.rollouts.update(agentName, func(r *Rollout) {
+		r.GreenPID = green.PID
+		r.Phase = RolloutWaiting
+	})
+
+	if err := am.awaitGreenReady(agentDef, green); err != nil {
+		fmt.Printf("%s: Replacement %s (PID %d) not ready: %v - rolling back\n", am.AgentID, agentName, green.PID, err)
+		am.rollBackGreen(request, green, err.Error())
+		return
+	}
+
+	// Hand over: blue stops taking requests before green starts, so no request is handled twice
+	rollout, _ := am.rollouts.get(agentName)
+	if _, err := am.controlAgent(rollout.BlueControl, blue.PID, "pause"); err != nil {
+		am.controlAgent(rollout.BlueControl, blue.PID, "resume")
+		am.rollBackGreen(request, green, fmt.Sprintf("failed to pause PID %d: %v", blue.PID, err))
+		return
+	}
+	if _, err := am.controlAgent(rollout.GreenControl, green.PID, "resume"); err != nil {
+		am.controlAgent(rollout.BlueControl, blue.PID, "resume")
+		am.rollBackGreen(request, green, fmt.Sprintf("failed to resume replacement: %v", err))
+		return
+	}
+
+	// Switch: green takes over the managed slot, the running registration and the probe status
+	am.rollouts.update(agentName, func(r *Rollout) { r.Phase = RolloutDraining })
+	am.state.renameManaged(greenKey(agentName), agentName)
+	am.switchRunningRegistration(agentDef, green, rollout)
+	fmt.Printf("%s: %s switched to PID %d, draining PID %d\n", am.AgentID, agentName, green.PID, blue.PID)
+
+	// Drain: give the old instance a longer grace period to finish in-flight work
+	if blue.Process != nil && blue.Running {
+		terminateProcessGroup(blue.Process, rolloutDrainGrace, func() bool {
+			select {
+			case <-blue.exited:
+				return true
+			default:
+				return false
+			}
+		})
+		select {
+		case <-blue.exited:
+		case <-time.After(5 * time.Second):
+			fmt.Printf("Warning: %s (PID %d) did not exit after drain\n", agentName, blue.PID)
+		}
+	}
+	time.Sleep(rolloutSettle)
+
+	// The old instance's shutdown may have unregistered the agent by name; restore green
+	if _, registered := am.state.getRunning(agentName); !registered && am.isProcessRunning(green.PID) {
+		am.switchRunningRegistration(agentDef, green, rollout)
+	}
+
+	am.finishRollout(request, RolloutCompleted, "")
+}
+
+// checkRolloutBlue verifies blue is still the agent's managed, registered instance and that no
+// other instance holds the singleton
+func (am *AgentManager) checkRolloutBlue(agentName string, blue AgentProcess) error {
+	current, managed := am.state.getManaged(agentName)
+	if !managed || !current.Running || current.PID != blue.PID {
+		return fmt.Errorf("PID %d is no longer the running instance", blue.PID)
+	}
+	running, registered := am.state.getRunning(agentName)
+	if !registered || running.PID != blue.PID || running.ControlChannel == "" {
+		return fmt.Errorf("PID %d is not registered with a control channel", blue.PID)
+	}
+	if am.getSingletonAgents()[agentName] {
+		instances, err := am.activeInstances(agentName)
+		if err != nil {
+			return fmt.Errorf("error checking agent status: %v", err)
+		}
+		for _, instanceID := range instances {
+			if instanceID != blue.InstanceID {
+				return newManagerError(ErrCodeSingletonConflict, "agent %s has another active instance (singleton constraint): %s", agentName, instanceID)
+			}
+		}
+	}
+	return nil
+}
+
+// rollBackGreen stops the replacement and reports the rollback; blue keeps serving
+func (am *AgentManager) rollBackGreen(request AgentRequest, green AgentProcess, reason string) {
+	am.stopAgentProcess(greenKey(request.AgentName), green)
+	am.state.removeManagedIf(greenKey(request.AgentName), green.PID)
+	am.finishRollout(request, RolloutRolledBack, reason)
+}
+
+// awaitGreenReady waits until the green instance has registered and answers a ping on its own
+// control channel. Blue's health check would reach blue, so it is not used here; the agent's
+// probes take over once green is switched in.
+func (am *AgentManager) awaitGreenReady(agentDef *AgentDefinition, green AgentProcess) error {
+	deadline := time.Now().Add(rolloutReadyTimeout(agentDef))
+	var lastProbe time.Time
+	var probeErr error
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-green.exited:
+			return fmt.Errorf("replacement exited before becoming ready")
+		case <-time.After(rolloutPollInterval):
+		}
+
+		rollout, _ := am.rollouts.get(agentDef.Name)
+		if rollout.RegisteredAt.IsZero() {
+			continue // no registration/heartbeat from green yet
+		}
+		if rollout.GreenControl == "" {
+			return fmt.Errorf("replacement registered without a control channel")
+		}
+		if time.Since(lastProbe) < rolloutProbeInterval {
+			continue
+		}
+		lastProbe = time.Now()
+		if _, probeErr = am.controlAgent(rollout.GreenControl, green.PID, "ping"); probeErr == nil {
+			am.rollouts.update(agentDef.Name, func(r *Rollout) { r.Ready = true })
+			return nil
+		}
+	}
+
+	rollout, _ := am.rollouts.get(agentDef.Name)
+	switch {
+	case rollout.RegisteredAt.IsZero():
+		return fmt.Errorf("replacement did not register within %v", rolloutReadyTimeout(agentDef))
+	case probeErr != nil:
+		return fmt.Errorf("replacement did not answer ping: %v", probeErr)
+	default:
+		return fmt.Errorf("replacement not ready within %v", rolloutReadyTimeout(agentDef))
+	}
+}
+
+// controlAgent sends a command to the control channel of the agent process with pid and waits for
+// its reply
+func (am *AgentManager) controlAgent(channel string, pid int, command string) (map[string]interface{}, error) {
+	if channel == "" {
+		return nil, fmt.Errorf("no control channel")
+	}
+	replyTo := fmt.Sprintf("centerfire:agent:manager:control:%s:%d", am.managerID, time.Now().UnixNano())
+	pubsub := am.RedisClient.Subscribe(am.ctx, replyTo)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(am.ctx); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s: %v", replyTo, err)
+	}
+
+	data, _ := json.Marshal(map[string]string{"command": command, "reply_to": replyTo})
+	receivers, err := am.RedisClient.Publish(am.ctx, channel, data).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s: %v", command, err)
+	}
+	if receivers == 0 {
+		return nil, fmt.Errorf("nothing listening on %s", channel)
+	}
+
+	ctx, cancel := context.WithTimeout(am.ctx, rolloutControlTimeout)
+	defer cancel()
+	msg, err := pubsub.ReceiveMessage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no reply to %s: %v", command, err)
+	}
+	var reply map[string]interface{}
+	if err := json.Unmarshal([]byte(msg.Payload), &reply); err != nil {
+		return nil, fmt.Errorf("invalid reply to %s: %v", command, err)
+	}
+	if message, failed := reply["error"].(string); failed {
+		return reply, fmt.Errorf("%s", message)
+	}
+	if replyPID := pidFromRecord(reply); replyPID != pid {
+		return reply, fmt.Errorf("reply to %s came from PID %d, want %d", command, replyPID, pid)
+	}
+	return reply, nil
+}
+
+// switchRunningRegistration points the running/singleton registration at the green instance
+func (am *AgentManager) switchRunningRegistration(agentDef *AgentDefinition, green AgentProcess, rollout Rollout) {
+	am.state.putRunning(agentDef.Name, &AgentProcess{
+		Name:           agentDef.Name,
+		Host:           am.hostname,
+		PID:            green.PID,
+		Running:        true,
+		StartTime:      green.StartTime,
+		LastHeartbeat:  rollout.LastHeartbeat,
+		AgentType:      agentDef.Type,
+		ControlChannel: rollout.GreenControl,
+	})
+	registration := map[string]interface{}{"pid": green.PID}
+	for k, v := range rollout.registration {
+		registration[k] = v
+	}
+	registration["standby"] = false // resumed at the hand-over
+	am.storeAgentInRedis(agentDef.Name, registration)
+
+	if agentDef.HealthCheck != nil {
+		am.probes.reset(agentDef.Name, agentDef.HealthCheck.probeType())
+		if rollout.Ready {
+			am.probes.record(agentDef.Name, agentDef.HealthCheck, "ready at switch", nil)
+		}
+	}
+}
+
+// finishRollout reports the final outcome on the requester's channel
+func (am *AgentManager) finishRollout(request AgentRequest, phase RolloutPhase, message string) {
+	am.rollouts.update(request.AgentName, func(r *Rollout) {
+		r.Phase = phase
+		r.Error = message
+	})
+	rollout, _ := am.rollouts.get(request.AgentName)
+	am.emitEvent(LifecycleEvent{
+		Type:      EventRolloutFinished,
+		Agent:     request.AgentName,
+		PID:       rollout.GreenPID,
+		Reason:    string(phase),
+		StartedAt: &rollout.StartedAt,
+		Details: map[string]interface{}{
+			"blue_pid": rollout.BluePID,
+			"error":    message,
+		},
+	})
+
+	if phase == RolloutCompleted {
+		fmt.Printf("%s: Rolling restart of %s completed\n", am.AgentID, request.AgentName)
+		am.reply(request, map[string]interface{}{
+			"event":   "rollout_finished",
+			"status":  "restarted",
+			"agent":   request.AgentName,
+			"rollout": rollout,
+		})
+		return
+	}
+
+	fmt.Printf("%s: Rolling restart of %s rolled back: %s\n", am.AgentID, request.AgentName, message)
+	am.replyError(request, ErrCodeRolloutFailed, message, map[string]interface{}{
+		"event":   "rollout_finished",
+		"rollout": rollout,
+	})
+}
+
+// rejectDuringRollout replies with rollout_in_progress if the agent is mid-rollout
+func (am *AgentManager) rejectDuringRollout(request AgentRequest) bool {
+	rollout, rolling := am.rollouts.get(request.AgentName)
+	if !rolling {
+		return false
+	}
+	am.replyError(request, ErrCodeRolloutInProgress, fmt.Sprintf("agent %s has a rollout in progress", request.AgentName), map[string]interface{}{
+		"rollout": rollout,
+	})
+	return true
+}
+
+// rolloutStatus returns the in-progress rollout for status replies, or nil
+func (am *AgentManager) rolloutStatus(agentName string) interface{} {
+	if rollout, rolling := am.rollouts.get(agentName); rolling {
+		return rollout
+	}
+	return nil
+}
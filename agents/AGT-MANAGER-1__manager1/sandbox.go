@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SandboxConfig declares the resource limits and isolation applied to an agent process
+type SandboxConfig struct {
+	MaxCPUSeconds   uint64   `json:"max_cpu_seconds,omitempty"`    // RLIMIT_CPU, 0 = unlimited
+	MaxMemoryMB     uint64   `json:"max_memory_mb,omitempty"`      // cgroup memory.max, falls back to RLIMIT_DATA
+	MaxOpenFiles    uint64   `json:"max_open_files,omitempty"`     // RLIMIT_NOFILE
+	CPUQuota        float64  `json:"cpu_quota,omitempty"`          // cgroup cpu.max in cores (e.g. 0.5), ignored without cgroup v2
+	WorkDirJail     string   `json:"workdir_jail,omitempty"`       // agent directory must resolve inside this root (placement check, not containment)
+	EnvAllowList    []string `json:"env_allow_list,omitempty"`     // manager env vars passed through, nil = inherit all
+	MaxTaskDataSize int      `json:"max_task_data_size,omitempty"` // bytes allowed in queued task data, 0 = default
+}
+
+const (
+	defaultMaxTaskDataSize = 64 * 1024
+	cgroupRoot             = "/sys/fs/cgroup"
+	cgroupParent           = "centerfire-agents"
+)
+
+// baseEnvAllowList is always passed through so `go build` keeps working inside the sandbox
+var baseEnvAllowList = []string{"PATH", "HOME", "USER", "TMPDIR", "GOPATH", "GOROOT", "GOCACHE", "GOMODCACHE", "GOFLAGS", "GOPROXY"}
+
+// agentCommand is an agent process prepared to start inside its sandbox
+type agentCommand struct {
+	*exec.Cmd
+	Cgroup    string   // cgroup v2 path the process starts in, empty when limits are not enforced there
+	cgroupDir *os.File // held open until Start, which clones the process directly into the cgroup
+}
+
+// Start starts the process and releases the cgroup handle; a cgroup nothing started in is removed
+func (c *agentCommand) Start() error {
+	err := c.Cmd.Start()
+	if c.cgroupDir != nil {
+		c.cgroupDir.Close()
+		c.cgroupDir = nil
+	}
+	if err != nil {
+		removeCgroup(c.Cgroup)
+	}
+	return err
+}
+
+// buildAgentCommand compiles the agent and prepares its sandboxed command. instanceName names the
+// cgroup, so concurrent instances of one agent get their own.
+func (am *AgentManager) buildAgentCommand(agentDef *AgentDefinition, instanceName string, extraEnv []string) (*agentCommand, error) {
+	sandbox := agentDef.Sandbox
+
+	directory, err := resolveAgentDirectory(agentDef.Directory, sandbox)
+	if err != nil {
+		return nil, err
+	}
+
+	// go build needs TMPDIR to exist; inside the jail nothing else creates it
+	if sandbox != nil && sandbox.WorkDirJail != "" && sandbox.EnvAllowList != nil {
+		if err := os.MkdirAll(jailTempDir(sandbox), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create temp directory in workdir jail: %v", err)
+		}
+	}
+	env := append(filterEnvironment(os.Environ(), sandbox), extraEnv...)
+
+	// Compile outside the limits, which are sized for the agent rather than the toolchain
+	binary, err := buildAgentBinary(agentDef.Name, directory, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// The shell applies rlimits via the ulimit builtin, then execs the agent in its place
+	cmd := exec.Command("/bin/sh", "-c", ulimitPrefix(sandbox)+`exec "$0"`, binary)
+	cmd.Dir = directory
+	cmd.Env = env
+
+	// Dedicated process group so stop/kill also reaches anything the agent forks
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	command := &agentCommand{Cmd: cmd}
+	group, dir, err := prepareCgroup(instanceName, sandbox)
+	if err != nil {
+		fmt.Printf("Warning: Failed to apply cgroup limits to %s: %v\n", instanceName, err)
+	} else if dir != nil {
+		startInCgroup(cmd.SysProcAttr, int(dir.Fd()))
+		command.Cgroup = group
+		command.cgroupDir = dir
+	}
+
+	return command, nil
+}
+
+// buildAgentBinary compiles the agent in directory, as `go run *.go` used to on every start, and
+// returns the binary's path. Each build goes to a temporary name first so that instances starting
+// concurrently never exec a half-written file.
+func buildAgentBinary(name, directory string, env []string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	binDir := filepath.Join(cacheDir, "centerfire", "agents")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create agent build directory: %v", err)
+	}
+
+	binary := filepath.Join(binDir, sanitizeCgroupName(name))
+	tmp, err := os.CreateTemp(binDir, sanitizeCgroupName(name)+".build-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create agent binary: %v", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	build := exec.Command("/bin/sh", "-c", `exec go build -o "$0" *.go`, tmp.Name())
+	build.Dir = directory
+	build.Env = env
+	if output, err := build.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to build %s: %v: %s", name, err, strings.TrimSpace(string(output)))
+	}
+
+	if err := os.Rename(tmp.Name(), binary); err != nil {
+		return "", fmt.Errorf("failed to install agent binary: %v", err)
+	}
+	return binary, nil
+}
+
+// resolveAgentDirectory validates the agent directory against the working-directory jail. This is
+// a placement check, not a containment boundary: the agent runs as the manager's user and can
+// still reach any path that user can.
+func resolveAgentDirectory(directory string, sandbox *SandboxConfig) (string, error) {
+	if sandbox == nil || sandbox.WorkDirJail == "" {
+		return directory, nil
+	}
+
+	jail, err := filepath.EvalSymlinks(sandbox.WorkDirJail)
+	if err != nil {
+		return "", fmt.Errorf("invalid workdir jail %s: %v", sandbox.WorkDirJail, err)
+	}
+	resolved, err := filepath.EvalSymlinks(directory)
+	if err != nil {
+		return "", fmt.Errorf("invalid agent directory %s: %v", directory, err)
+	}
+
+	rel, err := filepath.Rel(jail, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("agent directory %s escapes workdir jail %s", directory, sandbox.WorkDirJail)
+	}
+
+	return resolved, nil
+}
+
+// filterEnvironment keeps only allow-listed variables when the sandbox declares an allow-list
+func filterEnvironment(environ []string, sandbox *SandboxConfig) []string {
+	if sandbox == nil || sandbox.EnvAllowList == nil {
+		return environ
+	}
+
+	allowed := make(map[string]bool)
+	for _, name := range baseEnvAllowList {
+		allowed[name] = true
+	}
+	for _, name := range sandbox.EnvAllowList {
+		allowed[name] = true
+	}
+
+	filtered := make([]string, 0, len(allowed))
+	for _, kv := range environ {
+		name := kv
+		if idx := strings.Index(kv, "="); idx >= 0 {
+			name = kv[:idx]
+		}
+		if allowed[name] {
+			filtered = append(filtered, kv)
+		}
+	}
+
+	if sandbox.WorkDirJail != "" {
+		filtered = append(filtered, fmt.Sprintf("TMPDIR=%s", jailTempDir(sandbox)))
+	}
+
+	return filtered
+}
+
+// jailTempDir is the TMPDIR given to agents whose environment is filtered inside a workdir jail
+func jailTempDir(sandbox *SandboxConfig) string {
+	return filepath.Join(sandbox.WorkDirJail, "tmp")
+}
+
+// ulimitPrefix renders the shell ulimit calls for the sandbox's rlimits
+func ulimitPrefix(sandbox *SandboxConfig) string {
+	if sandbox == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	if sandbox.MaxCPUSeconds > 0 {
+		fmt.Fprintf(&b, "ulimit -t %d || exit 126; ", sandbox.MaxCPUSeconds)
+	}
+	if sandbox.MaxOpenFiles > 0 {
+		fmt.Fprintf(&b, "ulimit -n %d || exit 126; ", sandbox.MaxOpenFiles)
+	}
+	// Data-segment limit only when cgroup v2 cannot enforce memory for us. Not RLIMIT_AS: the Go
+	// runtime reserves far more address space than it uses and will not start under a tight one.
+	if sandbox.MaxMemoryMB > 0 && !cgroupV2Available() {
+		fmt.Fprintf(&b, "ulimit -d %d || exit 126; ", sandbox.MaxMemoryMB*1024)
+	}
+	return b.String()
+}
+
+// checkTaskData enforces the size limit on queued ephemeral task data
+func checkTaskData(taskJSON []byte, sandbox *SandboxConfig) error {
+	limit := defaultMaxTaskDataSize
+	if sandbox != nil && sandbox.MaxTaskDataSize > 0 {
+		limit = sandbox.MaxTaskDataSize
+	}
+	if len(taskJSON) > limit {
+		return fmt.Errorf("task data is %d bytes, exceeds limit of %d", len(taskJSON), limit)
+	}
+	return nil
+}
+
+// cgroupV2Available reports whether a writable unified cgroup hierarchy is mounted
+func cgroupV2Available() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return false
+	}
+	return syscall.Access(cgroupRoot, 0x2) == nil // W_OK
+}
+
+// prepareCgroup creates the instance's cgroup with memory and CPU limits and opens it, so the
+// process can be started inside it before it has a chance to fork. It returns a nil handle when
+// the sandbox sets no cgroup limits or cgroup v2 is unavailable.
+func prepareCgroup(instanceName string, sandbox *SandboxConfig) (string, *os.File, error) {
+	if sandbox == nil || (sandbox.MaxMemoryMB == 0 && sandbox.CPUQuota == 0) || !cgroupV2Available() {
+		return "", nil, nil
+	}
+
+	parent := filepath.Join(cgroupRoot, cgroupParent)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create cgroup %s: %v", parent, err)
+	}
+	// Delegate controllers to the per-agent groups; ignore errors if already enabled
+	os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+memory +cpu"), 0644)
+
+	group := filepath.Join(parent, sanitizeCgroupName(instanceName))
+	if err := os.MkdirAll(group, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create cgroup %s: %v", group, err)
+	}
+
+	if sandbox.MaxMemoryMB > 0 {
+		limit := strconv.FormatUint(sandbox.MaxMemoryMB*1024*1024, 10)
+		if err := os.WriteFile(filepath.Join(group, "memory.max"), []byte(limit), 0644); err != nil {
+			removeCgroup(group)
+			return "", nil, fmt.Errorf("failed to set memory.max: %v", err)
+		}
+	}
+	if sandbox.CPUQuota > 0 {
+		period := 100000
+		quota := fmt.Sprintf("%d %d", int(sandbox.CPUQuota*float64(period)), period)
+		if err := os.WriteFile(filepath.Join(group, "cpu.max"), []byte(quota), 0644); err != nil {
+			removeCgroup(group)
+			return "", nil, fmt.Errorf("failed to set cpu.max: %v", err)
+		}
+	}
+
+	dir, err := os.Open(group)
+	if err != nil {
+		removeCgroup(group)
+		return "", nil, fmt.Errorf("failed to open cgroup %s: %v", group, err)
+	}
+	return group, dir, nil
+}
+
+// removeCgroup deletes an agent cgroup once its processes have exited
+func removeCgroup(group string) {
+	if group == "" {
+		return
+	}
+	if err := os.Remove(group); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Warning: Failed to remove cgroup %s: %v\n", group, err)
+	}
+}
+
+func sanitizeCgroupName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '.' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// terminateProcessGroup sends SIGTERM to the agent's process group, escalating to SIGKILL after grace
+func terminateProcessGroup(cmd *exec.Cmd, grace time.Duration, exited func() bool) {
+	if cmd == nil || cmd.Process == nil {
+		return
+	}
+
+	pid := cmd.Process.Pid
+	pgid, err := syscall.Getpgid(pid)
+	if err != nil || pgid != pid {
+		// Not a group leader (e.g. adopted process); signal the process only
+		cmd.Process.Signal(syscall.SIGTERM)
+		time.Sleep(grace)
+		if !exited() {
+			cmd.Process.Kill()
+		}
+		return
+	}
+
+	syscall.Kill(-pgid, syscall.SIGTERM)
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		if exited() {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// Kill stragglers in the group even if the leader exited cleanly
+	syscall.Kill(-pgid, syscall.SIGKILL)
+}
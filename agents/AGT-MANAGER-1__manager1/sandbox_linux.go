@@ -0,0 +1,11 @@
+//go:build linux
+
+package main
+
+import "syscall"
+
+// startInCgroup makes the process start inside the cgroup open as fd (CLONE_INTO_CGROUP)
+func startInCgroup(attr *syscall.SysProcAttr, fd int) {
+	attr.UseCgroupFD = true
+	attr.CgroupFD = fd
+}
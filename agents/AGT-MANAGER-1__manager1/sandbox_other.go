@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+import "syscall"
+
+// startInCgroup is never reached off Linux: cgroupV2Available reports false there
+func startInCgroup(attr *syscall.SysProcAttr, fd int) {}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// agentState is the single guarded store for the manager's agent maps.
+// Callers receive copies of AgentProcess so no goroutine reads a record while another mutates it;
+// mutations go through the methods below. Definitions are replaced wholesale, never edited in place.
+type agentState struct {
+	mu       sync.RWMutex
+	agents   map[string]*AgentProcess    // processes launched by this manager, keyed by instance name
+	running  map[string]*AgentProcess    // PID-based tracking of self-registered running agents
+	registry map[string]*AgentDefinition // agent registry for ephemeral lifecycle
+
+	opsMu sync.Mutex
+	ops   map[string]*sync.Mutex // per-agent lifecycle locks serialising start/stop/restart
+}
+
+func newAgentState() *agentState {
+	return &agentState{
+		agents:   make(map[string]*AgentProcess),
+		running:  make(map[string]*AgentProcess),
+		registry: make(map[string]*AgentDefinition),
+		ops:      make(map[string]*sync.Mutex),
+	}
+}
+
+// lockAgent serialises lifecycle operations on one agent; call the returned func to release
+func (s *agentState) lockAgent(name string) func() {
+	s.opsMu.Lock()
+	op, exists := s.ops[name]
+	if !exists {
+		op = &sync.Mutex{}
+		s.ops[name] = op
+	}
+	s.opsMu.Unlock()
+
+	op.Lock()
+	return op.Unlock
+}
+
+// Managed processes
+
+func (s *agentState) putManaged(name string, process *AgentProcess) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.agents[name] = process
+}
+
+func (s *agentState) getManaged(name string) (AgentProcess, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	process, exists := s.agents[name]
+	if !exists {
+		return AgentProcess{}, false
+	}
+	return *process, true
+}
+
+func (s *agentState) removeManaged(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.agents, name)
+}
+
+// removeManagedIf deletes the record only if it still belongs to the given PID
+func (s *agentState) removeManagedIf(name string, pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if process, exists := s.agents[name]; exists && process.PID == pid {
+		delete(s.agents, name)
+	}
+}
+
+// markManagedStopped flags the process as no longer running if it still belongs to the given PID
+func (s *agentState) markManagedStopped(name string, pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if process, exists := s.agents[name]; exists && process.PID == pid {
+		process.Running = false
+	}
+}
+
+// renameManaged moves a process record to a new key, replacing whatever was there
+func (s *agentState) renameManaged(from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if process, exists := s.agents[from]; exists {
+		s.agents[to] = process
+		delete(s.agents, from)
+	}
+}
+
+func (s *agentState) listManaged() map[string]AgentProcess {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot := make(map[string]AgentProcess, len(s.agents))
+	for name, process := range s.agents {
+		snapshot[name] = *process
+	}
+	return snapshot
+}
+
+// Registered running agents
+
+func (s *agentState) putRunning(name string, process *AgentProcess) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running[name] = process
+}
+
+func (s *agentState) getRunning(name string) (AgentProcess, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	process, exists := s.running[name]
+	if !exists {
+		return AgentProcess{}, false
+	}
+	return *process, true
+}
+
+func (s *agentState) removeRunning(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, name)
+}
+
+// removeRunningIf deletes the registration only if it still belongs to the given PID,
+// so a stale cleanup never removes an agent that re-registered in the meantime
+func (s *agentState) removeRunningIf(name string, pid int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if process, exists := s.running[name]; exists && process.PID == pid {
+		delete(s.running, name)
+		return true
+	}
+	return false
+}
+
+// touchHeartbeat records a heartbeat and returns the updated registration
+func (s *agentState) touchHeartbeat(name string, at time.Time) (AgentProcess, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	process, exists := s.running[name]
+	if !exists {
+		return AgentProcess{}, false
+	}
+	process.LastHeartbeat = at
+	return *process, true
+}
+
+func (s *agentState) listRunning() map[string]AgentProcess {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot := make(map[string]AgentProcess, len(s.running))
+	for name, process := range s.running {
+		snapshot[name] = *process
+	}
+	return snapshot
+}
+
+func (s *agentState) countRunning() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.running)
+}
+
+// Agent registry
+
+func (s *agentState) getDefinition(name string) (*AgentDefinition, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	def, exists := s.registry[name]
+	return def, exists
+}
+
+// putDefinition registers or replaces a definition, reporting whether it already existed
+func (s *agentState) putDefinition(def *AgentDefinition) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, existed := s.registry[def.Name]
+	s.registry[def.Name] = def
+	return existed
+}
+
+func (s *agentState) listDefinitions() map[string]*AgentDefinition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot := make(map[string]*AgentDefinition, len(s.registry))
+	for name, def := range s.registry {
+		snapshot[name] = def
+	}
+	return snapshot
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePID hands out PIDs that never belong to a real process, one per (re)start of an agent
+func fakePID(agent, generation int) int {
+	return 1_000_000 + agent*10_000 + generation
+}
+
+// Register, heartbeat, stop and restart the same agents from many goroutines while readers walk
+// the snapshots. Run with -race: every access must go through agentState's lock.
+func TestAgentStateConcurrentLifecycle(t *testing.T) {
+	const (
+		agents  = 8
+		rounds  = 200
+		readers = 4
+	)
+	s := newAgentState()
+	names := make([]string, agents)
+	for i := range names {
+		names[i] = fmt.Sprintf("AGT-FAKE-%d", i)
+		s.putDefinition(&AgentDefinition{Name: names[i], Type: PersistentAgent})
+	}
+
+	var wg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < rounds; n++ {
+				// Snapshots are copies, so reading them must not race with the writers below
+				for _, process := range s.listRunning() {
+					_ = process.LastHeartbeat
+				}
+				for _, process := range s.listManaged() {
+					_ = process.Running
+				}
+				s.countRunning()
+				s.listDefinitions()
+			}
+		}()
+	}
+
+	for i, name := range names {
+		i, name := i, name
+		pid := func(generation int) int { return fakePID(i, generation) }
+
+		// Self-registration
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < rounds; n++ {
+				s.putRunning(name, &AgentProcess{Name: name, PID: pid(n), Running: true, LastHeartbeat: time.Now()})
+			}
+		}()
+
+		// Heartbeats, arriving on their own goroutine as they do from Redis
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < rounds; n++ {
+				s.touchHeartbeat(name, time.Now())
+				s.getRunning(name)
+			}
+		}()
+
+		// Stops of whatever instance is current
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < rounds; n++ {
+				unlock := s.lockAgent(name)
+				if process, ok := s.getManaged(name); ok {
+					s.markManagedStopped(name, process.PID)
+				}
+				if process, ok := s.getRunning(name); ok {
+					s.removeRunningIf(name, process.PID)
+				}
+				unlock()
+			}
+		}()
+
+		// Restarts: replace the managed instance with a new PID, then reap the old one
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < rounds; n++ {
+				unlock := s.lockAgent(name)
+				old, hadOld := s.getManaged(name)
+				s.putManaged(name, &AgentProcess{Name: name, PID: pid(rounds + n), Running: true, StartTime: time.Now()})
+				if hadOld {
+					// A late monitor for the old process must not touch the new one
+					s.markManagedStopped(name, old.PID)
+					s.removeManagedIf(name, old.PID)
+				}
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		process, ok := s.getManaged(name)
+		if !ok {
+			t.Fatalf("%s: managed record lost", name)
+		}
+		if process.PID < fakePID(i, rounds) || process.PID >= fakePID(i, 2*rounds) {
+			t.Errorf("%s: managed PID %d is not from a restart", name, process.PID)
+		}
+	}
+}
+
+// Restarts under lockAgent must never interleave: each one sees the PID the previous one set.
+func TestAgentStateLockAgentSerialisesRestarts(t *testing.T) {
+	const restarts = 500
+	s := newAgentState()
+	name := "AGT-FAKE-RESTART"
+	s.putManaged(name, &AgentProcess{Name: name, PID: fakePID(0, 0), Running: true})
+
+	var wg sync.WaitGroup
+	for n := 0; n < restarts; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := s.lockAgent(name)
+			defer unlock()
+			current, _ := s.getManaged(name)
+			s.markManagedStopped(name, current.PID)
+			s.putManaged(name, &AgentProcess{Name: name, PID: current.PID + 1, Running: true})
+		}()
+	}
+	wg.Wait()
+
+	process, _ := s.getManaged(name)
+	if want := fakePID(0, restarts); process.PID != want || !process.Running {
+		t.Errorf("after %d restarts got PID %d running=%t, want PID %d running", restarts, process.PID, process.Running, want)
+	}
+}
+
+// A stale cleanup for a dead PID must leave an agent that re-registered with a new PID alone.
+func TestAgentStateStaleCleanupKeepsNewInstance(t *testing.T) {
+	s := newAgentState()
+	name := "AGT-FAKE-STALE"
+	oldPID, newPID := fakePID(1, 0), fakePID(1, 1)
+
+	s.putRunning(name, &AgentProcess{Name: name, PID: oldPID, Running: true})
+	s.putManaged(name, &AgentProcess{Name: name, PID: oldPID, Running: true})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.putRunning(name, &AgentProcess{Name: name, PID: newPID, Running: true})
+		s.putManaged(name, &AgentProcess{Name: name, PID: newPID, Running: true})
+	}()
+	go func() {
+		defer wg.Done()
+		s.markManagedStopped(name, oldPID)
+		s.removeRunningIf(name, oldPID)
+		s.removeManagedIf(name, oldPID)
+	}()
+	wg.Wait()
+
+	// Whichever order the two ran in, the cleanup only ever removed the old PID
+	if process, ok := s.getRunning(name); ok && process.PID != newPID {
+		t.Errorf("running registration has PID %d, want %d or none", process.PID, newPID)
+	}
+	if process, ok := s.getManaged(name); ok && (process.PID != newPID || !process.Running) {
+		t.Errorf("managed record has PID %d running=%t, want PID %d running", process.PID, process.Running, newPID)
+	}
+
+	s.putRunning(name, &AgentProcess{Name: name, PID: newPID, Running: true})
+	if s.removeRunningIf(name, oldPID) {
+		t.Error("removeRunningIf removed a registration belonging to another PID")
+	}
+	if _, ok := s.getRunning(name); !ok {
+		t.Error("registration for the new PID was removed")
+	}
+}
+
+// Callers get copies: editing one must not change the stored record.
+func TestAgentStateReturnsCopies(t *testing.T) {
+	s := newAgentState()
+	name := "AGT-FAKE-COPY"
+	beat := time.Now()
+	s.putRunning(name, &AgentProcess{Name: name, PID: fakePID(2, 0), LastHeartbeat: beat})
+
+	process, _ := s.getRunning(name)
+	process.PID = 0
+	process.LastHeartbeat = time.Time{}
+	snapshot := s.listRunning()
+	snapshot[name] = AgentProcess{}
+
+	stored, _ := s.getRunning(name)
+	if stored.PID != fakePID(2, 0) || !stored.LastHeartbeat.Equal(beat) {
+		t.Errorf("stored record changed through a copy: PID %d heartbeat %v", stored.PID, stored.LastHeartbeat)
+	}
+}
@@ -0,0 +1,604 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Ephemeral task queue: one Redis stream per ephemeral agent type, consumed by a pool of
+// workers on the leader. Each worker leases a task through the consumer group, runs one agent
+// process for it, records the result and exit code, then acks. Unacked tasks left by a crashed
+// worker or manager are reclaimed once their lease expires; running tasks renew it.
+const (
+	taskStreamPrefix       = "centerfire:tasks:stream:"
+	taskStatusPrefix       = "centerfire:tasks:status:"
+	taskConsumerGroup      = "agent-manager"
+	taskStatusTTL          = 24 * time.Hour
+	taskReadBlock          = 5 * time.Second
+	taskLease              = 2 * time.Minute // unacked tasks idle this long are reclaimed
+	taskLeaseRenewEvery    = 30 * time.Second
+	defaultTaskPoolSize    = 1
+	defaultTaskMaxAttempts = 3
+	taskOutputLimit        = 64 * 1024
+	taskStopGrace          = 5 * time.Second
+)
+
+// TaskState is the lifecycle state of a queued ephemeral task
+type TaskState string
+
+const (
+	TaskQueued    TaskState = "queued"
+	TaskRunning   TaskState = "running"
+	TaskRetrying  TaskState = "retrying"
+	TaskCompleted TaskState = "completed"
+	TaskFailed    TaskState = "failed"
+	TaskTimeout   TaskState = "timeout"
+)
+
+// TaskRecord is the persisted status of a task, returned by task_status and sent to the submitter
+type TaskRecord struct {
+	TaskID          string          `json:"task_id"`
+	AgentName       string          `json:"agent_name"`
+	State           TaskState       `json:"state"`
+	Attempt         int             `json:"attempt"`
+	MaxAttempts     int             `json:"max_attempts"`
+	ExitCode        *int            `json:"exit_code,omitempty"`
+	Result          json.RawMessage `json:"result,omitempty"` // JSON the agent wrote to TASK_RESULT_FILE
+	Output          string          `json:"output,omitempty"` // tail of stdout/stderr
+	Error           string          `json:"error,omitempty"`
+	Instance        string          `json:"instance,omitempty"`
+	SubmittedAt     time.Time       `json:"submitted_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+	DurationMs      int64           `json:"duration_ms,omitempty"`
+	RequestID       string          `json:"request_id,omitempty"`
+	ResponseChannel string          `json:"response_channel,omitempty"`
+}
+
+// queuedTask is one stream entry
+type queuedTask struct {
+	MessageID       string
+	TaskID          string
+	AgentName       string
+	TaskData        string
+	Attempt         int
+	RequestID       string
+	ResponseChannel string
+	SubmittedAt     time.Time
+}
+
+// taskPools tracks how many workers run per ephemeral agent type
+type taskPools struct {
+	mu      sync.Mutex
+	workers map[string]int
+}
+
+func newTaskPools() *taskPools {
+	return &taskPools{workers: make(map[string]int)}
+}
+
+func taskStream(agentName string) string {
+	return taskStreamPrefix + agentName
+}
+
+func taskStatusKey(taskID string) string {
+	return taskStatusPrefix + taskID
+}
+
+// poolSize returns the configured worker count for an ephemeral agent
+func (def *AgentDefinition) poolSize() int {
+	if def.PoolSize > 0 {
+		return def.PoolSize
+	}
+	return defaultTaskPoolSize
+}
+
+// maxAttempts returns how many times a crashing task is run before it is failed
+func (def *AgentDefinition) maxAttempts() int {
+	if def.MaxAttempts > 0 {
+		return def.MaxAttempts
+	}
+	return defaultTaskMaxAttempts
+}
+
+// startTaskWorkers starts worker pools for every registered ephemeral agent
+func (am *AgentManager) startTaskWorkers() {
+	for _, agentDef := range am.state.listDefinitions() {
+		am.ensureTaskPool(agentDef)
+	}
+}
+
+// ensureTaskPool creates the agent's stream group and grows its pool to the configured size.
+// Pools never shrink while the manager runs; a smaller PoolSize applies after restart.
+func (am *AgentManager) ensureTaskPool(agentDef *AgentDefinition) {
+	if agentDef.Type != EphemeralAgent {
+		return
+	}
+
+	err := am.RedisClient.XGroupCreateMkStream(am.ctx, taskStream(agentDef.Name), taskConsumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		fmt.Printf("Warning: Failed to create task group for %s: %v\n", agentDef.Name, err)
+		return
+	}
+
+	am.tasks.mu.Lock()
+	defer am.tasks.mu.Unlock()
+	for worker := am.tasks.workers[agentDef.Name]; worker < agentDef.poolSize(); worker++ {
+		go am.taskWorker(agentDef.Name, worker)
+		am.tasks.workers[agentDef.Name] = worker + 1
+	}
+}
+
+// enqueueTask validates and appends a task to the agent's stream
+func (am *AgentManager) enqueueTask(agentDef *AgentDefinition, request AgentRequest, taskID string) (*TaskRecord, error) {
+	taskJSON := []byte("{}")
+	if request.TaskData != nil {
+		var err error
+		if taskJSON, err = json.Marshal(request.TaskData); err != nil {
+			return nil, newManagerError(ErrCodeInvalidRequest, "invalid task data for %s: %v", taskID, err)
+		}
+	}
+	if err := checkTaskData(taskJSON, agentDef.Sandbox); err != nil {
+		return nil, newManagerError(ErrCodeInvalidRequest, "rejected task data for %s: %v", taskID, err)
+	}
+
+	record := &TaskRecord{
+		TaskID:          taskID,
+		AgentName:       agentDef.Name,
+		State:           TaskQueued,
+		Attempt:         1,
+		MaxAttempts:     agentDef.maxAttempts(),
+		SubmittedAt:     time.Now(),
+		RequestID:       request.RequestID,
+		ResponseChannel: request.ResponseChannel,
+	}
+
+	am.ensureTaskPool(agentDef)
+	err := am.RedisClient.XAdd(am.ctx, &redis.XAddArgs{
+		Stream: taskStream(agentDef.Name),
+		Values: map[string]interface{}{
+			"task_id":          taskID,
+			"agent_name":       agentDef.Name,
+			"task_data":        string(taskJSON),
+			"attempt":          record.Attempt,
+			"request_id":       request.RequestID,
+			"response_channel": request.ResponseChannel,
+			"submitted_at":     record.SubmittedAt.UnixNano(),
+		},
+	}).Err()
+	if err != nil {
+		return nil, newManagerError(ErrCodeInternal, "failed to queue task %s: %v", taskID, err)
+	}
+
+	am.saveTaskRecord(record)
+	am.emitEvent(LifecycleEvent{Type: EventTaskQueued, Agent: agentDef.Name, Details: map[string]interface{}{"task_id": taskID}})
+	return record, nil
+}
+
+// taskWorker leases and runs tasks for one agent type while this manager is leader
+func (am *AgentManager) taskWorker(agentName string, worker int) {
+	consumer := fmt.Sprintf("%s-w%d", am.managerID, worker)
+	stream := taskStream(agentName)
+
+	for !am.draining.Load() {
+		if !am.isLeader() {
+			time.Sleep(leaderRenewEvery)
+			continue
+		}
+		agentDef, exists := am.state.getDefinition(agentName)
+		if !exists || agentDef.Type != EphemeralAgent {
+			time.Sleep(taskReadBlock)
+			continue
+		}
+
+		task, reclaimed, ok := am.leaseTask(stream, consumer)
+		if !ok {
+			continue
+		}
+
+		stopRenewal := am.renewTaskLease(stream, consumer, task.MessageID)
+		am.processTask(agentDef, stream, task, reclaimed)
+		stopRenewal()
+	}
+}
+
+// leaseTask reclaims an expired lease if there is one, otherwise waits for a new task
+func (am *AgentManager) leaseTask(stream, consumer string) (queuedTask, bool, bool) {
+	claimed, _, err := am.RedisClient.XAutoClaim(am.ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    taskConsumerGroup,
+		Consumer: consumer,
+		MinIdle:  taskLease,
+		Start:    "0-0",
+		Count:    1,
+	}).Result()
+	if err == nil && len(claimed) > 0 {
+		return parseQueuedTask(claimed[0]), true, true
+	}
+
+	streams, err := am.RedisClient.XReadGroup(am.ctx, &redis.XReadGroupArgs{
+		Group:    taskConsumerGroup,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    1,
+		Block:    taskReadBlock,
+	}).Result()
+	if err != nil {
+		if err != redis.Nil {
+			time.Sleep(time.Second) // Redis unavailable; avoid spinning
+		}
+		return queuedTask{}, false, false
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return queuedTask{}, false, false
+	}
+	return parseQueuedTask(streams[0].Messages[0]), false, true
+}
+
+// renewTaskLease re-claims the entry for this consumer periodically, resetting its idle time
+// so long-running tasks are not reclaimed; call the returned func once the task is done
+func (am *AgentManager) renewTaskLease(stream, consumer, messageID string) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(taskLeaseRenewEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				am.RedisClient.XClaimJustID(am.ctx, &redis.XClaimArgs{
+					Stream:   stream,
+					Group:    taskConsumerGroup,
+					Consumer: consumer,
+					Messages: []string{messageID},
+				})
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func parseQueuedTask(msg redis.XMessage) queuedTask {
+	field := func(name string) string {
+		value, _ := msg.Values[name].(string)
+		return value
+	}
+	task := queuedTask{
+		MessageID:       msg.ID,
+		TaskID:          field("task_id"),
+		AgentName:       field("agent_name"),
+		TaskData:        field("task_data"),
+		RequestID:       field("request_id"),
+		ResponseChannel: field("response_channel"),
+	}
+	task.Attempt, _ = strconv.Atoi(field("attempt"))
+	if task.Attempt < 1 {
+		task.Attempt = 1
+	}
+	if nanos, err := strconv.ParseInt(field("submitted_at"), 10, 64); err == nil {
+		task.SubmittedAt = time.Unix(0, nanos)
+	}
+	return task
+}
+
+// processTask runs a leased task and decides between ack, retry and failure
+func (am *AgentManager) processTask(agentDef *AgentDefinition, stream string, task queuedTask, reclaimed bool) {
+	if reclaimed {
+		// The previous holder never acked: its worker or manager died mid-task
+		fmt.Printf("%s: Reclaimed task %s (attempt %d) from an expired lease\n", am.AgentID, task.TaskID, task.Attempt)
+		task.Attempt++
+	}
+
+	record := &TaskRecord{
+		TaskID:          task.TaskID,
+		AgentName:       agentDef.Name,
+		Attempt:         task.Attempt,
+		MaxAttempts:     agentDef.maxAttempts(),
+		SubmittedAt:     task.SubmittedAt,
+		RequestID:       task.RequestID,
+		ResponseChannel: task.ResponseChannel,
+	}
+
+	if task.Attempt > record.MaxAttempts {
+		record.State = TaskFailed
+		record.Error = fmt.Sprintf("task crashed %d times", record.MaxAttempts)
+		am.finishTask(stream, task, record)
+		return
+	}
+
+	crashed := am.executeTask(agentDef, task, record)
+	if am.draining.Load() {
+		// Manager shutdown killed the process; leave the task unacked for the next leader
+		return
+	}
+
+	if crashed && task.Attempt < record.MaxAttempts {
+		record.State = TaskRetrying
+		am.saveTaskRecord(record)
+
+		err := am.RedisClient.XAdd(am.ctx, &redis.XAddArgs{
+			Stream: stream,
+			Values: map[string]interface{}{
+				"task_id":          task.TaskID,
+				"agent_name":       task.AgentName,
+				"task_data":        task.TaskData,
+				"attempt":          task.Attempt + 1,
+				"request_id":       task.RequestID,
+				"response_channel": task.ResponseChannel,
+				"submitted_at":     task.SubmittedAt.UnixNano(),
+			},
+		}).Err()
+		if err != nil {
+			fmt.Printf("Warning: Failed to requeue task %s: %v\n", task.TaskID, err)
+			return // unacked; reclaimed after the lease expires
+		}
+		am.RedisClient.XAck(am.ctx, stream, taskConsumerGroup, task.MessageID)
+		fmt.Printf("%s: Task %s crashed on attempt %d - requeued\n", am.AgentID, task.TaskID, task.Attempt)
+		return
+	}
+
+	if crashed {
+		record.State = TaskFailed
+		if record.Error == "" {
+			record.Error = fmt.Sprintf("task crashed %d times", record.MaxAttempts)
+		}
+	}
+	am.finishTask(stream, task, record)
+}
+
+// executeTask runs one agent process for the task and fills in the record.
+// It reports a crash when the process died without producing a result: killed by a signal,
+// failed to start, or exited non-zero without writing TASK_RESULT_FILE.
+func (am *AgentManager) executeTask(agentDef *AgentDefinition, task queuedTask, record *TaskRecord) bool {
+	instanceName := fmt.Sprintf("%s_%s", agentDef.Name, task.TaskID)
+	record.Instance = instanceName
+
+	workDir, err := os.MkdirTemp("", "centerfire-task-")
+	if err != nil {
+		record.Error = fmt.Sprintf("failed to create task directory: %v", err)
+		return true
+	}
+	defer os.RemoveAll(workDir)
+
+	dataFile := filepath.Join(workDir, "task.json")
+	resultFile := filepath.Join(workDir, "result.json")
+	if err := os.WriteFile(dataFile, []byte(task.TaskData), 0600); err != nil {
+		record.Error = fmt.Sprintf("failed to write task data: %v", err)
+		return true
+	}
+
+	cmd, err := am.buildAgentCommand(agentDef, instanceName, []string{
+		"AGENT_TYPE=ephemeral",
+		fmt.Sprintf("TASK_ID=%s", task.TaskID),
+		fmt.Sprintf("TASK_ATTEMPT=%d", task.Attempt),
+		fmt.Sprintf("TASK_DATA_FILE=%s", dataFile),
+		fmt.Sprintf("TASK_RESULT_FILE=%s", resultFile),
+	})
+	if err != nil {
+		// Configuration problem; retrying will not help
+		record.State = TaskFailed
+		record.Error = fmt.Sprintf("failed to prepare %s: %v", instanceName, err)
+		return false
+	}
+
+	output := &tailBuffer{limit: taskOutputLimit}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Start(); err != nil {
+		record.Error = fmt.Sprintf("failed to start %s: %v", instanceName, err)
+		return true
+	}
+
+	startedAt := time.Now()
+	record.State = TaskRunning
+	record.StartedAt = &startedAt
+	am.saveTaskRecord(record)
+
+	process := &AgentProcess{
+		Name:      agentDef.Name,
+		Directory: cmd.Dir,
+		Process:   cmd.Cmd,
+		PID:       cmd.Process.Pid,
+		Running:   true,
+		StartTime: startedAt,
+		AgentType: EphemeralAgent,
+		TaskID:    task.TaskID,
+		Cgroup:    cmd.Cgroup,
+		exited:    make(chan struct{}),
+	}
+	am.state.putManaged(instanceName, process)
+	fmt.Printf("%s: Running task %s on %s (PID %d, attempt %d)\n", am.AgentID, task.TaskID, instanceName, process.PID, task.Attempt)
+	am.emitEvent(LifecycleEvent{
+		Type:     EventTaskStarted,
+		Agent:    agentDef.Name,
+		Instance: instanceName,
+		PID:      process.PID,
+		Details:  map[string]interface{}{"task_id": task.TaskID, "attempt": task.Attempt},
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+		close(process.exited)
+	}()
+
+	var timeoutChan <-chan time.Time
+	if agentDef.MaxRuntime > 0 {
+		timer := time.NewTimer(time.Duration(agentDef.MaxRuntime) * time.Second)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	timedOut := false
+	select {
+	case <-done:
+	case <-timeoutChan:
+		timedOut = true
+		fmt.Printf("%s: Task %s exceeded max runtime of %d seconds - killing %s\n", am.AgentID, task.TaskID, agentDef.MaxRuntime, instanceName)
+		terminateProcessGroup(cmd.Cmd, taskStopGrace, func() bool {
+			select {
+			case <-process.exited:
+				return true
+			default:
+				return false
+			}
+		})
+		<-done
+	}
+
+	finishedAt := time.Now()
+	record.FinishedAt = &finishedAt
+	record.DurationMs = finishedAt.Sub(startedAt).Milliseconds()
+	record.Output = output.String()
+	removeCgroup(cmd.Cgroup)
+	am.state.removeManagedIf(instanceName, process.PID)
+
+	exitCode := cmd.ProcessState.ExitCode() // -1 when killed by a signal
+	record.ExitCode = &exitCode
+
+	wroteResult := false
+	if data, err := os.ReadFile(resultFile); err == nil && len(data) > 0 {
+		if json.Valid(data) {
+			record.Result = json.RawMessage(data)
+		} else {
+			result, _ := json.Marshal(string(data))
+			record.Result = json.RawMessage(result)
+		}
+		wroteResult = true
+	}
+
+	switch {
+	case timedOut:
+		record.State = TaskTimeout
+		record.Error = fmt.Sprintf("exceeded max runtime of %d seconds", agentDef.MaxRuntime)
+		return false
+	case exitCode == 0:
+		record.State = TaskCompleted
+		return false
+	case wroteResult:
+		record.State = TaskFailed
+		record.Error = fmt.Sprintf("agent exited with code %d", exitCode)
+		return false
+	default:
+		record.Error = fmt.Sprintf("agent crashed (%s)", cmd.ProcessState.String())
+		return true
+	}
+}
+
+// finishTask persists the final record, reports it to the submitter and acks the stream entry
+func (am *AgentManager) finishTask(stream string, task queuedTask, record *TaskRecord) {
+	am.saveTaskRecord(record)
+
+	request := AgentRequest{
+		RequestType:     "spawn_ephemeral",
+		AgentName:       record.AgentName,
+		TaskID:          record.TaskID,
+		RequestID:       record.RequestID,
+		ResponseChannel: record.ResponseChannel,
+	}
+	response := map[string]interface{}{
+		"event":   "task_finished",
+		"status":  string(record.State),
+		"agent":   record.AgentName,
+		"task_id": record.TaskID,
+		"task":    record,
+	}
+	if code, failed := taskErrorCodes[record.State]; failed {
+		response["error"] = record.Error
+		response["error_code"] = code
+	}
+	am.reply(request, response)
+	am.emitEvent(LifecycleEvent{
+		Type:      EventTaskFinished,
+		Agent:     record.AgentName,
+		Instance:  record.Instance,
+		ExitCode:  record.ExitCode,
+		Reason:    string(record.State),
+		StartedAt: record.StartedAt,
+		Details:   map[string]interface{}{"task_id": record.TaskID, "attempt": record.Attempt, "error": record.Error},
+	})
+
+	am.RedisClient.XAck(am.ctx, stream, taskConsumerGroup, task.MessageID)
+	fmt.Printf("%s: Task %s %s (attempt %d)\n", am.AgentID, record.TaskID, record.State, record.Attempt)
+}
+
+// taskErrorCodes maps unsuccessful final task states to error codes
+var taskErrorCodes = map[TaskState]ManagerErrorCode{
+	TaskFailed:  ErrCodeTaskFailed,
+	TaskTimeout: ErrCodeTaskTimeout,
+}
+
+func (am *AgentManager) saveTaskRecord(record *TaskRecord) {
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	am.RedisClient.Set(am.ctx, taskStatusKey(record.TaskID), string(recordJSON), taskStatusTTL)
+}
+
+func (am *AgentManager) loadTaskRecord(taskID string) (*TaskRecord, error) {
+	data, err := am.RedisClient.Get(am.ctx, taskStatusKey(taskID)).Result()
+	if err == redis.Nil {
+		return nil, newManagerError(ErrCodeTaskNotFound, "Task %s not found", taskID)
+	}
+	if err != nil {
+		return nil, newManagerError(ErrCodeInternal, "failed to load task %s: %v", taskID, err)
+	}
+	var record TaskRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, newManagerError(ErrCodeInternal, "corrupt task record %s: %v", taskID, err)
+	}
+	return &record, nil
+}
+
+func (am *AgentManager) handleTaskStatus(request AgentRequest) {
+	if request.TaskID == "" {
+		am.replyError(request, ErrCodeInvalidRequest, "task_id is required", nil)
+		return
+	}
+	record, err := am.loadTaskRecord(request.TaskID)
+	if err != nil {
+		am.replyError(request, errorCode(err), err.Error(), map[string]interface{}{
+			"task_id": request.TaskID,
+		})
+		return
+	}
+	am.reply(request, map[string]interface{}{
+		"status":  "found",
+		"task_id": record.TaskID,
+		"task":    record,
+	})
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	data  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = b.data[len(b.data)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
@@ -3,11 +3,23 @@ module agt-naming-2
 go 1.25.1
 
 require (
+	centerfire/shared/agent v0.0.0
+	centerfire/shared/messages v0.0.0
+	github.com/alicebob/miniredis/v2 v2.35.0
+	github.com/oklog/ulid/v2 v2.1.1
 	github.com/redis/go-redis/v9 v9.13.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
+	centerfire/shared/config v0.0.0 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+)
+
+replace (
+	centerfire/shared/agent => ../../shared/agent
+	centerfire/shared/config => ../../shared/config
+	centerfire/shared/messages => ../../shared/messages
 )
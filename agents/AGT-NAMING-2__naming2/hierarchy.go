@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Modules and functions are named inside a capability: MOD-AUTH-1-token and FN-AUTH-1-issueToken
+// belong to CAP-AUTH-1, and their records point back at the parent CID so the hierarchy survives
+// renames. Graph IDs follow lexi:<Type>/<CapabilitySlug>/<Name>.
+
+// nameRecordKey is the Redis key of a stored name record, or "" for kinds without one
+func nameRecordKey(kind, slug string) string {
+	if slug == "" {
+		return ""
+	}
+	switch kind {
+	case "capability", "module", "function":
+		return fmt.Sprintf("centerfire.dev.names:%s:%s", kind, slug)
+	case "namespace":
+		// <project>.<env>.ns<N> lives under its environment
+		if match := namespacePattern.FindStringSubmatch(slug); match != nil {
+			return fmt.Sprintf("centerfire.%s.namespaces:%s", match[2], slug)
+		}
+	}
+	return ""
+}
+
+// loadNameRecord reads a stored name record
+func (a *NamingAgent) loadNameRecord(key string) (map[string]interface{}, error) {
+	data, err := a.redisClient.Get(a.ctx, key).Result()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("no name record at %s", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, fmt.Errorf("invalid name record at %s: %w", key, err)
+	}
+	return record, nil
+}
+
+// resolveParent finds the confirmed record of a capability or module given its slug, alias or CID
+func (a *NamingAgent) resolveParent(kind, ref string) (map[string]interface{}, error) {
+	key, record, err := a.resolveNameRecord(ref, kind)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", kind, ref, err)
+	}
+	if recordKind(key, record) != kind {
+		return nil, fmt.Errorf("%s is not a %s", ref, kind)
+	}
+	if recordStatus(record) == NameStatusReserved {
+		return nil, fmt.Errorf("%s %s is only reserved; confirm it first", kind, ref)
+	}
+	return record, nil
+}
+
+// findScopedName finds the module or function called name inside a capability, or returns a nil
+// record. Children are found through the capability index by CID, not by slug prefix.
+func (a *NamingAgent) findScopedName(kind, capabilityCID, name string) (string, map[string]interface{}, error) {
+	cids, err := a.redisClient.ZRange(a.ctx, nameIndexPrefix+"capability:"+capabilityCID, 0, -1).Result()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list names in %s: %w", capabilityCID, err)
+	}
+	for _, cid := range cids {
+		key, record, err := a.resolveNameRecord(cid, "")
+		if err != nil {
+			continue // an expired reservation the sweeper has not unindexed yet
+		}
+		if recordKind(key, record) == kind && getString(record, "name") == name {
+			return key, record, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// scopedError reports naming rule violations back to the caller
+func scopedError(kind, name string, v *nameValidation) map[string]interface{} {
+	response := map[string]interface{}{
+		"error":      fmt.Sprintf("invalid %s name: %s", kind, name),
+		"violations": v.Violations,
+	}
+	if v.Suggestion != "" {
+		response["suggestion"] = v.Suggestion
+	}
+	return response
+}
+
+// handleAllocateModule allocates a module name scoped under a capability
+func (a *NamingAgent) handleAllocateModule(request map[string]interface{}, ttl time.Duration) map[string]interface{} {
+	params, ok := request["params"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{"error": "No params provided"}
+	}
+
+	capabilityRef, ok := params["capability"].(string)
+	if !ok {
+		return map[string]interface{}{"error": "Capability required (slug or CID)"}
+	}
+	name, ok := params["name"].(string)
+	if !ok {
+		return map[string]interface{}{"error": "Name required"}
+	}
+	if v := validateName(name, "module"); len(v.Violations) > 0 {
+		return scopedError("module", name, v)
+	}
+	purpose, _ := params["purpose"].(string)
+
+	capability, err := a.resolveParent("capability", capabilityRef)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	capabilitySlug := getString(capability, "slug")
+
+	allocation, err := a.allocateScopedName(scopedName{
+		Kind:       "module",
+		Slug:       fmt.Sprintf("MOD-%s-%s", strings.TrimPrefix(capabilitySlug, "CAP-"), name),
+		Name:       name,
+		Path:       capabilitySlug + "/" + name,
+		GraphID:    fmt.Sprintf("lexi:Module/%s/%s", capabilitySlug, name),
+		Capability: capability,
+		Parent:     capability,
+		Purpose:    purpose,
+		TTL:        ttl,
+	})
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	return allocation
+}
+
+// handleAllocateFunction allocates a function name scoped under a capability, optionally within a module
+func (a *NamingAgent) handleAllocateFunction(request map[string]interface{}, ttl time.Duration) map[string]interface{} {
+	params, ok := request["params"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{"error": "No params provided"}
+	}
+
+	capabilityRef, ok := params["capability"].(string)
+	if !ok {
+		return map[string]interface{}{"error": "Capability required (slug or CID)"}
+	}
+	name, ok := params["name"].(string)
+	if !ok {
+		return map[string]interface{}{"error": "Name required"}
+	}
+	if v := validateName(name, "function"); len(v.Violations) > 0 {
+		return scopedError("function", name, v)
+	}
+	purpose, _ := params["purpose"].(string)
+
+	capability, err := a.resolveParent("capability", capabilityRef)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	capabilitySlug := getString(capability, "slug")
+	domainAndSequence := strings.TrimPrefix(capabilitySlug, "CAP-")
+
+	parent := capability
+	path := capabilitySlug + "/" + name
+	if moduleRef, ok := params["module"].(string); ok && moduleRef != "" {
+		// Accept the module's slug, CID or its bare name within this capability. A bare name is
+		// looked up by capability CID: modules keep their slug when the capability is renamed.
+		if !strings.HasPrefix(moduleRef, "MOD-") && !strings.HasPrefix(moduleRef, "cid:") {
+			_, module, err := a.findScopedName("module", getString(capability, "cid"), moduleRef)
+			if err != nil {
+				return map[string]interface{}{"error": err.Error()}
+			}
+			if module == nil {
+				return map[string]interface{}{"error": fmt.Sprintf("module %s not found in %s", moduleRef, capabilitySlug)}
+			}
+			moduleRef = getString(module, "cid")
+		}
+		module, err := a.resolveParent("module", moduleRef)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		if getString(module, "capability_cid") != getString(capability, "cid") {
+			return map[string]interface{}{"error": fmt.Sprintf("module %s does not belong to %s", moduleRef, capabilitySlug)}
+		}
+		parent = module
+		path = getString(module, "path") + "/" + name
+	}
+
+	slug := fmt.Sprintf("FN-%s-%s", domainAndSequence, name)
+	if len(slug) > maxFunctionSlugLength {
+		return map[string]interface{}{"error": fmt.Sprintf("function slug %s exceeds %d characters", slug, maxFunctionSlugLength)}
+	}
+
+	allocation, err := a.allocateScopedName(scopedName{
+		Kind:       "function",
+		Slug:       slug,
+		Name:       name,
+		Path:       path,
+		GraphID:    fmt.Sprintf("lexi:Function/%s/%s", capabilitySlug, name),
+		Capability: capability,
+		Parent:     parent,
+		Purpose:    purpose,
+		TTL:        ttl,
+	})
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	return allocation
+}
+
+// scopedName describes a module or function allocation
+type scopedName struct {
+	Kind       string
+	Slug       string
+	Name       string
+	Path       string
+	GraphID    string
+	Capability map[string]interface{}
+	Parent     map[string]interface{}
+	Purpose    string
+	TTL        time.Duration // > 0 reserves the name until confirmed
+}
+
+// allocateScopedName mints a CID and stores the record; names are unique within their capability
+func (a *NamingAgent) allocateScopedName(n scopedName) (map[string]interface{}, error) {
+	nameKey := nameRecordKey(n.Kind, n.Slug)
+
+	exists, err := a.redisClient.Exists(a.ctx, nameKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s: %w", n.Slug, err)
+	}
+	if exists == 1 {
+		return nil, fmt.Errorf("%s %s already allocated", n.Kind, n.Slug)
+	}
+	// After a capability rename its older children still carry the old slug, so the slug check
+	// alone would let the same name be allocated twice
+	_, existing, err := a.findScopedName(n.Kind, getString(n.Capability, "cid"), n.Name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%s %s already allocated in %s as %s", n.Kind, n.Name, getString(n.Capability, "slug"), getString(existing, "slug"))
+	}
+
+	cid, _, err := a.mintCID(func(id ulid.ULID) string {
+		return fmt.Sprintf("cid:centerfire:%s:%s", n.Kind, id)
+	}, nameKey)
+	if err != nil {
+		return nil, err
+	}
+
+	record := map[string]interface{}{
+		"slug":           n.Slug,
+		"name":           n.Name,
+		"kind":           n.Kind,
+		"cid":            cid,
+		"path":           n.Path,
+		"graph_id":       n.GraphID,
+		"environment":    "dev",
+		"capability":     getString(n.Capability, "slug"),
+		"capability_cid": getString(n.Capability, "cid"),
+		"parent_cid":     getString(n.Parent, "cid"),
+		"purpose":        n.Purpose,
+		"allocated":      time.Now().Format(time.RFC3339),
+	}
+	markAllocation(record, n.TTL)
+
+	stored, err := a.storeNewName(nameKey, cid, record, n.TTL)
+	if err != nil {
+		a.releaseCID(cid)
+		return nil, fmt.Errorf("failed to store %s %s: %w", n.Kind, n.Slug, err)
+	}
+	if !stored {
+		a.releaseCID(cid)
+		return nil, fmt.Errorf("%s %s already allocated", n.Kind, n.Slug)
+	}
+
+	if n.TTL > 0 {
+		a.publishNameEvent("name_reserved", record)
+	} else {
+		a.publishNameEvent(n.Kind+"_allocated", record)
+	}
+	log.Printf("%s: Allocated %s: %s (CID: %s)", a.config.AgentID, n.Kind, n.Slug, cid)
+
+	return record, nil
+}
+
+// publishNameEvent appends a name record event to the semantic names stream
+func (a *NamingAgent) publishNameEvent(eventType string, record map[string]interface{}) {
+	eventData := make(map[string]interface{}, len(record)+1)
+	for k, v := range record {
+		eventData[k] = v
+	}
+	eventData["event_type"] = eventType
+
+	eventJSON, _ := json.Marshal(eventData)
+	_, err := a.redisClient.XAdd(a.ctx, &redis.XAddArgs{
+		Stream: "centerfire:semantic:names",
+		Values: map[string]interface{}{
+			"data":      string(eventJSON),
+			"timestamp": time.Now().Unix(),
+			"source":    a.config.AgentID,
+		},
+	}).Result()
+	if err != nil {
+		log.Printf("%s: Error publishing %s event: %v", a.config.AgentID, eventType, err)
+	}
+}
+
+func getString(data map[string]interface{}, key string) string {
+	if val, ok := data[key].(string); ok {
+		return val
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// cidGuardPrefix keys map each CID to the name record that owns it, so a CID is minted once
+const cidGuardPrefix = "centerfire.cids:"
+
+// cidClaimAttempts bounds retries when a freshly minted CID is already claimed
+const cidClaimAttempts = 3
+
+// mintCID generates a monotonic ULID and claims the CID built from it for owner via SETNX.
+// Returns the CID and the ULID used so callers can derive directory suffixes.
+func (a *NamingAgent) mintCID(format func(id ulid.ULID) string, owner string) (string, ulid.ULID, error) {
+	for attempt := 0; attempt < cidClaimAttempts; attempt++ {
+		id := ulid.Make()
+		cid := format(id)
+
+		claimed, err := a.redisClient.SetNX(a.ctx, cidGuardPrefix+cid, owner, 0).Result()
+		if err != nil {
+			return "", ulid.ULID{}, fmt.Errorf("failed to claim CID %s: %w", cid, err)
+		}
+		if claimed {
+			return cid, id, nil
+		}
+		log.Printf("%s: CID %s already claimed, minting another", a.config.AgentID, cid)
+	}
+	return "", ulid.ULID{}, fmt.Errorf("could not mint a unique CID after %d attempts", cidClaimAttempts)
+}
+
+// releaseCID drops a CID claim when the allocation that minted it could not be stored
+func (a *NamingAgent) releaseCID(cid string) {
+	a.redisClient.Del(a.ctx, cidGuardPrefix+cid)
+}
+
+// dirSuffix is the short ULID prefix used in directory names, matching AGT-BOOTSTRAP-1
+func dirSuffix(id ulid.ULID) string {
+	return id.String()[:8]
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Secondary indexes over name records, all keyed by CID so renames don't invalidate them:
+//
+//	index:all, index:kind:<kind>, index:domain:<DOMAIN>, index:env:<env>  zsets scored by allocation time
+//	index:capability:<cid>                                               zset of a capability's modules and functions
+//	index:trigram:<abc>                                                  sets for purpose substring search
+//	index:entries:<cid>                                                  the index keys holding a CID
+const (
+	nameIndexPrefix     = "centerfire.dev.index:"
+	nameIndexVersionKey = nameIndexPrefix + "version"
+	nameIndexVersion    = "3"
+
+	defaultListLimit   = 50
+	maxListLimit       = 500
+	maxSearchScan      = 5000
+	intersectionExpiry = 30 * time.Second
+)
+
+// nameIndexKeys returns the sorted-set indexes a record belongs to
+func nameIndexKeys(kind string, record map[string]interface{}) []string {
+	keys := []string{nameIndexPrefix + "all", nameIndexPrefix + "kind:" + kind}
+	if domain := recordDomain(kind, record); domain != "" {
+		keys = append(keys, nameIndexPrefix+"domain:"+domain)
+	}
+	if env := recordEnvironment(kind, record); env != "" {
+		keys = append(keys, nameIndexPrefix+"env:"+env)
+	}
+	if capabilityCID := getString(record, "capability_cid"); capabilityCID != "" {
+		keys = append(keys, nameIndexPrefix+"capability:"+capabilityCID)
+	}
+	return keys
+}
+
+// recordEnvironment is the environment a record lives in. Capabilities, modules and functions
+// allocated before they carried one are all stored under centerfire.dev.
+func recordEnvironment(kind string, record map[string]interface{}) string {
+	if env := getString(record, "environment"); env != "" {
+		return env
+	}
+	switch kind {
+	case "capability", "module", "function":
+		return "dev"
+	}
+	return ""
+}
+
+// recordDomain is the capability domain of a capability, module or function record
+func recordDomain(kind string, record map[string]interface{}) string {
+	switch kind {
+	case "capability":
+		return strings.ToUpper(getString(record, "domain"))
+	case "module", "function":
+		if match := capabilitySlugPattern.FindStringSubmatch(getString(record, "capability")); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// trigrams splits lowercased text into its distinct three-rune substrings
+func trigrams(text string) []string {
+	runes := []rune(strings.ToLower(text))
+	seen := make(map[string]bool)
+	var grams []string
+	for i := 0; i+3 <= len(runes); i++ {
+		gram := string(runes[i : i+3])
+		if !seen[gram] {
+			seen[gram] = true
+			grams = append(grams, gram)
+		}
+	}
+	return grams
+}
+
+// indexName adds a record to the secondary indexes
+func (a *NamingAgent) indexName(kind string, record map[string]interface{}) {
+	cid := getString(record, "cid")
+	if cid == "" {
+		return
+	}
+
+	score := float64(time.Now().Unix())
+	if allocated, err := time.Parse(time.RFC3339, getString(record, "allocated")); err == nil {
+		score = float64(allocated.Unix())
+	}
+
+	entriesKey := nameIndexPrefix + "entries:" + cid
+	pipe := a.redisClient.TxPipeline()
+	for _, key := range nameIndexKeys(kind, record) {
+		pipe.ZAdd(a.ctx, key, redis.Z{Score: score, Member: cid})
+		pipe.SAdd(a.ctx, entriesKey, key)
+	}
+	for _, gram := range trigrams(getString(record, "purpose")) {
+		key := nameIndexPrefix + "trigram:" + gram
+		pipe.SAdd(a.ctx, key, cid)
+		pipe.SAdd(a.ctx, entriesKey, key)
+	}
+	if _, err := pipe.Exec(a.ctx); err != nil {
+		log.Printf("%s: Error indexing %s: %v", a.config.AgentID, cid, err)
+	}
+}
+
+// unindexName removes a CID from every index it was added to
+func (a *NamingAgent) unindexName(cid string) {
+	entriesKey := nameIndexPrefix + "entries:" + cid
+	keys, err := a.redisClient.SMembers(a.ctx, entriesKey).Result()
+	if err != nil {
+		return
+	}
+	pipe := a.redisClient.TxPipeline()
+	for _, key := range keys {
+		if strings.HasPrefix(key, nameIndexPrefix+"trigram:") {
+			pipe.SRem(a.ctx, key, cid)
+		} else {
+			pipe.ZRem(a.ctx, key, cid)
+		}
+	}
+	pipe.Del(a.ctx, entriesKey)
+	if _, err := pipe.Exec(a.ctx); err != nil {
+		log.Printf("%s: Error unindexing %s: %v", a.config.AgentID, cid, err)
+	}
+}
+
+// reindexName refreshes the indexes after a record changed (e.g. a rename moved its domain)
+func (a *NamingAgent) reindexName(kind string, record map[string]interface{}) {
+	a.unindexName(getString(record, "cid"))
+	a.indexName(kind, record)
+}
+
+// rebuildNameIndexes indexes every stored record; runs once per index version or on demand
+func (a *NamingAgent) rebuildNameIndexes(force bool) (int, error) {
+	if !force {
+		if version, _ := a.redisClient.Get(a.ctx, nameIndexVersionKey).Result(); version == nameIndexVersion {
+			return 0, nil
+		}
+	}
+
+	indexed := 0
+	for _, pattern := range []string{"centerfire.dev.names:*", "centerfire.*.namespaces:*"} {
+		iter := a.redisClient.Scan(a.ctx, 0, pattern, 500).Iterator()
+		for iter.Next(a.ctx) {
+			record, err := a.loadNameRecord(iter.Val())
+			if err != nil {
+				continue
+			}
+			a.reindexName(recordKind(iter.Val(), record), record)
+			indexed++
+		}
+		if err := iter.Err(); err != nil {
+			return indexed, fmt.Errorf("failed to scan %s: %w", pattern, err)
+		}
+	}
+
+	a.redisClient.Set(a.ctx, nameIndexVersionKey, nameIndexVersion, 0)
+	log.Printf("%s: Indexed %d name records", a.config.AgentID, indexed)
+	return indexed, nil
+}
+
+// loadByCID returns the current record for a CID; ok is false if it no longer exists
+func (a *NamingAgent) loadByCID(cid string) (string, map[string]interface{}, bool) {
+	key, err := a.redisClient.Get(a.ctx, cidGuardPrefix+cid).Result()
+	if err != nil {
+		return "", nil, false
+	}
+	record, err := a.loadNameRecord(key)
+	if err != nil {
+		return "", nil, false
+	}
+	return key, record, true
+}
+
+// handleGetName looks a name up by slug, alias or CID
+func (a *NamingAgent) handleGetName(request map[string]interface{}) map[string]interface{} {
+	params, ok := request["params"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{"error": "No params provided"}
+	}
+	ref := getString(params, "slug")
+	if ref == "" {
+		ref = getString(params, "cid")
+	}
+
+	key, record, err := a.resolveNameRecord(ref, getString(params, "kind"))
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	response := map[string]interface{}{
+		"name":   record,
+		"kind":   recordKind(key, record),
+		"status": recordStatus(record),
+	}
+	if !strings.HasPrefix(ref, "cid:") && getString(record, "slug") != ref && getString(record, "namespace") != ref {
+		response["aliased_from"] = ref
+	}
+	return response
+}
+
+// handleResolveCID maps a CID to its current name, or reports what became of it
+func (a *NamingAgent) handleResolveCID(request map[string]interface{}) map[string]interface{} {
+	params, ok := request["params"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{"error": "No params provided"}
+	}
+	cid := getString(params, "cid")
+	if cid == "" {
+		return map[string]interface{}{"error": "CID required"}
+	}
+
+	if key, record, ok := a.loadByCID(cid); ok {
+		return map[string]interface{}{
+			"cid":    cid,
+			"slug":   getString(record, "slug"),
+			"kind":   recordKind(key, record),
+			"status": recordStatus(record),
+			"name":   record,
+		}
+	}
+
+	// No live record: the history says whether it was released or its reservation expired
+	last, err := a.redisClient.LIndex(a.ctx, historyKeyPrefix+cid, -1).Result()
+	if err != nil {
+		return map[string]interface{}{"error": "unknown CID: " + cid}
+	}
+	response := map[string]interface{}{"cid": cid, "history_available": true}
+	var entry map[string]interface{}
+	if json.Unmarshal([]byte(last), &entry) == nil {
+		response["slug"] = entry["slug"]
+		response["status"] = entry["status"]
+		response["last_action"] = entry["action"]
+	}
+	return response
+}
+
+// handleListNames lists names filtered by kind/type, domain, environment, status and allocation
+// date range, newest first. cursor is the offset returned as next_cursor by the previous page.
+func (a *NamingAgent) handleListNames(request map[string]interface{}) map[string]interface{} {
+	params, _ := request["params"].(map[string]interface{})
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	limit, err := intParam(params, "limit", defaultListLimit)
+	if err != nil || limit < 1 {
+		return map[string]interface{}{"error": "limit must be a positive integer"}
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	cursor, err := intParam(params, "cursor", 0)
+	if err != nil || cursor < 0 {
+		return map[string]interface{}{"error": "cursor must be a non-negative integer"}
+	}
+	min, max := "-inf", "+inf"
+	if since := getString(params, "since"); since != "" {
+		t, err := parseTimeParam(since)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		min = strconv.FormatInt(t.Unix(), 10)
+	}
+	if until := getString(params, "until"); until != "" {
+		t, err := parseTimeParam(until)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		max = strconv.FormatInt(t.Unix(), 10)
+	}
+
+	kind := getString(params, "kind")
+	if kind == "" {
+		kind = getString(params, "type")
+	}
+	indexes := []string{}
+	if kind != "" {
+		indexes = append(indexes, nameIndexPrefix+"kind:"+kind)
+	}
+	if domain := getString(params, "domain"); domain != "" {
+		indexes = append(indexes, nameIndexPrefix+"domain:"+strings.ToUpper(domain))
+	}
+	if env := getString(params, "environment"); env != "" {
+		indexes = append(indexes, nameIndexPrefix+"env:"+env)
+	}
+
+	source := nameIndexPrefix + "all"
+	switch len(indexes) {
+	case 0:
+	case 1:
+		source = indexes[0]
+	default:
+		// Intersect into a short-lived key so the date range and paging run server side
+		source = nameIndexPrefix + "tmp:" + ulid.Make().String()
+		if err := a.redisClient.ZInterStore(a.ctx, source, &redis.ZStore{Keys: indexes, Aggregate: "MIN"}).Err(); err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("failed to query indexes: %v", err)}
+		}
+		a.redisClient.Expire(a.ctx, source, intersectionExpiry)
+		defer a.redisClient.Del(a.ctx, source)
+	}
+
+	cids, err := a.redisClient.ZRevRangeByScore(a.ctx, source, &redis.ZRangeBy{
+		Min:    min,
+		Max:    max,
+		Offset: int64(cursor),
+		Count:  int64(limit),
+	}).Result()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to query indexes: %v", err)}
+	}
+
+	status := getString(params, "status")
+	names := make([]map[string]interface{}, 0, len(cids))
+	for _, cid := range cids {
+		_, record, ok := a.loadByCID(cid)
+		if !ok || (status != "" && recordStatus(record) != status) {
+			continue // expired reservation or filtered out
+		}
+		names = append(names, record)
+	}
+
+	response := map[string]interface{}{"names": names, "count": len(names)}
+	if len(cids) == limit {
+		response["next_cursor"] = cursor + limit
+	}
+	return response
+}
+
+// handleSearchNames finds names whose purpose contains the query, case-insensitively
+func (a *NamingAgent) handleSearchNames(request map[string]interface{}) map[string]interface{} {
+	params, ok := request["params"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{"error": "No params provided"}
+	}
+	query := strings.ToLower(strings.TrimSpace(getString(params, "query")))
+	if query == "" {
+		return map[string]interface{}{"error": "Query required"}
+	}
+	limit, err := intParam(params, "limit", defaultListLimit)
+	if err != nil || limit < 1 {
+		return map[string]interface{}{"error": "limit must be a positive integer"}
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	// Trigram intersection narrows candidates; queries under three characters scan the recent names.
+	// Either way only the maxSearchScan most recently allocated candidates are loaded.
+	source := nameIndexPrefix + "all"
+	if grams := trigrams(query); len(grams) > 0 {
+		// index:all contributes the allocation time as the score; the trigram sets only filter
+		keys := []string{source}
+		weights := []float64{1}
+		for _, gram := range grams {
+			keys = append(keys, nameIndexPrefix+"trigram:"+gram)
+			weights = append(weights, 0)
+		}
+		source = nameIndexPrefix + "tmp:" + ulid.Make().String()
+		if err := a.redisClient.ZInterStore(a.ctx, source, &redis.ZStore{Keys: keys, Weights: weights, Aggregate: "SUM"}).Err(); err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("failed to query indexes: %v", err)}
+		}
+		a.redisClient.Expire(a.ctx, source, intersectionExpiry)
+		defer a.redisClient.Del(a.ctx, source)
+	}
+	candidates, err := a.redisClient.ZRevRange(a.ctx, source, 0, maxSearchScan-1).Result()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to query indexes: %v", err)}
+	}
+
+	kind := getString(params, "kind")
+	domain := strings.ToUpper(getString(params, "domain"))
+	env := getString(params, "environment")
+
+	matches := make([]map[string]interface{}, 0)
+	for _, cid := range candidates {
+		key, record, ok := a.loadByCID(cid)
+		if !ok || !strings.Contains(strings.ToLower(getString(record, "purpose")), query) {
+			continue
+		}
+		matchKind := recordKind(key, record)
+		if (kind != "" && matchKind != kind) ||
+			(domain != "" && recordDomain(matchKind, record) != domain) ||
+			(env != "" && recordEnvironment(matchKind, record) != env) {
+			continue
+		}
+		matches = append(matches, record)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return getString(matches[i], "slug") < getString(matches[j], "slug")
+	})
+
+	total := len(matches)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return map[string]interface{}{"query": query, "names": matches, "count": len(matches), "total": total}
+}
+
+// handleReindexNames rebuilds the secondary indexes from the stored records
+func (a *NamingAgent) handleReindexNames(request map[string]interface{}) map[string]interface{} {
+	indexed, err := a.rebuildNameIndexes(true)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error(), "indexed": indexed}
+	}
+	return map[string]interface{}{"indexed": indexed}
+}
+
+// intParam reads a JSON number param, accepting numeric strings from query-style callers
+func intParam(params map[string]interface{}, name string, fallback int) (int, error) {
+	switch v := params[name].(type) {
+	case nil:
+		return fallback, nil
+	case float64:
+		return int(v), nil
+	case string:
+		return strconv.Atoi(v)
+	}
+	return 0, fmt.Errorf("%s must be a number", name)
+}
+
+// parseTimeParam accepts RFC3339, a date (2006-01-02) or unix seconds
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339, YYYY-MM-DD or unix seconds", value)
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Name lifecycle: reserve_name allocates with a TTL so AGT-STRUCT can create the directory before
+// confirm_name makes it permanent; unconfirmed reservations expire. Names can then be released,
+// deprecated or renamed (the old slug stays resolvable as an alias). Every transition is appended
+// to a per-CID history list and published to centerfire:semantic:names.
+
+const (
+	NameStatusReserved   = "reserved"
+	NameStatusActive     = "active"
+	NameStatusDeprecated = "deprecated"
+)
+
+const (
+	defaultReservationTTL    = 5 * time.Minute
+	maxReservationTTL        = time.Hour
+	reservationSweepInterval = 30 * time.Second
+
+	reservationsKey  = "centerfire.dev.reservations" // zset of cid|nameKey scored by expiry
+	historyKeyPrefix = "centerfire.dev.history:"     // list of transitions per CID
+	aliasKeyPrefix   = "centerfire.dev.aliases:"     // <kind>:<old slug> -> current name key
+)
+
+// recordStatus returns the status stored on a record; records written before lifecycles are active
+func recordStatus(record map[string]interface{}) string {
+	if status := getString(record, "status"); status != "" {
+		return status
+	}
+	return NameStatusActive
+}
+
+// recordKind returns the kind of a record, falling back to its key for older capability records
+func recordKind(key string, record map[string]interface{}) string {
+	if kind := getString(record, "kind"); kind != "" {
+		return kind
+	}
+	if strings.Contains(key, ".namespaces:") {
+		return "namespace"
+	}
+	rest := strings.TrimPrefix(key, "centerfire.dev.names:")
+	kind, _, _ := strings.Cut(rest, ":")
+	return kind
+}
+
+// markAllocation sets status fields on a new record; ttl > 0 makes it a reservation
+func markAllocation(record map[string]interface{}, ttl time.Duration) {
+	if ttl > 0 {
+		record["status"] = NameStatusReserved
+		record["expires_at"] = time.Now().Add(ttl).Format(time.RFC3339)
+	} else {
+		record["status"] = NameStatusActive
+	}
+}
+
+// storeNewName writes a freshly minted record without overwriting an existing one. Reservations
+// expire together with their CID guard and are tracked for the expiry sweeper.
+func (a *NamingAgent) storeNewName(nameKey, cid string, record map[string]interface{}, ttl time.Duration) (bool, error) {
+	recordJSON, _ := json.Marshal(record)
+	stored, err := a.redisClient.SetNX(a.ctx, nameKey, recordJSON, ttl).Result()
+	if err != nil || !stored {
+		return stored, err
+	}
+
+	action := "allocated"
+	if ttl > 0 {
+		action = "reserved"
+		a.redisClient.Expire(a.ctx, cidGuardPrefix+cid, ttl)
+		a.redisClient.ZAdd(a.ctx, reservationsKey, redis.Z{
+			Score:  float64(time.Now().Add(ttl).Unix()),
+			Member: cid + "|" + nameKey,
+		})
+	}
+	a.recordHistory(cid, action, record, nil)
+	a.indexName(recordKind(nameKey, record), record)
+	return true, nil
+}
+
+// saveNameRecord rewrites an existing record, keeping any reservation TTL
+func (a *NamingAgent) saveNameRecord(key string, record map[string]interface{}) error {
+	recordJSON, _ := json.Marshal(record)
+	return a.redisClient.Set(a.ctx, key, recordJSON, redis.KeepTTL).Err()
+}
+
+// recordHistory appends a transition to the CID's history
+func (a *NamingAgent) recordHistory(cid, action string, record map[string]interface{}, details map[string]interface{}) {
+	entry := map[string]interface{}{
+		"action":    action,
+		"slug":      getString(record, "slug"),
+		"status":    getString(record, "status"),
+		"timestamp": time.Now().Format(time.RFC3339),
+		"source":    a.config.AgentID,
+	}
+	if len(details) > 0 {
+		entry["details"] = details
+	}
+	entryJSON, _ := json.Marshal(entry)
+	if err := a.redisClient.RPush(a.ctx, historyKeyPrefix+cid, entryJSON).Err(); err != nil {
+		log.Printf("%s: Error recording %s history for %s: %v", a.config.AgentID, action, cid, err)
+	}
+}
+
+// resolveNameRecord finds a record by CID, slug or alias. kind is inferred from the slug when empty.
+func (a *NamingAgent) resolveNameRecord(ref, kind string) (string, map[string]interface{}, error) {
+	if ref == "" {
+		return "", nil, fmt.Errorf("cid or slug required")
+	}
+
+	var key string
+	if strings.HasPrefix(ref, "cid:") {
+		owner, err := a.redisClient.Get(a.ctx, cidGuardPrefix+ref).Result()
+		if err == redis.Nil {
+			return "", nil, fmt.Errorf("unknown CID: %s", ref)
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to resolve %s: %w", ref, err)
+		}
+		key = owner
+	} else {
+		if kind == "" {
+			kind = inferNameKind(ref)
+		}
+		if kind == "" && namespacePattern.MatchString(ref) {
+			kind = "namespace"
+		}
+		key = nameRecordKey(kind, ref)
+		if key == "" {
+			return "", nil, fmt.Errorf("cannot resolve %s: pass kind (capability, module, function)", ref)
+		}
+		if target, err := a.redisClient.Get(a.ctx, aliasKeyPrefix+kind+":"+ref).Result(); err == nil {
+			key = target // renamed: follow the alias to the current record
+		}
+	}
+
+	record, err := a.loadNameRecord(key)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s not found: %w", ref, err)
+	}
+	return key, record, nil
+}
+
+// lifecycleTarget reads the cid/slug/kind params shared by the lifecycle actions
+func (a *NamingAgent) lifecycleTarget(request map[string]interface{}) (map[string]interface{}, string, map[string]interface{}, error) {
+	params, ok := request["params"].(map[string]interface{})
+	if !ok {
+		return nil, "", nil, fmt.Errorf("No params provided")
+	}
+	ref := getString(params, "cid")
+	if ref == "" {
+		ref = getString(params, "slug")
+	}
+	key, record, err := a.resolveNameRecord(ref, getString(params, "kind"))
+	return params, key, record, err
+}
+
+// handleReserveName allocates a capability, module or function as a reservation that expires
+// unless confirmed
+func (a *NamingAgent) handleReserveName(request map[string]interface{}) map[string]interface{} {
+	params, ok := request["params"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{"error": "No params provided"}
+	}
+
+	ttl := defaultReservationTTL
+	if seconds, ok := params["ttl_seconds"].(float64); ok {
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if ttl <= 0 || ttl > maxReservationTTL {
+		return map[string]interface{}{"error": fmt.Sprintf("ttl_seconds must be between 1 and %d", int(maxReservationTTL.Seconds()))}
+	}
+
+	kind := getString(params, "kind")
+	switch kind {
+	case "", "capability":
+		return a.handleAllocateCapability(request, ttl)
+	case "module":
+		return a.handleAllocateModule(request, ttl)
+	case "function":
+		return a.handleAllocateFunction(request, ttl)
+	default:
+		return map[string]interface{}{"error": "Unsupported kind: " + kind + " (capability, module, function)"}
+	}
+}
+
+// handleConfirmName makes a reservation permanent
+func (a *NamingAgent) handleConfirmName(request map[string]interface{}) map[string]interface{} {
+	_, key, record, err := a.lifecycleTarget(request)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	cid := getString(record, "cid")
+
+	switch status := recordStatus(record); status {
+	case NameStatusReserved:
+	case NameStatusActive:
+		record["already_confirmed"] = true
+		return record
+	default:
+		return map[string]interface{}{"error": fmt.Sprintf("cannot confirm %s name %s", status, getString(record, "slug"))}
+	}
+
+	persisted, err := a.redisClient.Persist(a.ctx, key).Result()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to confirm %s: %v", getString(record, "slug"), err)}
+	}
+	if !persisted {
+		// PERSIST also answers false for a key without a TTL, e.g. one a concurrent confirm
+		// already persisted, so only a missing record means the reservation lapsed
+		current, err := a.loadNameRecord(key)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("reservation for %s expired", getString(record, "slug"))}
+		}
+		switch status := recordStatus(current); status {
+		case NameStatusReserved:
+			record = current
+		case NameStatusActive:
+			current["already_confirmed"] = true
+			return current
+		default:
+			return map[string]interface{}{"error": fmt.Sprintf("cannot confirm %s name %s", status, getString(current, "slug"))}
+		}
+	}
+	a.redisClient.Persist(a.ctx, cidGuardPrefix+cid)
+	a.redisClient.ZRem(a.ctx, reservationsKey, cid+"|"+key)
+
+	record["status"] = NameStatusActive
+	record["confirmed"] = time.Now().Format(time.RFC3339)
+	delete(record, "expires_at")
+	if err := a.saveNameRecord(key, record); err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to store %s: %v", getString(record, "slug"), err)}
+	}
+
+	a.recordHistory(cid, "confirmed", record, nil)
+	a.publishNameEvent("name_confirmed", record)
+	log.Printf("%s: Confirmed %s (CID: %s)", a.config.AgentID, getString(record, "slug"), cid)
+	return record
+}
+
+// handleReleaseName frees a reserved or allocated name. The CID stays claimed so it is never
+// reissued; the slug's sequence number is not reused either.
+func (a *NamingAgent) handleReleaseName(request map[string]interface{}) map[string]interface{} {
+	params, key, record, err := a.lifecycleTarget(request)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	cid := getString(record, "cid")
+	slug := getString(record, "slug")
+	kind := recordKind(key, record)
+	force, _ := params["force"].(bool)
+
+	if kind == "capability" && !force {
+		children, err := a.countChildren(cid)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		if children > 0 {
+			return map[string]interface{}{"error": fmt.Sprintf("%s still has %d modules/functions; release them first or pass force", slug, children)}
+		}
+	}
+
+	if err := a.redisClient.Del(a.ctx, key).Err(); err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to release %s: %v", slug, err)}
+	}
+	a.redisClient.Persist(a.ctx, cidGuardPrefix+cid)
+	a.redisClient.ZRem(a.ctx, reservationsKey, cid+"|"+key)
+	a.unindexName(cid)
+	for _, alias := range stringList(record["aliases"]) {
+		a.redisClient.Del(a.ctx, aliasKeyPrefix+kind+":"+alias)
+	}
+
+	reason := getString(params, "reason")
+	previous := recordStatus(record)
+	record["status"] = "released"
+	record["released"] = time.Now().Format(time.RFC3339)
+	a.recordHistory(cid, "released", record, map[string]interface{}{"reason": reason, "previous_status": previous})
+	a.publishNameEvent("name_released", record)
+	log.Printf("%s: Released %s (CID: %s)", a.config.AgentID, slug, cid)
+
+	return map[string]interface{}{"slug": slug, "cid": cid, "status": "released", "previous_status": previous}
+}
+
+// countChildren counts module and function records scoped under a capability. Children are found
+// by the parent CID rather than by slug prefix: a renamed capability keeps its children's slugs.
+func (a *NamingAgent) countChildren(capabilityCID string) (int, error) {
+	cids, err := a.redisClient.ZRange(a.ctx, nameIndexPrefix+"capability:"+capabilityCID, 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list names in %s: %w", capabilityCID, err)
+	}
+	count := 0
+	for _, cid := range cids {
+		if _, _, err := a.resolveNameRecord(cid, ""); err == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// handleDeprecateName marks an active name deprecated, optionally pointing at its replacement
+func (a *NamingAgent) handleDeprecateName(request map[string]interface{}) map[string]interface{} {
+	params, key, record, err := a.lifecycleTarget(request)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	cid := getString(record, "cid")
+
+	if status := recordStatus(record); status != NameStatusActive {
+		return map[string]interface{}{"error": fmt.Sprintf("cannot deprecate %s name %s", status, getString(record, "slug"))}
+	}
+
+	record["status"] = NameStatusDeprecated
+	record["deprecated"] = time.Now().Format(time.RFC3339)
+	if reason := getString(params, "reason"); reason != "" {
+		record["deprecation_reason"] = reason
+	}
+	if replacement := getString(params, "replaced_by"); replacement != "" {
+		_, replacementRecord, err := a.resolveNameRecord(replacement, recordKind(key, record))
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("replacement: %v", err)}
+		}
+		record["replaced_by"] = getString(replacementRecord, "cid")
+	}
+	if err := a.saveNameRecord(key, record); err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to store %s: %v", getString(record, "slug"), err)}
+	}
+
+	a.recordHistory(cid, "deprecated", record, map[string]interface{}{
+		"reason":      record["deprecation_reason"],
+		"replaced_by": record["replaced_by"],
+	})
+	a.publishNameEvent("name_deprecated", record)
+	log.Printf("%s: Deprecated %s (CID: %s)", a.config.AgentID, getString(record, "slug"), cid)
+	return record
+}
+
+// handleRenameName gives a name a new slug. The CID and directory stay; the old slug becomes an
+// alias. Modules and functions are renamed within their capability (new_name), capabilities
+// take a full new_slug.
+func (a *NamingAgent) handleRenameName(request map[string]interface{}) map[string]interface{} {
+	params, key, record, err := a.lifecycleTarget(request)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	cid := getString(record, "cid")
+	oldSlug := getString(record, "slug")
+	kind := recordKind(key, record)
+
+	if status := recordStatus(record); status != NameStatusActive && status != NameStatusDeprecated {
+		return map[string]interface{}{"error": fmt.Sprintf("cannot rename %s name %s", status, oldSlug)}
+	}
+
+	var newSlug string
+	switch kind {
+	case "capability":
+		newSlug = getString(params, "new_slug")
+		if v := validateName(newSlug, "capability"); len(v.Violations) > 0 {
+			return scopedError("capability", newSlug, v)
+		}
+	case "module", "function":
+		newName := getString(params, "new_name")
+		if v := validateName(newName, kind); len(v.Violations) > 0 {
+			return scopedError(kind, newName, v)
+		}
+		_, existing, err := a.findScopedName(kind, getString(record, "capability_cid"), newName)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		if existing != nil && getString(existing, "cid") != cid {
+			return map[string]interface{}{"error": fmt.Sprintf("%s %s already allocated in %s as %s", kind, newName, getString(record, "capability"), getString(existing, "slug"))}
+		}
+		oldName := getString(record, "name")
+		newSlug = strings.TrimSuffix(oldSlug, oldName) + newName
+		record["name"] = newName
+		record["path"] = strings.TrimSuffix(getString(record, "path"), oldName) + newName
+		record["graph_id"] = strings.TrimSuffix(getString(record, "graph_id"), oldName) + newName
+	default:
+		return map[string]interface{}{"error": fmt.Sprintf("cannot rename %s names", kind)}
+	}
+	if newSlug == oldSlug {
+		return map[string]interface{}{"error": "new name is the same as the current one"}
+	}
+
+	newKey := nameRecordKey(kind, newSlug)
+	// Renaming back to one of the record's own earlier slugs turns that alias into the slug again
+	newAliasKey := aliasKeyPrefix + kind + ":" + newSlug
+	if target, err := a.redisClient.Get(a.ctx, newAliasKey).Result(); err == nil && target != key {
+		return map[string]interface{}{"error": fmt.Sprintf("%s is an alias of another name", newSlug)}
+	}
+
+	renamed := time.Now().Format(time.RFC3339)
+	var aliases []string
+	for _, alias := range stringList(record["aliases"]) {
+		if alias != newSlug {
+			aliases = append(aliases, alias)
+		}
+	}
+	aliases = append(aliases, oldSlug)
+	record["slug"] = newSlug
+	record["aliases"] = aliases
+	record["renamed"] = renamed
+
+	recordJSON, _ := json.Marshal(record)
+	stored, err := a.redisClient.SetNX(a.ctx, newKey, recordJSON, 0).Result()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to store %s: %v", newSlug, err)}
+	}
+	if !stored {
+		return map[string]interface{}{"error": fmt.Sprintf("%s %s already allocated", kind, newSlug)}
+	}
+
+	// Point the CID and every alias at the new record, then drop the old one
+	a.redisClient.Set(a.ctx, cidGuardPrefix+cid, newKey, 0)
+	for _, alias := range aliases {
+		a.redisClient.Set(a.ctx, aliasKeyPrefix+kind+":"+alias, newKey, 0)
+	}
+	a.redisClient.Del(a.ctx, key, newAliasKey)
+
+	if kind == "capability" {
+		a.raiseSequenceFor(newSlug)
+	}
+	a.reindexName(kind, record)
+
+	a.recordHistory(cid, "renamed", record, map[string]interface{}{"from": oldSlug, "to": newSlug})
+	event := map[string]interface{}{"previous_slug": oldSlug}
+	for k, v := range record {
+		event[k] = v
+	}
+	a.publishNameEvent("name_renamed", event)
+	log.Printf("%s: Renamed %s -> %s (CID: %s)", a.config.AgentID, oldSlug, newSlug, cid)
+	return record
+}
+
+// raiseSequenceFor keeps a capability sequence ahead of a slug assigned by rename, so the slug
+// is never allocated again
+func (a *NamingAgent) raiseSequenceFor(slug string) {
+	match := capabilitySlugPattern.FindStringSubmatch(slug)
+	if match == nil {
+		return
+	}
+	sequence, _ := strconv.ParseInt(match[2], 10, 64)
+	ref := sequenceRef{Env: "dev", Name: "CAP-" + match[1]}
+	a.sequences.raise(ref.fileName(), sequence)
+	if _, err := a.allocateSequence(ref, 0); err != nil {
+		log.Printf("%s: Failed to raise sequence %s: %v", a.config.AgentID, ref.fileName(), err)
+	}
+}
+
+// handleNameHistory returns every recorded transition of a CID, oldest first
+func (a *NamingAgent) handleNameHistory(request map[string]interface{}) map[string]interface{} {
+	params, ok := request["params"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{"error": "No params provided"}
+	}
+
+	cid := getString(params, "cid")
+	if cid == "" {
+		_, record, err := a.resolveNameRecord(getString(params, "slug"), getString(params, "kind"))
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		cid = getString(record, "cid")
+	}
+
+	entries, err := a.redisClient.LRange(a.ctx, historyKeyPrefix+cid, 0, -1).Result()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to read history: %v", err)}
+	}
+	history := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		var item map[string]interface{}
+		if json.Unmarshal([]byte(entry), &item) == nil {
+			history = append(history, item)
+		}
+	}
+
+	return map[string]interface{}{"cid": cid, "history": history, "count": len(history)}
+}
+
+// startReservationSweeper records reservations that lapsed without confirmation. Redis expires
+// the keys themselves; the sweeper only writes history and events.
+func (a *NamingAgent) startReservationSweeper() {
+	ticker := time.NewTicker(reservationSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.sweepReservations()
+		}
+	}
+}
+
+func (a *NamingAgent) sweepReservations() {
+	due, err := a.redisClient.ZRangeByScore(a.ctx, reservationsKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return
+	}
+
+	for _, member := range due {
+		// ZREM decides which instance reports the expiry
+		if removed, err := a.redisClient.ZRem(a.ctx, reservationsKey, member).Result(); err != nil || removed == 0 {
+			continue
+		}
+		cid, nameKey, _ := strings.Cut(member, "|")
+		if exists, _ := a.redisClient.Exists(a.ctx, nameKey).Result(); exists == 1 {
+			continue
+		}
+
+		record := map[string]interface{}{
+			"cid":    cid,
+			"slug":   nameKey[strings.LastIndex(nameKey, ":")+1:],
+			"status": "expired",
+		}
+		a.recordHistory(cid, "expired", record, nil)
+		a.unindexName(cid)
+		a.publishNameEvent("name_reservation_expired", record)
+		log.Printf("%s: Reservation %s expired unconfirmed", a.config.AgentID, record["slug"])
+	}
+}
+
+// stringList converts a decoded JSON array to strings
+func stringList(value interface{}) []string {
+	items, _ := value.([]interface{})
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	if direct, ok := value.([]string); ok {
+		list = append(list, direct...)
+	}
+	return list
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// call runs a handler and fails the test if it answered with an error
+func call(t *testing.T, handler func(map[string]interface{}) map[string]interface{}, params map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	response := handler(map[string]interface{}{"params": params})
+	if errText, ok := response["error"]; ok {
+		t.Fatalf("%v: %v", params, errText)
+	}
+	return response
+}
+
+func allocateModule(a *NamingAgent, ttl time.Duration) func(map[string]interface{}) map[string]interface{} {
+	return func(request map[string]interface{}) map[string]interface{} {
+		return a.handleAllocateModule(request, ttl)
+	}
+}
+
+func allocateFunction(a *NamingAgent) func(map[string]interface{}) map[string]interface{} {
+	return func(request map[string]interface{}) map[string]interface{} {
+		return a.handleAllocateFunction(request, 0)
+	}
+}
+
+func TestScopedNamesStayUniqueAfterCapabilityRename(t *testing.T) {
+	a, _ := newTestAgent(t)
+	capability := a.handleAllocateCapability(map[string]interface{}{"params": map[string]interface{}{"domain": "AUTH"}}, 0)
+	capabilityCID := capability["cid"].(string)
+	module := call(t, allocateModule(a, 0), map[string]interface{}{"capability": capabilityCID, "name": "token"})
+	call(t, a.handleRenameName, map[string]interface{}{"cid": capabilityCID, "new_slug": "CAP-IAM-1"})
+
+	// MOD-IAM-1-token would be a new key, but the capability already has a token module
+	response := a.handleAllocateModule(map[string]interface{}{"params": map[string]interface{}{"capability": "CAP-IAM-1", "name": "token"}}, 0)
+	if response["error"] == nil {
+		t.Fatalf("allocated a second token module: %v", response["slug"])
+	}
+
+	// A bare module name resolves through the capability CID to the module's original slug
+	function := call(t, allocateFunction(a), map[string]interface{}{"capability": "CAP-IAM-1", "module": "token", "name": "issue"})
+	if function["parent_cid"] != module["cid"] {
+		t.Errorf("function parent is %v, want %v", function["parent_cid"], module["cid"])
+	}
+	if response := a.handleAllocateFunction(map[string]interface{}{"params": map[string]interface{}{"capability": "CAP-IAM-1", "name": "issue"}}, 0); response["error"] == nil {
+		t.Errorf("allocated a second issue function: %v", response["slug"])
+	}
+
+	// Renaming a child onto a sibling's name is refused the same way
+	other := call(t, allocateModule(a, 0), map[string]interface{}{"capability": "CAP-IAM-1", "name": "session"})
+	if response := a.handleRenameName(map[string]interface{}{"params": map[string]interface{}{"cid": other["cid"], "new_name": "token"}}); response["error"] == nil {
+		t.Errorf("renamed %v onto an existing module: %v", other["slug"], response["slug"])
+	}
+
+	if children, err := a.countChildren(capabilityCID); err != nil || children != 3 {
+		t.Errorf("countChildren = %d, %v; want 3", children, err)
+	}
+}
+
+func TestRenameBackToOwnAlias(t *testing.T) {
+	a, _ := newTestAgent(t)
+	capability := a.handleAllocateCapability(map[string]interface{}{"params": map[string]interface{}{"domain": "AUTH"}}, 0)
+	module := call(t, allocateModule(a, 0), map[string]interface{}{"capability": capability["cid"], "name": "token"})
+	cid := module["cid"].(string)
+
+	call(t, a.handleRenameName, map[string]interface{}{"cid": cid, "new_name": "jwt"})
+	renamed := call(t, a.handleRenameName, map[string]interface{}{"cid": cid, "new_name": "token"})
+	if renamed["slug"] != "MOD-AUTH-1-token" {
+		t.Errorf("slug is %v after renaming back", renamed["slug"])
+	}
+	if aliases := stringList(renamed["aliases"]); !reflect.DeepEqual(aliases, []string{"MOD-AUTH-1-jwt"}) {
+		t.Errorf("aliases are %v, want [MOD-AUTH-1-jwt]", aliases)
+	}
+	if key, _, err := a.resolveNameRecord("MOD-AUTH-1-jwt", "module"); err != nil || key != nameRecordKey("module", "MOD-AUTH-1-token") {
+		t.Errorf("MOD-AUTH-1-jwt resolves to %q, %v", key, err)
+	}
+
+	// Another record's alias is still refused
+	other := call(t, allocateModule(a, 0), map[string]interface{}{"capability": capability["cid"], "name": "session"})
+	response := a.handleRenameName(map[string]interface{}{"params": map[string]interface{}{"cid": other["cid"], "new_name": "jwt"}})
+	if errText, _ := response["error"].(string); !strings.Contains(errText, "alias of another name") {
+		t.Errorf("renaming onto another record's alias answered %v", response)
+	}
+}
+
+func TestConfirmPersistedReservation(t *testing.T) {
+	a, _ := newTestAgent(t)
+	capability := a.handleAllocateCapability(map[string]interface{}{"params": map[string]interface{}{"domain": "AUTH"}}, 0)
+	module := call(t, allocateModule(a, time.Hour), map[string]interface{}{"capability": capability["cid"], "name": "token"})
+	key := nameRecordKey("module", module["slug"].(string))
+
+	// A reservation whose key already lost its TTL is confirmed, not reported as expired
+	if err := a.redisClient.Persist(a.ctx, key).Err(); err != nil {
+		t.Fatal(err)
+	}
+	confirmed := call(t, a.handleConfirmName, map[string]interface{}{"cid": module["cid"]})
+	if confirmed["status"] != NameStatusActive {
+		t.Errorf("status is %v after confirm", confirmed["status"])
+	}
+
+	again := call(t, a.handleConfirmName, map[string]interface{}{"cid": module["cid"]})
+	if again["already_confirmed"] != true {
+		t.Errorf("second confirm answered %v", again)
+	}
+}
@@ -5,291 +5,123 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
 	"time"
 
+	"centerfire/shared/agent"
+	"centerfire/shared/messages"
+	"github.com/oklog/ulid/v2"
 	"github.com/redis/go-redis/v9"
 	"gopkg.in/yaml.v2"
 )
 
-// AgentConfig represents the agent configuration from YAML
+// AgentConfig holds the naming-specific settings from agent.yaml; the shared runtime reads the rest
 type AgentConfig struct {
-	AgentID      string                 `yaml:"agent_id"`
-	CID          string                 `yaml:"cid"`
-	FriendlyName string                 `yaml:"friendly_name"`
-	Namespace    string                 `yaml:"namespace"`
-	Language     string                 `yaml:"language"`
-	AgentType    string                 `yaml:"agent_type"`
-	Capabilities []string               `yaml:"capabilities"`
-	Communication map[string]interface{} `yaml:"communication"`
-	Monitoring   map[string]interface{}  `yaml:"monitoring"`
-	Logging      map[string]interface{}  `yaml:"logging"`
+	AgentID       string `yaml:"agent_id"`
+	FriendlyName  string `yaml:"friendly_name"`
+	SequencesFile string `yaml:"sequences_file"` // durable sequence snapshot, shared with AGT-BOOTSTRAP-1
 }
 
 // NamingAgent represents the template-based naming authority agent
 type NamingAgent struct {
 	config      AgentConfig
+	runtime     *agent.Agent
 	ctx         context.Context
-	cancel      context.CancelFunc
-	pidFile     string
-	healthFile  string
 	redisClient *redis.Client
-	socketPath  string
+	sequences   *sequenceStore
 }
 
-// NewAgent creates a new naming agent from configuration
-func NewAgent(configPath string) (*NamingAgent, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	
-	// Load configuration
-	configData, err := os.ReadFile(configPath)
+// NewAgent creates a new naming agent from the runtime configuration
+func NewAgent(runtimeConfig *agent.Config) (*NamingAgent, error) {
+	if !runtimeConfig.UsesRedis() {
+		return nil, fmt.Errorf("%s: naming records live in Redis, redis_channels is required", runtimeConfig.Path)
+	}
+
+	// Load the naming-specific settings from the same file
+	configData, err := os.ReadFile(runtimeConfig.Path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
-	
 	var config AgentConfig
 	if err := yaml.Unmarshal(configData, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
-	
-	// Connect to Redis
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
-		Password: "",
-		DB:       0,
-	})
-	
-	// Get socket path from communication config
-	socketPath := "/tmp/agt-naming-2.sock"
-	if comm, ok := config.Communication["unix_socket"].(string); ok {
-		socketPath = comm
-	}
-	
-	return &NamingAgent{
-		config:      config,
-		ctx:         ctx,
-		cancel:      cancel,
-		pidFile:     fmt.Sprintf("/tmp/%s.pid", config.AgentID),
-		healthFile:  fmt.Sprintf("/tmp/%s.health", config.AgentID),
-		redisClient: redisClient,
-		socketPath:  socketPath,
-	}, nil
-}
-
-// Start initializes and runs the agent
-func (a *NamingAgent) Start() error {
-	log.Printf("🚀 Starting %s (%s)", a.config.AgentID, a.config.FriendlyName)
-	
-	// Test Redis connection
-	_, err := a.redisClient.Ping(a.ctx).Result()
-	if err != nil {
-		return fmt.Errorf("failed to connect to Redis: %w", err)
-	}
-	log.Printf("Connected to Redis successfully")
-	
-	// Write PID file
-	if err := a.writePIDFile(); err != nil {
-		return fmt.Errorf("failed to write PID file: %w", err)
-	}
-	
-	// Setup signal handling
-	a.setupSignalHandling()
-	
-	// Register with monitor
-	if err := a.registerWithMonitor(); err != nil {
-		log.Printf("⚠️ Failed to register with monitor: %v", err)
-	}
-	
-	// Start health reporting
-	go a.healthReporter()
-	
-	// Start Redis listener for backward compatibility
-	go a.startRedisListener()
-	
-	// Start Unix socket listener for new architecture
-	go a.startSocketListener()
-	
-	// Run main agent logic
-	return a.run()
-}
 
-// run contains the main agent logic
-func (a *NamingAgent) run() error {
-	log.Printf("✅ %s ready - listening on Redis and Unix socket", a.config.FriendlyName)
-	
-	// Register with manager and start heartbeat
-	go a.registerWithManager()
-	go a.startHeartbeat()
-	
-	for {
-		select {
-		case <-a.ctx.Done():
-			log.Printf("🛑 %s shutting down", a.config.AgentID)
-			return nil
-		case <-time.After(30 * time.Second):
-			a.logToCapture("Agent heartbeat", map[string]interface{}{
-				"status": "running",
-				"uptime": time.Now().Unix(),
-			})
-		}
-	}
+	runtime := agent.New(runtimeConfig)
+	a := &NamingAgent{
+		config:      config,
+		runtime:     runtime,
+		ctx:         runtime.Context(),
+		redisClient: runtime.Redis(),
+		sequences:   newSequenceStore(config.SequencesFile),
+	}
+	a.registerHandlers()
+	runtime.OnStart(a.onStart)
+	runtime.OnStop(a.onStop)
+	return a, nil
 }
 
-// startRedisListener handles Redis pub/sub for backward compatibility
-func (a *NamingAgent) startRedisListener() {
-	channels := []string{"agent.naming.request"}
-	if comm, ok := a.config.Communication["redis_channels"].([]interface{}); ok {
-		channels = make([]string, len(comm))
-		for i, ch := range comm {
-			channels[i] = ch.(string)
-		}
-	}
-	
-	pubsub := a.redisClient.Subscribe(a.ctx, channels[0])
-	defer pubsub.Close()
-	
-	ch := pubsub.Channel()
-	log.Printf("%s: Redis listener started on %s", a.config.AgentID, channels[0])
-	
-	for {
-		select {
-		case <-a.ctx.Done():
-			return
-		case msg := <-ch:
-			if msg != nil {
-				response := a.processMessage(msg.Payload)
-				responseJSON, _ := json.Marshal(response)
-				a.redisClient.Publish(a.ctx, "agent.naming.response", responseJSON)
-			}
-		}
+// registerHandlers maps every naming action onto its handler
+func (a *NamingAgent) registerHandlers() {
+	handlers := map[string]func(map[string]interface{}) map[string]interface{}{
+		"allocate_capability": func(request map[string]interface{}) map[string]interface{} {
+			return a.handleAllocateCapability(request, 0)
+		},
+		"allocate_module": func(request map[string]interface{}) map[string]interface{} {
+			return a.handleAllocateModule(request, 0)
+		},
+		"allocate_function": func(request map[string]interface{}) map[string]interface{} {
+			return a.handleAllocateFunction(request, 0)
+		},
+		"allocate_session":   a.handleAllocateSession,
+		"allocate_namespace": a.handleAllocateNamespace,
+		"validate_name":      a.handleValidateName,
+		"reserve_name":       a.handleReserveName,
+		"confirm_name":       a.handleConfirmName,
+		"release_name":       a.handleReleaseName,
+		"deprecate_name":     a.handleDeprecateName,
+		"rename_name":        a.handleRenameName,
+		"name_history":       a.handleNameHistory,
+		"get_name":           a.handleGetName,
+		"resolve_cid":        a.handleResolveCID,
+		"list_names":         a.handleListNames,
+		"search_names":       a.handleSearchNames,
+		"reindex_names":      a.handleReindexNames,
+		"manage_sequences":   a.handleManageSequences,
+	}
+	for action, handler := range handlers {
+		a.runtime.Handle(action, agent.MapHandler(handler))
 	}
 }
 
-// startSocketListener handles Unix socket communication
-func (a *NamingAgent) startSocketListener() {
-	for {
-		select {
-		case <-a.ctx.Done():
-			return
-		default:
-			if err := a.connectToSocket(); err != nil {
-				log.Printf("Socket connection failed: %v, retrying in 2s", err)
-				time.Sleep(2 * time.Second)
-			}
-		}
-	}
-}
+// onStart reconciles sequences and indexes once Redis is reachable, before requests are served
+func (a *NamingAgent) onStart(ctx context.Context) error {
+	log.Printf("🚀 Starting %s (%s)", a.config.AgentID, a.config.FriendlyName)
 
-// connectToSocket establishes and handles socket connection
-func (a *NamingAgent) connectToSocket() error {
-	conn, err := net.Dial("unix", a.socketPath)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-	
-	log.Printf("%s: Connected to socket %s", a.config.AgentID, a.socketPath)
-	
-	buffer := make([]byte, 4096)
-	for {
-		select {
-		case <-a.ctx.Done():
-			return nil
-		default:
-			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
-			n, err := conn.Read(buffer)
-			if err != nil {
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					continue
-				}
-				return err
-			}
-			
-			if n > 0 {
-				payload := string(buffer[:n])
-				response := a.processSocketMessage(payload)
-				responseJSON, _ := json.Marshal(response)
-				conn.Write(responseJSON)
-			}
-		}
+	// Reconcile sequences with the durable snapshot before allocating anything
+	if err := a.restoreSequences(); err != nil {
+		return fmt.Errorf("failed to restore sequences: %w", err)
 	}
-}
+	go a.startSequenceSnapshots()
 
-// processMessage handles both Redis and socket messages
-func (a *NamingAgent) processMessage(payload string) map[string]interface{} {
-	var request map[string]interface{}
-	if err := json.Unmarshal([]byte(payload), &request); err != nil {
-		return map[string]interface{}{
-			"error": "Invalid JSON format",
-		}
-	}
-	
-	action, ok := request["action"].(string)
-	if !ok {
-		return map[string]interface{}{
-			"error": "No action specified",
-		}
-	}
-	
-	// Log the request
-	a.logToCapture(fmt.Sprintf("Processing %s request", action), map[string]interface{}{
-		"action": action,
-	})
-	
-	var response map[string]interface{}
-	
-	switch action {
-	case "allocate_capability":
-		response = a.handleAllocateCapability(request)
-	case "allocate_session":
-		response = a.handleAllocateSession(request)
-	case "allocate_namespace":
-		response = a.handleAllocateNamespace(request)
-	case "allocate_module":
-		response = map[string]interface{}{"error": "not implemented yet"}
-	case "allocate_function":
-		response = map[string]interface{}{"error": "not implemented yet"}
-	case "validate_name":
-		response = map[string]interface{}{"error": "not implemented yet"}
-	default:
-		response = map[string]interface{}{"error": "Unknown action: " + action}
-	}
-	
-	// Preserve request_id if provided
-	if requestID, ok := request["request_id"]; ok {
-		response["request_id"] = requestID
+	// Index records allocated before the lookup indexes existed
+	if _, err := a.rebuildNameIndexes(false); err != nil {
+		log.Printf("⚠️ Failed to build name indexes: %v", err)
 	}
-	
-	return response
+	go a.startReservationSweeper()
+	return nil
 }
 
-// processSocketMessage handles socket-specific message wrapping
-func (a *NamingAgent) processSocketMessage(payload string) map[string]interface{} {
-	var request map[string]interface{}
-	if err := json.Unmarshal([]byte(payload), &request); err != nil {
-		return map[string]interface{}{
-			"success":   false,
-			"error":     "Invalid JSON format",
-			"timestamp": time.Now(),
-		}
-	}
-	
-	response := a.processMessage(payload)
-	
-	return map[string]interface{}{
-		"id":        request["id"],
-		"success":   response["error"] == nil,
-		"data":      response,
-		"timestamp": time.Now(),
+// onStop persists sequences allocated since the last snapshot
+func (a *NamingAgent) onStop() {
+	if err := a.sequences.save(false); err != nil {
+		log.Printf("%s: Failed to snapshot sequences: %v", a.config.AgentID, err)
 	}
 }
 
-// handleAllocateCapability allocates capability names with sequences
-func (a *NamingAgent) handleAllocateCapability(request map[string]interface{}) map[string]interface{} {
+// handleAllocateCapability allocates capability names with sequences; ttl > 0 reserves instead
+func (a *NamingAgent) handleAllocateCapability(request map[string]interface{}, ttl time.Duration) map[string]interface{} {
 	params, ok := request["params"].(map[string]interface{})
 	if !ok {
 		return map[string]interface{}{"error": "No params provided"}
@@ -299,6 +131,13 @@ func (a *NamingAgent) handleAllocateCapability(request map[string]interface{}) m
 	if !ok {
 		return map[string]interface{}{"error": "Domain is required"}
 	}
+	// Domains are stored, indexed and slugged in uppercase whatever the caller sent
+	domain = strings.ToUpper(domain)
+	if !capabilityDomainPattern.MatchString(domain) {
+		return map[string]interface{}{
+			"error": fmt.Sprintf("invalid domain %q: up to %d uppercase letters and digits, starting with a letter (e.g. AUTH)", domain, maxCapabilityDomainLength),
+		}
+	}
 	
 	// Get purpose from params (accept both 'purpose' and 'description')
 	purpose := ""
@@ -309,7 +148,10 @@ func (a *NamingAgent) handleAllocateCapability(request map[string]interface{}) m
 	}
 	
 	// Generate capability allocation
-	allocation := a.generateCapabilityID(domain, purpose)
+	allocation, err := a.generateCapabilityID(domain, purpose, ttl)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
 	
 	// Delegate structure creation to AGT-STRUCT-1
 	a.delegateStructureCreation(allocation)
@@ -334,7 +176,10 @@ func (a *NamingAgent) handleAllocateSession(request map[string]interface{}) map[
 		context = c
 	}
 	
-	sessionID := a.generateSessionID(sessionType)
+	sessionID, err := a.generateSessionID(sessionType)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
 	
 	return map[string]interface{}{
 		"session_id": sessionID,
@@ -362,7 +207,10 @@ func (a *NamingAgent) handleAllocateNamespace(request map[string]interface{}) ma
 	
 	classType, _ := params["class_type"].(string)
 	
-	namespace := a.generateNamespaceID(project, environment)
+	namespace, err := a.generateNamespaceID(project, environment)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
 	
 	response := map[string]interface{}{
 		"namespace":   namespace["namespace"],
@@ -380,61 +228,76 @@ func (a *NamingAgent) handleAllocateNamespace(request map[string]interface{}) ma
 }
 
 // generateCapabilityID creates capability names with Redis sequences
-func (a *NamingAgent) generateCapabilityID(domain, purpose string) map[string]interface{} {
+func (a *NamingAgent) generateCapabilityID(domain, purpose string, ttl time.Duration) (map[string]interface{}, error) {
 	domainUpper := strings.ToUpper(domain)
-	sequenceKey := fmt.Sprintf("centerfire.dev.sequence:CAP-%s", domainUpper)
 	
-	sequence, err := a.redisClient.Incr(a.ctx, sequenceKey).Result()
+	sequence, err := a.allocateSequence(sequenceRef{Env: "dev", Name: "CAP-" + domainUpper}, 1)
 	if err != nil {
-		log.Printf("Error getting sequence from Redis: %v, using fallback", err)
-		sequence = 1
+		return nil, err
 	}
 	
-	now := time.Now()
-	ulid := fmt.Sprintf("%d%08X", now.Unix(), now.Nanosecond()%0xFFFFFFFF)[:8]
-	
 	slug := fmt.Sprintf("CAP-%s-%d", domainUpper, sequence)
-	cid := fmt.Sprintf("cid:centerfire:capability:%s", ulid)
-	directory := fmt.Sprintf("%s__%s", slug, ulid)
-	
+	// The same rules validate_name and rename_name apply, including the slug length
+	if v := validateName(slug, "capability"); len(v.Violations) > 0 {
+		return nil, fmt.Errorf("cannot allocate %s: %s", slug, v.Violations[0].Message)
+	}
 	nameKey := fmt.Sprintf("centerfire.dev.names:capability:%s", slug)
+
+	cid, id, err := a.mintCID(func(id ulid.ULID) string {
+		return fmt.Sprintf("cid:centerfire:capability:%s", id)
+	}, nameKey)
+	if err != nil {
+		return nil, err
+	}
+	directory := fmt.Sprintf("%s__%s", slug, dirSuffix(id))
+	
 	allocated := time.Now().Format(time.RFC3339)
 	
 	nameData := map[string]interface{}{
-		"slug":      slug,
-		"cid":       cid,
-		"directory": directory,
-		"domain":    domain,
-		"purpose":   purpose,
-		"sequence":  sequence,
-		"allocated": allocated,
+		"slug":        slug,
+		"kind":        "capability",
+		"cid":         cid,
+		"directory":   directory,
+		"domain":      domainUpper,
+		"environment": "dev",
+		"purpose":     purpose,
+		"sequence":    sequence,
+		"allocated":   allocated,
 	}
+	markAllocation(nameData, ttl)
 	
-	nameJSON, _ := json.Marshal(nameData)
-	a.redisClient.Set(a.ctx, nameKey, nameJSON, 0)
+	stored, err := a.storeNewName(nameKey, cid, nameData, ttl)
+	if err != nil {
+		a.releaseCID(cid)
+		return nil, fmt.Errorf("failed to store capability %s: %w", slug, err)
+	}
+	if !stored {
+		// The sequence is behind existing allocations; never overwrite another record
+		a.releaseCID(cid)
+		return nil, fmt.Errorf("capability %s already allocated: sequence CAP-%s needs manage_sequences repair", slug, domainUpper)
+	}
 	
 	// Publish semantic name event
-	a.publishSemanticNameEvent(slug, cid, directory, domain, purpose, sequence, allocated)
+	if ttl > 0 {
+		a.publishNameEvent("name_reserved", nameData)
+	} else {
+		a.publishSemanticNameEvent(slug, cid, directory, domainUpper, purpose, sequence, allocated)
+	}
 	
 	log.Printf("%s: Allocated capability: %s (CID: %s)", a.config.AgentID, slug, cid)
 	
-	return nameData
+	return nameData, nil
 }
 
 // generateSessionID creates session identifiers
-func (a *NamingAgent) generateSessionID(sessionType string) string {
+func (a *NamingAgent) generateSessionID(sessionType string) (string, error) {
 	sessionTypeUpper := strings.ToUpper(sessionType)
-	sequenceKey := fmt.Sprintf("centerfire.dev.sequence:SES-%s", sessionTypeUpper)
 	
-	sequence, err := a.redisClient.Incr(a.ctx, sequenceKey).Result()
+	sequence, err := a.allocateSequence(sequenceRef{Env: "dev", Name: "SES-" + sessionTypeUpper}, 1)
 	if err != nil {
-		log.Printf("Error getting session sequence: %v, using fallback", err)
-		sequence = 1
+		return "", err
 	}
 	
-	now := time.Now()
-	ulid := fmt.Sprintf("%d%08X", now.Unix(), now.Nanosecond()%0xFFFFFFFF)[:8]
-	
 	prefix := "SES-CLAUDE"
 	switch sessionType {
 	case "claude_coding":
@@ -445,29 +308,30 @@ func (a *NamingAgent) generateSessionID(sessionType string) string {
 		prefix = "SES-GENERIC"
 	}
 	
-	return fmt.Sprintf("%s-%d-%s", prefix, sequence, ulid)
+	return fmt.Sprintf("%s-%d-%s", prefix, sequence, ulid.Make()), nil
 }
 
 // generateNamespaceID creates semantic namespaces with CIDs
-func (a *NamingAgent) generateNamespaceID(project, environment string) map[string]interface{} {
-	sequenceKey := fmt.Sprintf("centerfire.%s.sequence:NS-%s", environment, strings.ToUpper(project))
-	sequence, err := a.redisClient.Incr(a.ctx, sequenceKey).Result()
+func (a *NamingAgent) generateNamespaceID(project, environment string) (map[string]interface{}, error) {
+	sequence, err := a.allocateSequence(sequenceRef{Env: environment, Name: "NS-" + strings.ToUpper(project)}, 1)
 	if err != nil {
-		log.Printf("Error getting namespace sequence: %v, using fallback", err)
-		sequence = 1
+		return nil, err
 	}
 	
-	now := time.Now()
-	ulid := fmt.Sprintf("%d%08X", now.Unix(), now.Nanosecond()%0xFFFFFFFF)[:8]
-	
-	cid := fmt.Sprintf("cid:%s:%s:namespace:%s", project, environment, ulid)
 	namespace := fmt.Sprintf("%s.%s.ns%d", project, environment, sequence)
-	
 	nameKey := fmt.Sprintf("centerfire.%s.namespaces:%s", environment, namespace)
+
+	cid, _, err := a.mintCID(func(id ulid.ULID) string {
+		return fmt.Sprintf("cid:%s:%s:namespace:%s", project, environment, id)
+	}, nameKey)
+	if err != nil {
+		return nil, err
+	}
 	allocated := time.Now().Format(time.RFC3339)
 	
 	namespaceData := map[string]interface{}{
 		"namespace":   namespace,
+		"kind":        "namespace",
 		"cid":         cid,
 		"project":     project,
 		"environment": environment,
@@ -476,14 +340,24 @@ func (a *NamingAgent) generateNamespaceID(project, environment string) map[strin
 	}
 	
 	nameJSON, _ := json.Marshal(namespaceData)
-	a.redisClient.Set(a.ctx, nameKey, nameJSON, 0)
+	stored, err := a.redisClient.SetNX(a.ctx, nameKey, nameJSON, 0).Result()
+	if err != nil {
+		a.releaseCID(cid)
+		return nil, fmt.Errorf("failed to store namespace %s: %w", namespace, err)
+	}
+	if !stored {
+		a.releaseCID(cid)
+		return nil, fmt.Errorf("namespace %s already allocated: sequence %s:NS-%s needs manage_sequences repair", namespace, environment, strings.ToUpper(project))
+	}
+	
+	a.indexName("namespace", namespaceData)
 	
 	// Publish semantic namespace event
 	a.publishSemanticNamespaceEvent(namespace, cid, project, environment, sequence, allocated)
 	
 	log.Printf("%s: Allocated namespace: %s (CID: %s)", a.config.AgentID, namespace, cid)
 	
-	return namespaceData
+	return namespaceData, nil
 }
 
 // generateClassName creates Weaviate class names from namespace CIDs
@@ -565,178 +439,41 @@ func (a *NamingAgent) publishSemanticNamespaceEvent(namespace, cid, project, env
 	}
 }
 
-// delegateStructureCreation sends structure creation request to AGT-STRUCT-1
+// delegateStructureCreation sends structure creation request to AGT-STRUCT-2
 func (a *NamingAgent) delegateStructureCreation(allocation map[string]interface{}) {
-	structRequest := map[string]interface{}{
-		"action": "create_structure",
-		"params": map[string]interface{}{
-			"slug":      allocation["slug"],
-			"cid":       allocation["cid"],
-			"directory": allocation["directory"],
-			"domain":    allocation["domain"],
-			"purpose":   allocation["purpose"],
-		},
-		"source": a.config.AgentID,
+	env, err := messages.NewRequest(messages.ActionCreateStructure, &messages.CreateStructureParams{
+		Name:    getString(allocation, "directory"),
+		Type:    "capability",
+		CID:     getString(allocation, "cid"),
+		Slug:    getString(allocation, "slug"),
+		Domain:  getString(allocation, "domain"),
+		Purpose: getString(allocation, "purpose"),
+		Status:  getString(allocation, "status"), // reserved names are confirmed once the directory exists
+	})
+	if err != nil {
+		log.Printf("%s: Not delegating structure creation: %v", a.config.AgentID, err)
+		return
 	}
+	env.Source = a.config.AgentID
 	
-	requestJSON, _ := json.Marshal(structRequest)
+	requestJSON, _ := env.Marshal()
 	a.redisClient.Publish(a.ctx, "agent.struct.request", requestJSON)
 	
-	log.Printf("%s: Delegated structure creation to AGT-STRUCT-1", a.config.AgentID)
-}
-
-// registerWithManager registers with AGT-MANAGER-1
-func (a *NamingAgent) registerWithManager() {
-	responseChannel := fmt.Sprintf("centerfire:agent:manager:response:%s", a.config.AgentID)
-	
-	registrationRequest := map[string]interface{}{
-		"request_type": "register_running",
-		"agent_name":   a.config.AgentID,
-		"session_data": map[string]interface{}{
-			"pid": os.Getpid(),
-		},
-		"response_channel": responseChannel,
-	}
-	
-	requestJSON, _ := json.Marshal(registrationRequest)
-	a.redisClient.Publish(a.ctx, "centerfire:agent:manager", requestJSON)
-	
-	log.Printf("%s: Registered with AGT-MANAGER-1", a.config.AgentID)
-}
-
-// startHeartbeat sends periodic heartbeat to manager
-func (a *NamingAgent) startHeartbeat() {
-	ticker := time.NewTicker(25 * time.Second)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-a.ctx.Done():
-			return
-		case <-ticker.C:
-			heartbeat := map[string]interface{}{
-				"request_type": "heartbeat",
-				"agent_name":   a.config.AgentID,
-			}
-			
-			heartbeatJSON, _ := json.Marshal(heartbeat)
-			a.redisClient.Publish(a.ctx, "centerfire:agent:manager", heartbeatJSON)
-		}
-	}
-}
-
-// Standard template methods below
-func (a *NamingAgent) writePIDFile() error {
-	pid := os.Getpid()
-	return os.WriteFile(a.pidFile, []byte(fmt.Sprintf("%d", pid)), 0644)
-}
-
-func (a *NamingAgent) setupSignalHandling() {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	
-	go func() {
-		sig := <-sigChan
-		log.Printf("🛑 Received %s, initiating graceful shutdown", sig)
-		a.shutdown()
-	}()
-}
-
-func (a *NamingAgent) registerWithMonitor() error {
-	registrationData := map[string]interface{}{
-		"agent_id":      a.config.AgentID,
-		"cid":           a.config.CID,
-		"friendly_name": a.config.FriendlyName,
-		"namespace":     a.config.Namespace,
-		"pid":           os.Getpid(),
-		"capabilities":  a.config.Capabilities,
-		"status":        "starting",
-		"timestamp":     time.Now().Unix(),
-	}
-	
-	regFile := fmt.Sprintf("/tmp/agent-registry-%s.json", a.config.AgentID)
-	data, _ := json.MarshalIndent(registrationData, "", "  ")
-	
-	return os.WriteFile(regFile, data, 0644)
-}
-
-func (a *NamingAgent) healthReporter() {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-a.ctx.Done():
-			return
-		case <-ticker.C:
-			health := map[string]interface{}{
-				"agent_id":   a.config.AgentID,
-				"cid":        a.config.CID,
-				"namespace":  a.config.Namespace,
-				"status":     "healthy",
-				"timestamp":  time.Now().Unix(),
-				"pid":        os.Getpid(),
-				"uptime":     time.Now().Unix(),
-			}
-			
-			data, _ := json.Marshal(health)
-			os.WriteFile(a.healthFile, data, 0644)
-		}
-	}
-}
-
-func (a *NamingAgent) logToCapture(message string, data map[string]interface{}) {
-	logEntry := map[string]interface{}{
-		"agent_id":  a.config.AgentID,
-		"namespace": a.config.Namespace,
-		"timestamp": time.Now().Unix(),
-		"message":   message,
-		"data":      data,
-	}
-	
-	jsonData, _ := json.Marshal(logEntry)
-	log.Printf("CAPTURE: %s", string(jsonData))
-}
-
-func (a *NamingAgent) shutdown() {
-	log.Printf("🔄 %s performing graceful shutdown", a.config.AgentID)
-	
-	// Unregister with manager
-	unregRequest := map[string]interface{}{
-		"request_type": "unregister_running",
-		"agent_name":   a.config.AgentID,
-	}
-	unregJSON, _ := json.Marshal(unregRequest)
-	a.redisClient.Publish(a.ctx, "centerfire:agent:manager", unregJSON)
-	
-	a.logToCapture("Agent shutting down", map[string]interface{}{
-		"reason": "graceful_shutdown",
-		"uptime": time.Now().Unix(),
-	})
-	
-	// Clean up files
-	os.Remove(a.pidFile)
-	os.Remove(a.healthFile)
-	
-	// Cancel context
-	a.cancel()
-	
-	log.Printf("✅ %s shutdown complete", a.config.AgentID)
-	os.Exit(0)
+	log.Printf("%s: Delegated structure creation to AGT-STRUCT-2", a.config.AgentID)
 }
 
 func main() {
-	configPath := "agent.yaml"
-	if len(os.Args) > 1 {
-		configPath = os.Args[1]
+	runtimeConfig, err := agent.LoadConfigArgs(os.Args[1:], "agent.yaml")
+	if err != nil {
+		log.Fatalf("❌ Failed to load config: %v", err)
 	}
-	
-	agent, err := NewAgent(configPath)
+
+	naming, err := NewAgent(runtimeConfig)
 	if err != nil {
 		log.Fatalf("❌ Failed to create agent: %v", err)
 	}
-	
-	if err := agent.Start(); err != nil {
+
+	if err := naming.runtime.Run(); err != nil {
 		log.Fatalf("❌ Agent failed to start: %v", err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestAgent returns a naming agent on an in-memory Redis with its sequence snapshot in a
+// temporary directory
+func newTestAgent(t *testing.T) (*NamingAgent, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return &NamingAgent{
+		config:      AgentConfig{AgentID: "AGT-NAMING-2"},
+		ctx:         context.Background(),
+		redisClient: client,
+		sequences:   newSequenceStore(filepath.Join(t.TempDir(), "sequences.json")),
+	}, server
+}
@@ -0,0 +1,517 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Sequences live in Redis (centerfire.<env>.sequence:<NAME>) and are snapshotted to the same
+// sequences.json AGT-BOOTSTRAP-1 uses for AGT-* numbering. The snapshot is a floor: allocation
+// never hands out a number at or below it, so a flushed or restored Redis cannot re-mint slugs.
+
+const (
+	defaultSequencesFile     = "../sequences.json"
+	sequenceSnapshotInterval = 30 * time.Second
+)
+
+// allocateSequenceScript raises the counter to the durable floor if Redis lost it, then increments
+var allocateSequenceScript = redis.NewScript(`
+local floor = tonumber(ARGV[1])
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+if current < floor then
+	redis.call("SET", KEYS[1], floor)
+end
+return redis.call("INCRBY", KEYS[1], ARGV[2])
+`)
+
+// namingSequencePrefixes are the sequences this agent allocates. sequences.json also holds
+// AGT-BOOTSTRAP-1's AGT-* counters, which never belong in Redis.
+var namingSequencePrefixes = []string{"CAP-", "SES-", "NS-"}
+
+// sequenceRef identifies one sequence; Env is the key environment, Name e.g. "CAP-AUTH"
+type sequenceRef struct {
+	Env  string
+	Name string
+}
+
+func (r sequenceRef) redisKey() string {
+	return fmt.Sprintf("centerfire.%s.sequence:%s", r.Env, r.Name)
+}
+
+// ownedByNaming reports whether this agent allocates from the sequence
+func (r sequenceRef) ownedByNaming() bool {
+	for _, prefix := range namingSequencePrefixes {
+		if strings.HasPrefix(r.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileName is the sequences.json entry; dev sequences use the bare name like bootstrap's AGT-* entries
+func (r sequenceRef) fileName() string {
+	if r.Env == "dev" {
+		return r.Name
+	}
+	return r.Env + ":" + r.Name
+}
+
+// parseSequenceRef accepts "CAP-AUTH", "prod:NS-PROJECT" or a full Redis sequence key
+func parseSequenceRef(value string) (sequenceRef, error) {
+	if strings.HasPrefix(value, "centerfire.") {
+		rest := strings.TrimPrefix(value, "centerfire.")
+		parts := strings.SplitN(rest, ".sequence:", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return sequenceRef{}, fmt.Errorf("invalid sequence key: %s", value)
+		}
+		return sequenceRef{Env: parts[0], Name: parts[1]}, nil
+	}
+	if env, name, found := strings.Cut(value, ":"); found {
+		if env == "" || name == "" {
+			return sequenceRef{}, fmt.Errorf("invalid sequence name: %s", value)
+		}
+		return sequenceRef{Env: env, Name: strings.ToUpper(name)}, nil
+	}
+	if value == "" {
+		return sequenceRef{}, fmt.Errorf("sequence name is required")
+	}
+	return sequenceRef{Env: "dev", Name: strings.ToUpper(value)}, nil
+}
+
+// sequenceStore is the durable floor for every sequence, persisted to sequences.json
+type sequenceStore struct {
+	mu     sync.Mutex
+	path   string
+	floors map[string]int64
+	dirty  bool
+}
+
+func newSequenceStore(path string) *sequenceStore {
+	if path == "" {
+		path = defaultSequencesFile
+	}
+	return &sequenceStore{path: path, floors: make(map[string]int64)}
+}
+
+// readFile loads the snapshot; a missing file is an empty snapshot
+func (s *sequenceStore) readFile() (map[string]int64, error) {
+	floors := make(map[string]int64)
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return floors, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &floors); err != nil {
+		return nil, fmt.Errorf("invalid sequences file %s: %w", s.path, err)
+	}
+	return floors, nil
+}
+
+// load merges the snapshot file into memory
+func (s *sequenceStore) load() error {
+	floors, err := s.readFile()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, value := range floors {
+		if value > s.floors[name] {
+			s.floors[name] = value
+		}
+	}
+	return nil
+}
+
+func (s *sequenceStore) floor(name string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.floors[name]
+}
+
+// raise records a higher floor; lower values are ignored since sequences only grow
+func (s *sequenceStore) raise(name string, value int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value > s.floors[name] {
+		s.floors[name] = value
+		s.dirty = true
+	}
+}
+
+func (s *sequenceStore) snapshot() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	floors := make(map[string]int64, len(s.floors))
+	for name, value := range s.floors {
+		floors[name] = value
+	}
+	return floors
+}
+
+// save writes the snapshot atomically, merging entries other writers (bootstrap) added meanwhile
+func (s *sequenceStore) save(force bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty && !force {
+		return nil
+	}
+
+	onDisk, err := s.readFile()
+	if err != nil {
+		return err
+	}
+	for name, value := range onDisk {
+		if value > s.floors[name] {
+			s.floors[name] = value
+		}
+	}
+
+	data, err := json.MarshalIndent(s.floors, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".sequences-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// allocateSequence reserves count numbers and returns the last one. It fails closed: any Redis
+// error is returned instead of guessing a value.
+func (a *NamingAgent) allocateSequence(ref sequenceRef, count int64) (int64, error) {
+	value, err := allocateSequenceScript.Run(a.ctx, a.redisClient, []string{ref.redisKey()}, a.sequences.floor(ref.fileName()), count).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("sequence %s unavailable: %w", ref.fileName(), err)
+	}
+	a.sequences.raise(ref.fileName(), value)
+	return value, nil
+}
+
+// restoreSequences reconciles Redis with the snapshot at startup: each side is raised to the max.
+// Only sequences this agent allocates are written to Redis.
+func (a *NamingAgent) restoreSequences() error {
+	if err := a.sequences.load(); err != nil {
+		return err
+	}
+
+	redisValues, err := a.scanSequences()
+	if err != nil {
+		return err
+	}
+	for name, value := range redisValues {
+		a.sequences.raise(name, value)
+	}
+
+	restored := 0
+	for name, floor := range a.sequences.snapshot() {
+		ref, err := parseSequenceRef(name)
+		if err != nil || !ref.ownedByNaming() {
+			continue
+		}
+		if redisValues[name] < floor {
+			if err := a.redisClient.Set(a.ctx, ref.redisKey(), floor, 0).Err(); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+			restored++
+		}
+	}
+	if restored > 0 {
+		log.Printf("%s: Restored %d sequences from %s", a.config.AgentID, restored, a.sequences.path)
+	}
+	return a.sequences.save(true)
+}
+
+// scanSequences reads this agent's sequence counters from Redis keyed by file name
+func (a *NamingAgent) scanSequences() (map[string]int64, error) {
+	values := make(map[string]int64)
+	iter := a.redisClient.Scan(a.ctx, 0, "centerfire.*.sequence:*", 500).Iterator()
+	for iter.Next(a.ctx) {
+		ref, err := parseSequenceRef(iter.Val())
+		if err != nil || !ref.ownedByNaming() {
+			continue
+		}
+		value, err := a.redisClient.Get(a.ctx, iter.Val()).Int64()
+		if err != nil {
+			continue
+		}
+		values[ref.fileName()] = value
+	}
+	return values, iter.Err()
+}
+
+// startSequenceSnapshots persists allocated sequences periodically and on shutdown
+func (a *NamingAgent) startSequenceSnapshots() {
+	ticker := time.NewTicker(sequenceSnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.sequences.save(false); err != nil {
+				log.Printf("%s: Failed to snapshot sequences: %v", a.config.AgentID, err)
+			}
+		}
+	}
+}
+
+var (
+	capabilitySlugPattern = regexp.MustCompile(`^CAP-([A-Z0-9]+)-(\d+)$`)
+	namespacePattern      = regexp.MustCompile(`^(.+)\.([^.]+)\.ns(\d+)$`)
+)
+
+// highestAllocated derives sequence high-water marks from the stored name records
+func (a *NamingAgent) highestAllocated() (map[string]int64, error) {
+	highest := make(map[string]int64)
+	record := func(ref sequenceRef, value int64) {
+		if value > highest[ref.fileName()] {
+			highest[ref.fileName()] = value
+		}
+	}
+
+	iter := a.redisClient.Scan(a.ctx, 0, "centerfire.dev.names:capability:*", 500).Iterator()
+	for iter.Next(a.ctx) {
+		slug := strings.TrimPrefix(iter.Val(), "centerfire.dev.names:capability:")
+		if match := capabilitySlugPattern.FindStringSubmatch(slug); match != nil {
+			value, _ := strconv.ParseInt(match[2], 10, 64)
+			record(sequenceRef{Env: "dev", Name: "CAP-" + match[1]}, value)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	iter = a.redisClient.Scan(a.ctx, 0, "centerfire.*.namespaces:*", 500).Iterator()
+	for iter.Next(a.ctx) {
+		_, namespace, _ := strings.Cut(iter.Val(), ".namespaces:")
+		if match := namespacePattern.FindStringSubmatch(namespace); match != nil {
+			value, _ := strconv.ParseInt(match[3], 10, 64)
+			record(sequenceRef{Env: match[2], Name: "NS-" + strings.ToUpper(match[1])}, value)
+		}
+	}
+	return highest, iter.Err()
+}
+
+// handleManageSequences implements manage_sequences: inspect, reserve, repair, snapshot and restore
+func (a *NamingAgent) handleManageSequences(request map[string]interface{}) map[string]interface{} {
+	params, _ := request["params"].(map[string]interface{})
+	operation, _ := params["operation"].(string)
+	if operation == "" {
+		operation = "inspect"
+	}
+	name, _ := params["name"].(string)
+
+	switch operation {
+	case "inspect":
+		return a.inspectSequences(name)
+
+	case "reserve":
+		ref, err := namingSequenceRef(name)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		count := int64(1)
+		if c, ok := params["count"].(float64); ok {
+			count = int64(c)
+		}
+		if count < 1 || count > 1000 {
+			return map[string]interface{}{"error": "count must be between 1 and 1000"}
+		}
+		last, err := a.allocateSequence(ref, count)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		if err := a.sequences.save(false); err != nil {
+			log.Printf("%s: Failed to snapshot sequences: %v", a.config.AgentID, err)
+		}
+		log.Printf("%s: Reserved %s %d-%d", a.config.AgentID, ref.fileName(), last-count+1, last)
+		return map[string]interface{}{
+			"operation": "reserve",
+			"sequence":  ref.fileName(),
+			"from":      last - count + 1,
+			"to":        last,
+		}
+
+	case "repair":
+		return a.repairSequences(name)
+
+	case "snapshot":
+		if err := a.sequences.save(true); err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("snapshot failed: %v", err)}
+		}
+		return map[string]interface{}{"operation": "snapshot", "file": a.sequences.path, "sequences": a.sequences.snapshot()}
+
+	case "restore":
+		if err := a.restoreSequences(); err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("restore failed: %v", err)}
+		}
+		return map[string]interface{}{"operation": "restore", "file": a.sequences.path, "sequences": a.sequences.snapshot()}
+
+	default:
+		return map[string]interface{}{"error": "Unknown operation: " + operation + " (inspect, reserve, repair, snapshot, restore)"}
+	}
+}
+
+// namingSequenceRef parses a sequence named in a request, refusing sequences another agent owns
+func namingSequenceRef(name string) (sequenceRef, error) {
+	ref, err := parseSequenceRef(name)
+	if err != nil {
+		return sequenceRef{}, err
+	}
+	if !ref.ownedByNaming() {
+		return sequenceRef{}, fmt.Errorf("sequence %s is not allocated by the naming agent", ref.fileName())
+	}
+	return ref, nil
+}
+
+// inspectSequences reports Redis, snapshot and highest-allocated values and whether they drift
+func (a *NamingAgent) inspectSequences(filter string) map[string]interface{} {
+	redisValues, err := a.scanSequences()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to read sequences: %v", err)}
+	}
+	highest, err := a.highestAllocated()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to read allocations: %v", err)}
+	}
+	floors := a.sequences.snapshot()
+
+	names := map[string]bool{}
+	for name := range redisValues {
+		names[name] = true
+	}
+	for name := range floors {
+		// The snapshot file also holds AGT-BOOTSTRAP-1's counters, which never live in Redis
+		if ref, err := parseSequenceRef(name); err == nil && ref.ownedByNaming() {
+			names[name] = true
+		}
+	}
+	for name := range highest {
+		names[name] = true
+	}
+	if filter != "" {
+		ref, err := namingSequenceRef(filter)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		names = map[string]bool{ref.fileName(): true}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	sequences := make([]map[string]interface{}, 0, len(sorted))
+	drifted := 0
+	for _, name := range sorted {
+		status := "ok"
+		switch {
+		case redisValues[name] < highest[name]:
+			status = "behind_allocations" // next allocation would re-mint an existing slug
+		case redisValues[name] < floors[name]:
+			status = "behind_snapshot"
+		}
+		if status != "ok" {
+			drifted++
+		}
+		sequences = append(sequences, map[string]interface{}{
+			"name":              name,
+			"redis":             redisValues[name],
+			"snapshot":          floors[name],
+			"highest_allocated": highest[name],
+			"status":            status,
+		})
+	}
+
+	return map[string]interface{}{
+		"operation": "inspect",
+		"file":      a.sequences.path,
+		"sequences": sequences,
+		"drifted":   drifted,
+	}
+}
+
+// repairSequences raises every (or one) Redis counter of this agent's to max(redis, snapshot,
+// highest allocated)
+func (a *NamingAgent) repairSequences(filter string) map[string]interface{} {
+	redisValues, err := a.scanSequences()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to read sequences: %v", err)}
+	}
+	highest, err := a.highestAllocated()
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to read allocations: %v", err)}
+	}
+	for name, value := range highest {
+		a.sequences.raise(name, value)
+	}
+
+	var only string
+	if filter != "" {
+		ref, err := namingSequenceRef(filter)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		only = ref.fileName()
+	}
+
+	repaired := make([]map[string]interface{}, 0)
+	for name, target := range a.sequences.snapshot() {
+		if only != "" && name != only {
+			continue
+		}
+		if redisValues[name] >= target {
+			continue
+		}
+		ref, err := parseSequenceRef(name)
+		if err != nil || !ref.ownedByNaming() {
+			continue
+		}
+		if err := a.redisClient.Set(a.ctx, ref.redisKey(), target, 0).Err(); err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("failed to repair %s: %v", name, err)}
+		}
+		repaired = append(repaired, map[string]interface{}{"name": name, "from": redisValues[name], "to": target})
+		log.Printf("%s: Repaired sequence %s %d -> %d", a.config.AgentID, name, redisValues[name], target)
+	}
+
+	if err := a.sequences.save(true); err != nil {
+		log.Printf("%s: Failed to snapshot sequences: %v", a.config.AgentID, err)
+	}
+	return map[string]interface{}{
+		"operation": "repair",
+		"repaired":  repaired,
+		"count":     len(repaired),
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// sequences.json is shared with AGT-BOOTSTRAP-1; its AGT-* counters must never reach Redis
+const sharedSnapshot = `{
+  "AGT-CODING": 3,
+  "AGT-NAMING": 2,
+  "CAP-AUTH": 5
+}`
+
+func newSnapshotAgent(t *testing.T) *NamingAgent {
+	t.Helper()
+	a, _ := newTestAgent(t)
+	if err := os.WriteFile(a.sequences.path, []byte(sharedSnapshot), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sequences.load(); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestRepairSequencesSkipsBootstrapSequences(t *testing.T) {
+	a := newSnapshotAgent(t)
+
+	response := a.repairSequences("")
+	if errText, ok := response["error"]; ok {
+		t.Fatal(errText)
+	}
+	if count := response["count"]; count != 1 {
+		t.Errorf("repaired %v sequences, want 1 (CAP-AUTH): %v", count, response["repaired"])
+	}
+
+	for _, key := range []string{"centerfire.dev.sequence:AGT-CODING", "centerfire.dev.sequence:AGT-NAMING"} {
+		if n, _ := a.redisClient.Exists(a.ctx, key).Result(); n != 0 {
+			t.Errorf("repair wrote bootstrap sequence %s to Redis", key)
+		}
+	}
+	if value, err := a.redisClient.Get(a.ctx, "centerfire.dev.sequence:CAP-AUTH").Int64(); err != nil || value != 5 {
+		t.Errorf("CAP-AUTH = %d (%v), want 5", value, err)
+	}
+
+	if response := a.repairSequences("AGT-CODING"); response["error"] == nil {
+		t.Error("repair of a bootstrap sequence by name was accepted")
+	}
+}
+
+func TestInspectSequencesSkipsBootstrapSequences(t *testing.T) {
+	a := newSnapshotAgent(t)
+	if err := a.redisClient.Set(a.ctx, "centerfire.dev.sequence:CAP-AUTH", 5, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	response := a.inspectSequences("")
+	if errText, ok := response["error"]; ok {
+		t.Fatal(errText)
+	}
+	if drifted := response["drifted"]; drifted != 0 {
+		t.Errorf("drifted = %v, want 0: %v", drifted, response["sequences"])
+	}
+	for _, sequence := range response["sequences"].([]map[string]interface{}) {
+		if ref, _ := parseSequenceRef(sequence["name"].(string)); !ref.ownedByNaming() {
+			t.Errorf("inspect reported bootstrap sequence %v", sequence)
+		}
+	}
+
+	if response := a.inspectSequences("AGT-NAMING"); response["error"] == nil {
+		t.Error("inspect of a bootstrap sequence by name was accepted")
+	}
+}
+
+func TestRestoreSequencesSkipsBootstrapSequences(t *testing.T) {
+	a := newSnapshotAgent(t)
+	if err := a.restoreSequences(); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := a.redisClient.Keys(a.ctx, "centerfire.*.sequence:*").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "centerfire.dev.sequence:CAP-AUTH" {
+		t.Errorf("restore wrote %v, want only CAP-AUTH", keys)
+	}
+}
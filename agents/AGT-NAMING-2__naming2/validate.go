@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Naming rules from README.md and the unified naming convention: PREFIX-DOMAIN-N slugs with
+// uppercase domains and no zero padding, lowercase module names, camelCase function names.
+var (
+	capabilityDomainPattern = regexp.MustCompile(fmt.Sprintf(`^[A-Z][A-Z0-9]{1,%d}$`, maxCapabilityDomainLength-1))
+	agentDomainPattern      = regexp.MustCompile(`^[A-Z][A-Z0-9]*(-[A-Z][A-Z0-9]*)*$`)
+	moduleNamePattern       = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+	functionNamePattern     = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+	dirSuffixPattern        = regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{8}$`)
+	cidPattern              = regexp.MustCompile(`^cid:[A-Za-z0-9_.-]+(:[A-Za-z0-9_.-]+)+$`)
+)
+
+// A capability slug is CAP-<DOMAIN>-<N>; the longest domain still fits with a three digit sequence
+const (
+	maxCapabilityDomainLength = 12
+	maxCapabilitySlugLength   = len("CAP-") + maxCapabilityDomainLength + len("-999")
+	maxFunctionSlugLength     = 50
+)
+
+// reservedPrefixes may not be allocated through the naming authority
+var reservedPrefixes = []string{"TEST-", "TEMP-", "DEPRECATED-", "EXPERIMENTAL-"}
+
+// nameViolation is one broken naming rule, with the corrected value when one can be derived
+type nameViolation struct {
+	Rule       string `json:"rule"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// nameValidation collects violations and the canonical form built while checking
+type nameValidation struct {
+	Kind       string
+	Violations []nameViolation
+	Suggestion string
+}
+
+func (v *nameValidation) add(rule, message, suggestion string) {
+	v.Violations = append(v.Violations, nameViolation{Rule: rule, Message: message, Suggestion: suggestion})
+}
+
+// inferNameKind guesses the kind of a name from its prefix or shape
+func inferNameKind(name string) string {
+	upper := strings.ToUpper(name)
+	switch {
+	case strings.HasPrefix(name, "cid:"):
+		return "cid"
+	case strings.Contains(name, "__"):
+		return "directory"
+	case strings.HasPrefix(upper, "CAP-") || strings.HasPrefix(upper, "CAP_"):
+		return "capability"
+	case strings.HasPrefix(upper, "AGT-") || strings.HasPrefix(upper, "AGT_"):
+		return "agent"
+	case strings.HasPrefix(upper, "MOD-"):
+		return "module"
+	case strings.HasPrefix(upper, "FN-"):
+		return "function"
+	}
+	return ""
+}
+
+// validateName checks name against the rules for kind (inferred when empty)
+func validateName(name, kind string) *nameValidation {
+	if kind == "" {
+		kind = inferNameKind(name)
+	}
+	v := &nameValidation{Kind: kind}
+
+	if name == "" {
+		v.add("required", "name is empty", "")
+		return v
+	}
+	for _, prefix := range reservedPrefixes {
+		if kind != "directory" && strings.HasPrefix(strings.ToUpper(name), prefix) {
+			v.add("reserved_prefix", fmt.Sprintf("%s is reserved and cannot be allocated", prefix), strings.TrimPrefix(strings.ToUpper(name), prefix))
+		}
+	}
+
+	switch kind {
+	case "capability":
+		v.Suggestion = validateSequencedSlug(v, name, "CAP", capabilityDomainPattern)
+		if len(v.Suggestion) > maxCapabilitySlugLength {
+			v.add("max_length", fmt.Sprintf("capability slugs are at most %d characters", maxCapabilitySlugLength), "")
+		}
+	case "agent":
+		v.Suggestion = validateSequencedSlug(v, name, "AGT", agentDomainPattern)
+	case "module":
+		v.Suggestion = validateScopedName(v, name, "MOD", validateModuleName)
+	case "function":
+		v.Suggestion = validateScopedName(v, name, "FN", validateFunctionName)
+		if len(v.Suggestion) > maxFunctionSlugLength {
+			v.add("max_length", fmt.Sprintf("function slugs are at most %d characters", maxFunctionSlugLength), "")
+		}
+	case "directory":
+		v.Suggestion = validateDirectory(v, name)
+	case "cid":
+		validateCID(v, name)
+		v.Suggestion = name
+	case "":
+		v.add("unknown_kind", "cannot infer the kind of name; pass kind (capability, agent, module, function, directory, cid)", "")
+	default:
+		v.add("unknown_kind", "unsupported kind: "+kind, "")
+	}
+	return v
+}
+
+// validateSequencedSlug checks PREFIX-DOMAIN-N and returns the canonical slug
+func validateSequencedSlug(v *nameValidation, name, prefix string, domainPattern *regexp.Regexp) string {
+	canonical := name
+	if upper := strings.ToUpper(canonical); upper != canonical {
+		v.add("uppercase", "slugs are uppercase", upper)
+		canonical = upper
+	}
+	if replaced := strings.NewReplacer("_", "-", " ", "-").Replace(canonical); replaced != canonical {
+		v.add("separator", "slug parts are separated by '-'", replaced)
+		canonical = replaced
+	}
+	if !strings.HasPrefix(canonical, prefix+"-") {
+		v.add("prefix", fmt.Sprintf("slug must start with %s-", prefix), "")
+		return ""
+	}
+
+	rest := strings.TrimPrefix(canonical, prefix+"-")
+	idx := strings.LastIndex(rest, "-")
+	if idx < 0 || !isDigits(rest[idx+1:]) {
+		v.add("sequence", fmt.Sprintf("slug must end with a sequence number, e.g. %s-%s-1", prefix, strings.Trim(rest, "-")), "")
+		return ""
+	}
+	domain, sequence := rest[:idx], rest[idx+1:]
+
+	if trimmed := strings.TrimLeft(sequence, "0"); trimmed != sequence {
+		if trimmed == "" {
+			v.add("positive_sequence", "sequence numbers start at 1", "")
+			return ""
+		}
+		v.add("no_zero_padding", "sequence numbers are simple integers (1, 2, 10 - not 001)", fmt.Sprintf("%s-%s-%s", prefix, domain, trimmed))
+		sequence = trimmed
+	}
+	if prefix == "CAP" && len(domain) > maxCapabilityDomainLength {
+		v.add("domain_length", fmt.Sprintf("capability domains are at most %d characters", maxCapabilityDomainLength), "")
+	} else if !domainPattern.MatchString(domain) {
+		v.add("domain", fmt.Sprintf("domain %q must be uppercase letters and digits, starting with a letter", domain), "")
+	}
+	return fmt.Sprintf("%s-%s-%s", prefix, domain, sequence)
+}
+
+// validateScopedName checks either a bare module/function name or its PREFIX-DOMAIN-N-name slug
+func validateScopedName(v *nameValidation, name, prefix string, validateLeaf func(*nameValidation, string) string) string {
+	if !strings.HasPrefix(strings.ToUpper(name), prefix+"-") {
+		return validateLeaf(v, name)
+	}
+
+	// PREFIX-DOMAIN-N-name: the capability part follows the capability rules
+	rest := name[len(prefix)+1:]
+	parts := strings.SplitN(rest, "-", 3)
+	if len(parts) != 3 {
+		v.add("scope", fmt.Sprintf("%s slugs are %s-<DOMAIN>-<N>-<name>", strings.ToLower(v.Kind), prefix), "")
+		return ""
+	}
+	capability := validateSequencedSlug(v, "CAP-"+parts[0]+"-"+parts[1], "CAP", capabilityDomainPattern)
+	leaf := validateLeaf(v, parts[2])
+	if capability == "" || leaf == "" {
+		return ""
+	}
+	return prefix + "-" + strings.TrimPrefix(capability, "CAP-") + "-" + leaf
+}
+
+// validateModuleName checks a module name and returns its canonical form
+func validateModuleName(v *nameValidation, name string) string {
+	if moduleNamePattern.MatchString(name) {
+		return name
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	suggestion := strings.TrimLeftFunc(b.String(), unicode.IsDigit)
+	v.add("module_name", "module names are lowercase letters and digits, starting with a letter", suggestion)
+	return suggestion
+}
+
+// validateFunctionName checks a function name and returns its camelCase form
+func validateFunctionName(v *nameValidation, name string) string {
+	if functionNamePattern.MatchString(name) {
+		return name
+	}
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r >= unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	var b strings.Builder
+	for i, word := range words {
+		if i == 0 {
+			b.WriteString(strings.ToLower(word[:1]) + word[1:])
+		} else {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	suggestion := strings.TrimLeftFunc(b.String(), unicode.IsDigit)
+	v.add("function_name", "function names are camelCase letters and digits, starting with a lowercase letter", suggestion)
+	return suggestion
+}
+
+// validateDirectory checks <slug>__<ULID8>
+func validateDirectory(v *nameValidation, name string) string {
+	idx := strings.LastIndex(name, "__")
+	if idx < 0 {
+		v.add("directory_format", "directories are <slug>__<ULID8>", "")
+		return ""
+	}
+	slug, suffix := name[:idx], name[idx+2:]
+
+	inner := validateName(slug, inferNameKind(slug))
+	v.Violations = append(v.Violations, inner.Violations...)
+
+	if upper := strings.ToUpper(suffix); upper != suffix && dirSuffixPattern.MatchString(upper) {
+		v.add("dir_suffix", "directory suffix is the first 8 characters of the uppercase ULID", upper)
+		suffix = upper
+	} else if !dirSuffixPattern.MatchString(suffix) {
+		v.add("dir_suffix", "directory suffix must be the first 8 characters of a ULID", "")
+		return ""
+	}
+	if inner.Suggestion == "" {
+		return ""
+	}
+	return inner.Suggestion + "__" + suffix
+}
+
+// validateCID checks cid:<namespace>:...:<ULID>
+func validateCID(v *nameValidation, name string) {
+	if !cidPattern.MatchString(name) {
+		v.add("cid_format", "CIDs are cid:<namespace>:<kind>:<ULID>", "")
+		return
+	}
+	id := name[strings.LastIndex(name, ":")+1:]
+	if _, err := ulid.ParseStrict(id); err != nil {
+		v.add("cid_ulid", fmt.Sprintf("CID must end with a 26 character ULID: %v", err), "")
+	}
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// handleValidateName reports every rule a name breaks, suggestions, and whether it is allocated
+func (a *NamingAgent) handleValidateName(request map[string]interface{}) map[string]interface{} {
+	params, ok := request["params"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{"error": "No params provided"}
+	}
+
+	name, ok := params["name"].(string)
+	if !ok {
+		return map[string]interface{}{"error": "Name required"}
+	}
+	kind, _ := params["kind"].(string)
+
+	v := validateName(name, kind)
+	violations := v.Violations
+	if violations == nil {
+		violations = []nameViolation{}
+	}
+	response := map[string]interface{}{
+		"name":       name,
+		"kind":       v.Kind,
+		"valid":      len(v.Violations) == 0,
+		"violations": violations,
+	}
+	if v.Suggestion != "" && v.Suggestion != name {
+		response["suggestion"] = v.Suggestion
+	}
+
+	// Report whether the (canonical) name is already taken
+	if key := nameRecordKey(v.Kind, v.Suggestion); key != "" {
+		exists, err := a.redisClient.Exists(a.ctx, key).Result()
+		if err == nil {
+			response["allocated"] = exists == 1
+		}
+	}
+	return response
+}
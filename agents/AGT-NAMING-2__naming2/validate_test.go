@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCapabilityLimitsAgree(t *testing.T) {
+	longest := strings.Repeat("A", maxCapabilityDomainLength)
+	if !capabilityDomainPattern.MatchString(longest) {
+		t.Fatalf("domain of %d characters is refused", maxCapabilityDomainLength)
+	}
+	if capabilityDomainPattern.MatchString(longest + "A") {
+		t.Fatalf("domain of %d characters is accepted", maxCapabilityDomainLength+1)
+	}
+
+	// Every slug an accepted domain yields up to sequence 999 passes validate_name
+	for _, slug := range []string{"CAP-" + longest + "-1", "CAP-" + longest + "-999"} {
+		if v := validateName(slug, "capability"); len(v.Violations) > 0 {
+			t.Errorf("%s: %+v", slug, v.Violations)
+		}
+	}
+	if v := validateName("CAP-"+longest+"-1000", "capability"); len(v.Violations) == 0 {
+		t.Errorf("slug over %d characters passed validation", maxCapabilitySlugLength)
+	}
+	if v := validateName("CAP-"+longest+"A-1", "capability"); len(v.Violations) == 0 {
+		t.Error("over-long domain passed validation")
+	}
+}
+
+func TestAllocateCapabilityValidatesSlug(t *testing.T) {
+	a, _ := newTestAgent(t)
+	longest := strings.Repeat("A", maxCapabilityDomainLength)
+
+	allocation := a.handleAllocateCapability(map[string]interface{}{
+		"params": map[string]interface{}{"domain": strings.ToLower(longest), "purpose": "limits"},
+	}, 0)
+	if errText, ok := allocation["error"]; ok {
+		t.Fatal(errText)
+	}
+	if slug := allocation["slug"].(string); len(validateName(slug, "capability").Violations) > 0 {
+		t.Errorf("allocated %s, which validate_name rejects", slug)
+	}
+
+	if response := a.handleAllocateCapability(map[string]interface{}{
+		"params": map[string]interface{}{"domain": longest + "A"},
+	}, 0); response["error"] == nil {
+		t.Errorf("allocated over-long domain: %v", response)
+	}
+
+	// Past sequence 999 the slug no longer fits, so allocation refuses it
+	if err := a.redisClient.Set(a.ctx, "centerfire.dev.sequence:CAP-"+longest, 999, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if response := a.handleAllocateCapability(map[string]interface{}{
+		"params": map[string]interface{}{"domain": longest},
+	}, 0); response["error"] == nil {
+		t.Errorf("allocated a slug over %d characters: %v", maxCapabilitySlugLength, response["slug"])
+	}
+}
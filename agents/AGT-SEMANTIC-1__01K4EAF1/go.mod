@@ -3,6 +3,7 @@ module centerfire/AGT-SEMANTIC-1
 go 1.25.1
 
 require (
+	centerfire/shared/config v0.0.0
 	github.com/redis/go-redis/v9 v9.13.0
 	github.com/weaviate/weaviate v1.24.1
 	github.com/weaviate/weaviate-go-client/v4 v4.13.1
@@ -38,5 +39,8 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d // indirect
 	google.golang.org/grpc v1.59.0 // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace centerfire/shared/config => ../../shared/config
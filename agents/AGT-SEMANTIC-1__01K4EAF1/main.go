@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 	"time"
 	
+	"centerfire/shared/config"
 	"github.com/redis/go-redis/v9"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
@@ -30,18 +32,23 @@ type SemanticAgent struct {
 }
 
 // NewAgent - Create new SEMANTIC agent
-func NewAgent() *SemanticAgent {
-	// Connect to Redis container on port 6380
+func NewAgent(endpoints *config.Config) *SemanticAgent {
+	// Connect to Redis container (6380 unless configured otherwise)
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
 	
-	// Connect to Weaviate on port 8080
+	// Connect to Weaviate (8080 unless configured otherwise)
+	weaviateURL, err := url.Parse(endpoints.Get(config.WeaviateURL))
+	if err != nil {
+		fmt.Printf("Invalid Weaviate URL: %v\n", err)
+		return nil
+	}
 	cfg := weaviate.Config{
-		Host:   "localhost:8080",
-		Scheme: "http",
+		Host:   weaviateURL.Host,
+		Scheme: weaviateURL.Scheme,
 	}
 	
 	client, err := weaviate.NewClient(cfg)
@@ -638,7 +645,17 @@ func (a *SemanticAgent) publishSemanticEvent(eventData map[string]interface{}) e
 }
 
 func main() {
-	agent := NewAgent()
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	agent := NewAgent(endpoints)
 	if agent == nil {
 		fmt.Printf("Failed to create agent\n")
 		return
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
@@ -65,7 +67,45 @@ func (a *SemdocAgent) HandleRequest(request map[string]interface{}) map[string]i
 	}
 }
 
+// RunTask - Handle the single request the manager queued for this ephemeral instance.
+// The request is read from TASK_DATA_FILE and the response written to TASK_RESULT_FILE.
+func (a *SemdocAgent) RunTask(dataFile, resultFile string) error {
+	data, err := os.ReadFile(dataFile)
+	if err != nil {
+		return fmt.Errorf("reading task data: %v", err)
+	}
+	var request map[string]interface{}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return fmt.Errorf("parsing task data: %v", err)
+	}
+
+	response := a.HandleRequest(request)
+
+	result, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("encoding task result: %v", err)
+	}
+	if resultFile != "" {
+		if err := os.WriteFile(resultFile, result, 0600); err != nil {
+			return fmt.Errorf("writing task result: %v", err)
+		}
+	}
+	if message, failed := response["error"]; failed {
+		return fmt.Errorf("%v", message)
+	}
+	return nil
+}
+
 func main() {
 	agent := NewAgent()
+
+	// Ephemeral task mode: the manager hands over one request and collects the result
+	if dataFile := os.Getenv("TASK_DATA_FILE"); dataFile != "" {
+		if err := agent.RunTask(dataFile, os.Getenv("TASK_RESULT_FILE")); err != nil {
+			log.Fatalf("Task failed: %v", err)
+		}
+		return
+	}
+
 	agent.Start()
 }
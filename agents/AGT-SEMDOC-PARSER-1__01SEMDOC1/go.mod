@@ -3,6 +3,7 @@ module agt-semdoc-parser-1
 go 1.21
 
 require (
+	centerfire/shared/config v0.0.0
 	github.com/go-redis/redis/v8 v8.11.5
 	golang.org/x/net v0.17.0
 )
@@ -10,4 +11,7 @@ require (
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+replace centerfire/shared/config => ../../shared/config
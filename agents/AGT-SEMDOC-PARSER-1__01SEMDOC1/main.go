@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/go-redis/redis/v8"
 	"golang.org/x/net/context"
 )
@@ -53,10 +54,10 @@ type SemBlockContract struct {
 	Metadata       map[string]string `json:"metadata"`
 }
 
-func NewSemDocParser() *SemDocParser {
+func NewSemDocParser(endpoints *config.Config) *SemDocParser {
 	return &SemDocParser{
 		redisClient: redis.NewClient(&redis.Options{
-			Addr: "localhost:6380",
+			Addr: endpoints.Get(config.RedisAddr),
 		}),
 		agentID: "AGT-SEMDOC-PARSER-1",
 	}
@@ -427,6 +428,15 @@ func (p *SemDocParser) startHeartbeat(ctx context.Context) {
 }
 
 func main() {
-	parser := NewSemDocParser()
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	parser := NewSemDocParser(endpoints)
 	parser.Start()
 }
\ No newline at end of file
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/go-redis/redis/v8"
 )
 
@@ -54,11 +55,11 @@ type StackResponse struct {
 	RequestID  string      `json:"request_id"`
 }
 
-func NewStackAgent() *StackAgent {
+func NewStackAgent(endpoints *config.Config) *StackAgent {
 	ctx := context.Background()
 	
 	redisClient := redis.NewClient(&redis.Options{
-		Addr: "localhost:6380",
+		Addr: endpoints.Get(config.RedisAddr),
 		DB:   0,
 	})
 
@@ -431,6 +432,15 @@ func (sa *StackAgent) startListening() {
 }
 
 func main() {
-	agent := NewStackAgent()
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		log.Fatalf("❌ Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	agent := NewStackAgent(endpoints)
 	agent.startListening()
 }
\ No newline at end of file
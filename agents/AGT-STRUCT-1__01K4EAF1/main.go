@@ -10,6 +10,7 @@ import (
 	"syscall"
 	"time"
 	
+	"centerfire/shared/config"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -24,10 +25,10 @@ type StructAgent struct {
 }
 
 // NewAgent - Create new STRUCT agent
-func NewAgent() *StructAgent {
-	// Connect to Redis container on port 6380
+func NewAgent(endpoints *config.Config) *StructAgent {
+	// Connect to Redis container (6380 unless configured otherwise)
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
@@ -286,6 +287,16 @@ func (a *StructAgent) delegateDocumentation(name, structType, cid string) {
 }
 
 func main() {
-	agent := NewAgent()
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	agent := NewAgent(endpoints)
 	agent.Start()
 }
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"centerfire/shared/messages"
+	"gopkg.in/yaml.v2"
+)
+
+// cidPattern mirrors AGT-NAMING-2: cid:<namespace>:...:<id>
+var cidPattern = regexp.MustCompile(`^cid:[A-Za-z0-9_.-]+(:[A-Za-z0-9_.-]+)+$`)
+
+// idFile holds a structure's canonical CID so tooling can resolve it after moves
+const idFile = ".id"
+
+// namingTimeout bounds registry lookups; validation still reports local drift without them
+const namingTimeout = 3 * time.Second
+
+// structureManifests is the YAML file describing each structure type, and the keys it must have
+var structureManifests = map[string]struct {
+	File     string
+	NameKey  string
+	Required []string
+}{
+	"capability": {File: "spec.yaml", NameKey: "name", Required: []string{"name", "cid", "type", "spec"}},
+	"module":     {File: "module.yaml", NameKey: "name", Required: []string{"name", "cid", "type", "spec"}},
+	"agent":      {File: "agent.yaml", NameKey: "agent_id", Required: []string{"agent_id", "cid"}},
+}
+
+// structureDrift is one difference between a structure and its template or the naming registry
+type structureDrift struct {
+	Check      string `json:"check"`
+	Path       string `json:"path,omitempty"`
+	Message    string `json:"message"`
+	Repairable bool   `json:"repairable"`
+	Repaired   bool   `json:"repaired,omitempty"`
+
+	repair func() error
+}
+
+// structureReport collects the result of validating one structure
+type structureReport struct {
+	Drift    []*structureDrift
+	Warnings []string
+}
+
+func (r *structureReport) drift(check, path, message string, repair func() error) {
+	r.Drift = append(r.Drift, &structureDrift{
+		Check:      check,
+		Path:       path,
+		Message:    message,
+		Repairable: repair != nil,
+		repair:     repair,
+	})
+}
+
+func (r *structureReport) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// handleValidateStructure handles validate_structure requests
+func (a *StructAgent) handleValidateStructure(env *messages.Envelope) map[string]interface{} {
+	var params messages.ValidateStructureParams
+	if err := env.DecodeParams(&params); err != nil {
+		return map[string]interface{}{
+			"error": err.Error(),
+			"agent": a.config.AgentID,
+		}
+	}
+
+	root, err := a.resolveProjectRoot(params.Environment)
+	if err != nil {
+		return map[string]interface{}{
+			"error": err.Error(),
+			"agent": a.config.AgentID,
+		}
+	}
+	dir, err := joinStructurePath(root, params.Type, params.Name)
+	if err != nil {
+		return map[string]interface{}{
+			"error": err.Error(),
+			"agent": a.config.AgentID,
+		}
+	}
+
+	report := &structureReport{Drift: []*structureDrift{}, Warnings: []string{}}
+	template, cid := a.checkStructure(report, dir, params)
+
+	repaired := 0
+	valid := true
+	for _, d := range report.Drift {
+		if params.Repair && d.repair != nil {
+			if err := d.repair(); err != nil {
+				report.warn("failed to repair %s: %v", d.Check, err)
+			} else {
+				d.Repaired = true
+				repaired++
+			}
+		}
+		if !d.Repaired {
+			valid = false
+		}
+	}
+
+	if repaired > 0 {
+		a.sendToClaude("structure_repaired", map[string]interface{}{
+			"name":     params.Name,
+			"type":     params.Type,
+			"cid":      cid,
+			"repaired": repaired,
+			"agent":    a.config.AgentID,
+		})
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"valid":    valid,
+		"name":     params.Name,
+		"type":     params.Type,
+		"path":     dir,
+		"cid":      cid,
+		"template": template,
+		"drift":    report.Drift,
+		"warnings": report.Warnings,
+		"repaired": repaired,
+		"agent":    a.config.AgentID,
+	}
+}
+
+// checkStructure runs every check against dir, returning the template and CID it settled on
+func (a *StructAgent) checkStructure(report *structureReport, dir string, params messages.ValidateStructureParams) (string, string) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		report.drift("missing_directory", "", fmt.Sprintf("%s does not exist; use create_structure", dir), nil)
+		return "", ""
+	}
+	if err := validateStructureName(params.Type, params.Name); err != nil {
+		report.drift("name", "", err.Error(), nil)
+	}
+
+	manifest := checkManifest(report, dir, params.Type, params.Name)
+	cid := checkCID(report, dir, manifest)
+
+	tmplName := a.checkTemplateFiles(report, dir, params, manifest, cid)
+
+	if cid != "" {
+		a.checkRegistry(report, params.Type, params.Name, cid)
+	}
+	return tmplName, cid
+}
+
+// checkManifest parses the type's manifest and checks its schema
+func checkManifest(report *structureReport, dir, structType, name string) map[string]interface{} {
+	spec := structureManifests[structType]
+	data, err := os.ReadFile(filepath.Join(dir, spec.File))
+	if err != nil {
+		// A missing manifest is reported (and repaired) with the other template files
+		if !os.IsNotExist(err) {
+			report.drift("manifest", spec.File, fmt.Sprintf("cannot read %s: %v", spec.File, err), nil)
+		}
+		return nil
+	}
+
+	var manifest map[string]interface{}
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		report.drift("manifest", spec.File, fmt.Sprintf("%s is not valid YAML: %v", spec.File, err), nil)
+		return nil
+	}
+
+	for _, key := range spec.Required {
+		if _, ok := manifest[key]; !ok {
+			report.drift("manifest_schema", spec.File, fmt.Sprintf("%s is missing %s", spec.File, key), nil)
+		}
+	}
+	if t, ok := manifest["type"]; ok && fmt.Sprint(t) != structType {
+		report.drift("manifest_schema", spec.File, fmt.Sprintf("%s declares type %v, expected %s", spec.File, t, structType), nil)
+	}
+	if _, ok := manifest["spec"]; ok {
+		if _, isMap := manifest["spec"].(map[interface{}]interface{}); !isMap {
+			report.drift("manifest_schema", spec.File, fmt.Sprintf("%s: spec must be a mapping", spec.File), nil)
+		}
+	}
+	if declared, ok := manifest[spec.NameKey]; ok {
+		if d := fmt.Sprint(declared); d != name && d != structureSlug(name) {
+			report.drift("manifest_name", spec.File, fmt.Sprintf("%s declares %s %s, directory is %s", spec.File, spec.NameKey, d, name), nil)
+		}
+	}
+	return manifest
+}
+
+// checkCID compares the manifest CID with .id and returns the structure's CID
+func checkCID(report *structureReport, dir string, manifest map[string]interface{}) string {
+	manifestCID := ""
+	if manifest != nil {
+		if v, ok := manifest["cid"]; ok {
+			manifestCID = fmt.Sprint(v)
+		}
+	}
+
+	idCID := ""
+	if data, err := os.ReadFile(filepath.Join(dir, idFile)); err == nil {
+		idCID = strings.TrimSpace(string(data))
+	}
+
+	cid := idCID
+	if cid == "" {
+		cid = manifestCID
+	}
+	if cid != "" && !cidPattern.MatchString(cid) {
+		report.drift("cid_format", idFile, fmt.Sprintf("%q is not a CID (cid:<namespace>:<kind>:<ULID>)", cid), nil)
+	}
+	if idCID != "" && manifestCID != "" && idCID != manifestCID {
+		report.drift("cid_mismatch", idFile, fmt.Sprintf(".id has %s but the manifest has %s", idCID, manifestCID), nil)
+	}
+	return cid
+}
+
+// checkTemplateFiles renders the structure's template and compares the result with dir
+func (a *StructAgent) checkTemplateFiles(report *structureReport, dir string, params messages.ValidateStructureParams, manifest map[string]interface{}, cid string) string {
+	templates, err := loadTemplates(a.templatesDir)
+	if err != nil {
+		report.warn("template check skipped: %v", err)
+		return ""
+	}
+
+	tmplName := params.Template
+	if tmplName == "" && manifest != nil {
+		if recorded, ok := manifest["template"].(string); ok {
+			if _, known := templates[recorded]; known || recorded == "default" {
+				tmplName = recorded
+			} else {
+				report.warn("recorded template %s is not registered; checking against the default", recorded)
+			}
+		}
+	}
+	tmpl, err := selectTemplate(templates, tmplName, params.Type)
+	if err != nil {
+		report.warn("template check skipped: %v", err)
+		return ""
+	}
+
+	data, err := tmpl.templateData(params.Name, cid, manifestValues(tmpl, manifest))
+	if err != nil {
+		report.warn("template check skipped: %v", err)
+		return tmpl.Name
+	}
+	files, err := tmpl.render(data)
+	if err != nil {
+		report.warn("template check skipped: %v", err)
+		return tmpl.Name
+	}
+
+	for _, file := range files {
+		file := file
+		path := filepath.Join(dir, file.Path)
+		existing, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			if cid == "" && (file.Path == idFile || file.Path == structureManifests[params.Type].File) {
+				// Rewriting these without a known CID would record an empty identity
+				report.drift("missing_file", file.Path, fmt.Sprintf("%s is missing and no CID is recorded to restore it from", file.Path), nil)
+				continue
+			}
+			report.drift("missing_file", file.Path, fmt.Sprintf("%s is missing", file.Path), func() error {
+				return writeFileAtomic(path, []byte(file.Content))
+			})
+			continue
+		}
+		if err != nil {
+			report.drift("unreadable_file", file.Path, err.Error(), nil)
+			continue
+		}
+
+		if file.Path == "go.mod" {
+			checkGoModule(report, path, string(existing), file.Content)
+		}
+	}
+	return tmpl.Name
+}
+
+// checkGoModule compares the go.mod module path with the one the template renders
+func checkGoModule(report *structureReport, path, existing, rendered string) {
+	want, have := goModulePath(rendered), goModulePath(existing)
+	if want == "" || have == want {
+		return
+	}
+	report.drift("go_mod_module", "go.mod", fmt.Sprintf("module path is %q, expected %q", have, want), func() error {
+		return writeFileAtomic(path, []byte(replaceGoModulePath(existing, want)))
+	})
+}
+
+// goModulePath returns the module path declared in go.mod contents
+func goModulePath(contents string) string {
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// replaceGoModulePath rewrites the module directive, leaving the rest of go.mod untouched
+func replaceGoModulePath(contents, module string) string {
+	lines := strings.Split(contents, "\n")
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "module" {
+			lines[i] = "module " + module
+			return strings.Join(lines, "\n")
+		}
+	}
+	return "module " + module + "\n" + contents
+}
+
+// manifestValues fills declared template variables from the manifest (Purpose <- spec.purpose)
+func manifestValues(tmpl *StructureTemplate, manifest map[string]interface{}) map[string]string {
+	values := map[string]string{}
+	if manifest == nil {
+		return values
+	}
+	spec, _ := manifest["spec"].(map[interface{}]interface{})
+	for _, v := range tmpl.Variables {
+		key := strings.ToLower(v.Name)
+		if value, ok := manifest[key]; ok && value != nil {
+			values[v.Name] = fmt.Sprint(value)
+		} else if value, ok := spec[key]; ok && value != nil {
+			values[v.Name] = fmt.Sprint(value)
+		}
+	}
+	return values
+}
+
+// checkRegistry asks AGT-NAMING-2 whether cid is live and still names this structure
+func (a *StructAgent) checkRegistry(report *structureReport, structType, name, cid string) {
+	response, err := a.requestNaming(messages.ActionResolveCID, &messages.NameRefParams{CID: cid})
+	if err != nil {
+		report.warn("naming registry check skipped: %v", err)
+		return
+	}
+	if msg, ok := response["error"].(string); ok {
+		report.drift("registry_cid", idFile, fmt.Sprintf("naming registry: %s", msg), nil)
+		return
+	}
+	if _, ok := response["name"]; !ok {
+		report.drift("registry_status", idFile, fmt.Sprintf("%s is no longer allocated (status %v)", cid, response["status"]), nil)
+		return
+	}
+
+	if kind, _ := response["kind"].(string); kind != "" && kind != structType {
+		report.drift("registry_kind", idFile, fmt.Sprintf("naming registry has %s as a %s", cid, kind), nil)
+	}
+	if status, _ := response["status"].(string); status == "deprecated" {
+		report.warn("%s is deprecated in the naming registry", cid)
+	}
+	record, _ := response["name"].(map[string]interface{})
+	if directory, _ := record["directory"].(string); directory != "" && directory != name {
+		report.drift("registry_name", "", fmt.Sprintf("naming registry places %s at %s", cid, directory), nil)
+	} else if slug, _ := response["slug"].(string); directory == "" && slug != "" && slug != structureSlug(name) {
+		report.drift("registry_name", "", fmt.Sprintf("naming registry names %s %s", cid, slug), nil)
+	}
+}
+
+// requestNaming sends a request to AGT-NAMING-2 and waits for the reply on a private channel
+func (a *StructAgent) requestNaming(action string, params interface{}) (map[string]interface{}, error) {
+	env, err := messages.NewRequest(action, params)
+	if err != nil {
+		return nil, err
+	}
+	env.Source = a.config.AgentID
+	env.ReplyTo = fmt.Sprintf("agent.struct.reply.%s", env.RequestID)
+
+	pubsub := a.redisClient.Subscribe(a.ctx, env.ReplyTo)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(a.ctx); err != nil {
+		return nil, fmt.Errorf("naming agent unreachable: %v", err)
+	}
+
+	data, _ := env.Marshal()
+	if err := a.redisClient.Publish(a.ctx, "agent.naming.request", data).Err(); err != nil {
+		return nil, fmt.Errorf("naming agent unreachable: %v", err)
+	}
+
+	select {
+	case msg := <-pubsub.Channel():
+		var response map[string]interface{}
+		if err := json.Unmarshal([]byte(msg.Payload), &response); err != nil {
+			return nil, fmt.Errorf("invalid naming response: %v", err)
+		}
+		return response, nil
+	case <-time.After(namingTimeout):
+		return nil, fmt.Errorf("naming agent did not answer within %s", namingTimeout)
+	case <-a.ctx.Done():
+		return nil, a.ctx.Err()
+	}
+}
+
+// writeFileAtomic replaces path with contents via a temp file in the same directory
+func writeFileAtomic(path string, contents []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
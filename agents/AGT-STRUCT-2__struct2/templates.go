@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// templateManifest is the file that marks a directory under templates/ as a scaffold
+const templateManifest = "template.yaml"
+
+// templateSuffix is stripped from rendered file names; other files are copied verbatim
+const templateSuffix = ".tmpl"
+
+// structureRoots maps structure types to the directory their instances are created under
+var structureRoots = map[string]string{
+	"capability": "capabilities",
+	"agent":      "agents",
+	"module":     "modules",
+}
+
+// templateFuncs are available to every template and file name
+var templateFuncs = template.FuncMap{
+	"lower":  strings.ToLower,
+	"upper":  strings.ToUpper,
+	"quote":  strconv.Quote,
+	"slug":   structureSlug,
+	"domain": structureDomain,
+}
+
+// structureSlug strips the __<suffix> from a directory name: CAP-AUTH-1__01J9F7Z8 -> CAP-AUTH-1
+func structureSlug(name string) string {
+	if idx := strings.LastIndex(name, "__"); idx > 0 {
+		return name[:idx]
+	}
+	return name
+}
+
+// structureDomain extracts the domain from a sequenced name: AGT-HTTP-GATEWAY-1__01K4EAF1 -> HTTP-GATEWAY
+func structureDomain(name string) string {
+	slug := structureSlug(name)
+	if idx := strings.Index(slug, "-"); idx > 0 {
+		slug = slug[idx+1:]
+	}
+	if idx := strings.LastIndex(slug, "-"); idx > 0 && strings.Trim(slug[idx+1:], "0123456789") == "" {
+		slug = slug[:idx]
+	}
+	return slug
+}
+
+// TemplateVariable is a variable a template declares in its manifest
+type TemplateVariable struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	Default     string `yaml:"default" json:"default,omitempty"`
+	Required    bool   `yaml:"required" json:"required"`
+}
+
+// StructureTemplate is a scaffold directory loaded from templates/<name>
+type StructureTemplate struct {
+	Name        string             `yaml:"name" json:"name"`
+	Type        string             `yaml:"type" json:"type"`
+	Description string             `yaml:"description" json:"description,omitempty"`
+	Default     bool               `yaml:"default" json:"default"`
+	Variables   []TemplateVariable `yaml:"variables" json:"variables"`
+	Files       []string           `yaml:"-" json:"files"`
+	dir         string
+}
+
+// RenderedFile is one file of a rendered structure, relative to the structure directory
+type RenderedFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// builtinVariables are supplied by the agent and cannot be declared or overridden
+var builtinVariables = []string{"Name", "CID", "Type", "Template", "Created", "SharedPath"}
+
+// sharedPath locates shared/ from a structure directory; every structure sits at <root>/<type dir>/<name>
+const sharedPath = "../../shared"
+
+// loadTemplates reads every template directory under dir. Directories without a manifest are
+// plain copy-me skeletons (like agent-template) and are skipped.
+func loadTemplates(dir string) (map[string]*StructureTemplate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates directory %s: %v", dir, err)
+	}
+
+	templates := make(map[string]*StructureTemplate)
+	defaults := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tmplDir := filepath.Join(dir, entry.Name())
+		manifest, err := os.ReadFile(filepath.Join(tmplDir, templateManifest))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", filepath.Join(tmplDir, templateManifest), err)
+		}
+
+		tmpl := &StructureTemplate{dir: tmplDir}
+		if err := yaml.Unmarshal(manifest, tmpl); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", filepath.Join(tmplDir, templateManifest), err)
+		}
+		if tmpl.Name == "" {
+			tmpl.Name = entry.Name()
+		}
+		if _, ok := structureRoots[tmpl.Type]; !ok {
+			return nil, fmt.Errorf("template %s: unknown structure type %q", tmpl.Name, tmpl.Type)
+		}
+		for _, v := range tmpl.Variables {
+			if isBuiltinVariable(v.Name) {
+				return nil, fmt.Errorf("template %s: variable %s is supplied by the agent", tmpl.Name, v.Name)
+			}
+		}
+		if _, dup := templates[tmpl.Name]; dup {
+			return nil, fmt.Errorf("template %s is defined twice", tmpl.Name)
+		}
+		if tmpl.Default {
+			if other, ok := defaults[tmpl.Type]; ok {
+				return nil, fmt.Errorf("templates %s and %s are both the default for %s", other, tmpl.Name, tmpl.Type)
+			}
+			defaults[tmpl.Type] = tmpl.Name
+		}
+
+		tmpl.Files, err = templateFiles(tmplDir)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %v", tmpl.Name, err)
+		}
+		templates[tmpl.Name] = tmpl
+	}
+	return templates, nil
+}
+
+// templateFiles lists a template's source files relative to its directory, manifest excluded
+func templateFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel != templateManifest {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// selectTemplate picks the named template, or the type's default for "" and "default"
+func selectTemplate(templates map[string]*StructureTemplate, name, structType string) (*StructureTemplate, error) {
+	if name == "" || name == "default" {
+		for _, tmpl := range templates {
+			if tmpl.Type == structType && tmpl.Default {
+				return tmpl, nil
+			}
+		}
+		return nil, fmt.Errorf("no default template for %s structures", structType)
+	}
+
+	tmpl, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown template: %s", name)
+	}
+	if tmpl.Type != structType {
+		return nil, fmt.Errorf("template %s creates %s structures, not %s", name, tmpl.Type, structType)
+	}
+	return tmpl, nil
+}
+
+// templateData resolves the values a template renders with: builtins, then declared defaults,
+// then the caller's values. Undeclared and missing required variables are errors.
+func (t *StructureTemplate) templateData(name, cid string, values map[string]string) (map[string]interface{}, error) {
+	declared := make(map[string]bool, len(t.Variables))
+	for _, v := range t.Variables {
+		declared[v.Name] = true
+	}
+	for key := range values {
+		if !declared[key] {
+			return nil, fmt.Errorf("template %s does not declare variable %s", t.Name, key)
+		}
+	}
+
+	data := map[string]interface{}{
+		"Name":     name,
+		"CID":      cid,
+		"Type":     t.Type,
+		"Template": t.Name,
+		"Created":  time.Now().UTC().Format(time.RFC3339),
+
+		"SharedPath": sharedPath,
+	}
+	var missing []string
+	for _, v := range t.Variables {
+		value, ok := values[v.Name]
+		if !ok || value == "" {
+			value = v.Default
+		}
+		if v.Required && value == "" {
+			missing = append(missing, v.Name)
+		}
+		data[v.Name] = value
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("template %s requires %s", t.Name, strings.Join(missing, ", "))
+	}
+	return data, nil
+}
+
+// render produces the structure's files without touching the filesystem outside the template
+func (t *StructureTemplate) render(data map[string]interface{}) ([]RenderedFile, error) {
+	files := make([]RenderedFile, 0, len(t.Files))
+	for _, rel := range t.Files {
+		path, err := executeTemplate(rel, rel, data)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: file name %s: %v", t.Name, rel, err)
+		}
+		path = filepath.Clean(path)
+		if path == "." || filepath.IsAbs(path) || strings.HasPrefix(path, ".."+string(filepath.Separator)) || path == ".." {
+			return nil, fmt.Errorf("template %s: file name %s renders outside the structure: %s", t.Name, rel, path)
+		}
+
+		source, err := os.ReadFile(filepath.Join(t.dir, rel))
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %v", t.Name, err)
+		}
+
+		content := string(source)
+		if strings.HasSuffix(path, templateSuffix) {
+			path = strings.TrimSuffix(path, templateSuffix)
+			if content, err = executeTemplate(rel, content, data); err != nil {
+				return nil, fmt.Errorf("template %s: %v", t.Name, err)
+			}
+		}
+		files = append(files, RenderedFile{Path: path, Content: content})
+	}
+	return files, nil
+}
+
+// executeTemplate renders text with strict missing-key handling
+func executeTemplate(name, text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writeRenderedFiles writes rendered files under dir
+func writeRenderedFiles(dir string, files []RenderedFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(file.Content), 0644); err != nil {
+			return fmt.Errorf("failed to create %s: %v", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// errStructureExists is returned when the target directory exists and force was not requested
+var errStructureExists = errors.New("structure already exists")
+
+// createStructureDir renders files into a temporary sibling of dir and renames it into place, so
+// a structure either appears complete or not at all. With force an existing directory is moved
+// aside first and restored if the swap fails.
+func createStructureDir(dir string, files []RenderedFile, force bool) (replaced bool, err error) {
+	parent, name := filepath.Dir(dir), filepath.Base(dir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return false, fmt.Errorf("failed to create %s: %v", parent, err)
+	}
+
+	info, err := os.Lstat(dir)
+	switch {
+	case err == nil && !force:
+		return false, fmt.Errorf("%w: %s (set force to replace it)", errStructureExists, dir)
+	case err == nil && !info.IsDir():
+		return false, fmt.Errorf("%s exists and is not a directory", dir)
+	case err != nil && !os.IsNotExist(err):
+		return false, fmt.Errorf("failed to check %s: %v", dir, err)
+	}
+	exists := err == nil
+
+	tmp, err := os.MkdirTemp(parent, "."+name+".tmp-")
+	if err != nil {
+		return false, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	defer os.RemoveAll(tmp) // no-op once renamed into place
+
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return false, fmt.Errorf("failed to set permissions on %s: %v", tmp, err)
+	}
+	if err := writeRenderedFiles(tmp, files); err != nil {
+		return false, err
+	}
+	if hasGoModule(files) {
+		if err := verifyGoModule(tmp); err != nil {
+			return false, err
+		}
+	}
+
+	if !exists {
+		if err := os.Rename(tmp, dir); err != nil {
+			return false, fmt.Errorf("failed to move %s into place: %v", dir, err)
+		}
+		return false, nil
+	}
+
+	backup := filepath.Join(parent, fmt.Sprintf(".%s.old-%d", name, time.Now().UnixNano()))
+	if err := os.Rename(dir, backup); err != nil {
+		return false, fmt.Errorf("failed to move existing %s aside: %v", dir, err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		if restoreErr := os.Rename(backup, dir); restoreErr != nil {
+			return false, fmt.Errorf("failed to replace %s: %v (previous contents left in %s: %v)", dir, err, backup, restoreErr)
+		}
+		return false, fmt.Errorf("failed to replace %s: %v", dir, err)
+	}
+	if err := os.RemoveAll(backup); err != nil {
+		log.Printf("Failed to remove replaced structure %s: %v", backup, err)
+	}
+	return true, nil
+}
+
+// handleListTemplates handles list_templates requests, optionally filtered by type
+func (a *StructAgent) handleListTemplates(params map[string]interface{}) map[string]interface{} {
+	templates, err := loadTemplates(a.templatesDir)
+	if err != nil {
+		return map[string]interface{}{
+			"error": err.Error(),
+			"agent": a.config.AgentID,
+		}
+	}
+
+	structType, _ := params["type"].(string)
+	list := make([]*StructureTemplate, 0, len(templates))
+	for _, tmpl := range templates {
+		if structType == "" || tmpl.Type == structType {
+			list = append(list, tmpl)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Type != list[j].Type {
+			return list[i].Type < list[j].Type
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	return map[string]interface{}{
+		"success":   true,
+		"templates": list,
+		"count":     len(list),
+		"agent":     a.config.AgentID,
+	}
+}
+
+func isBuiltinVariable(name string) bool {
+	for _, builtin := range builtinVariables {
+		if builtin == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Directory name rules, mirroring AGT-NAMING-2's validate.go: CAP-<DOMAIN>-<N> and
+// AGT-<DOMAIN>-<N> slugs with an optional __<ULID8> suffix, and lowercase module names.
+var (
+	capabilityDirPattern = regexp.MustCompile(`^CAP-[A-Z][A-Z0-9]{1,15}-[1-9][0-9]*(__[0-9A-HJKMNP-TV-Z]{8})?$`)
+	agentDirPattern      = regexp.MustCompile(`^AGT-[A-Z][A-Z0-9]*(-[A-Z][A-Z0-9]*)*-[1-9][0-9]*(__[0-9A-HJKMNP-TV-Z]{8})?$`)
+	moduleDirPattern     = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+)
+
+// reservedPrefixes may not be allocated through the naming authority, so nothing is created for them
+var reservedPrefixes = []string{"TEST-", "TEMP-", "DEPRECATED-", "EXPERIMENTAL-"}
+
+// validateStructureName checks a structure directory name for its type. Names are single path
+// elements, so a valid name can never escape the structure root.
+func validateStructureName(structType, name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || filepath.Base(name) != name {
+		return fmt.Errorf("invalid structure name %q: must be a single directory name", name)
+	}
+	for _, prefix := range reservedPrefixes {
+		if strings.HasPrefix(strings.ToUpper(name), prefix) {
+			return fmt.Errorf("invalid structure name %q: %s is reserved", name, prefix)
+		}
+	}
+
+	switch structType {
+	case "capability":
+		if !capabilityDirPattern.MatchString(name) {
+			return fmt.Errorf("invalid capability name %q: expected CAP-<DOMAIN>-<N> or CAP-<DOMAIN>-<N>__<ULID8>", name)
+		}
+	case "agent":
+		if !agentDirPattern.MatchString(name) {
+			return fmt.Errorf("invalid agent name %q: expected AGT-<DOMAIN>-<N> or AGT-<DOMAIN>-<N>__<ULID8>", name)
+		}
+	case "module":
+		if !moduleDirPattern.MatchString(name) {
+			return fmt.Errorf("invalid module name %q: module names are lowercase letters and digits, starting with a letter", name)
+		}
+	default:
+		return fmt.Errorf("unknown structure type: %s", structType)
+	}
+	return nil
+}
+
+// resolveProjectRoot returns the absolute project root configured for env
+func (a *StructAgent) resolveProjectRoot(env string) (string, error) {
+	if env == "" {
+		env = defaultEnvironment
+	}
+	root, ok := a.config.ProjectRoots[env]
+	if !ok || root == "" {
+		return "", fmt.Errorf("no project root configured for environment %s", env)
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid project root for %s: %v", env, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("project root for %s is unavailable: %v", env, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("project root for %s is not a directory: %s", env, abs)
+	}
+	return abs, nil
+}
+
+// structurePath returns root/<type dir>/<name> for a name that follows the naming rules
+func structurePath(root, structType, name string) (string, error) {
+	if err := validateStructureName(structType, name); err != nil {
+		return "", err
+	}
+	return joinStructurePath(root, structType, name)
+}
+
+// joinStructurePath returns root/<type dir>/<name>, refusing anything that resolves outside root.
+// Unlike structurePath it accepts existing directories that predate the naming rules.
+func joinStructurePath(root, structType, name string) (string, error) {
+	base, ok := structureRoots[structType]
+	if !ok {
+		return "", fmt.Errorf("unknown structure type: %s", structType)
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid structure name %q: must be a single directory name", name)
+	}
+
+	dir := filepath.Join(root, base, name)
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("structure path %s escapes project root %s", dir, root)
+	}
+
+	// A symlinked type directory would redirect writes outside the root
+	if info, err := os.Lstat(filepath.Join(root, base)); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", fmt.Errorf("%s is a symlink; refusing to create structures through it", filepath.Join(root, base))
+	}
+	return dir, nil
+}
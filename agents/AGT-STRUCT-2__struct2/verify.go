@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// verifyTimeout bounds each go command run against a generated structure
+const verifyTimeout = 2 * time.Minute
+
+// hasGoModule reports whether a rendered structure is a Go module that must compile
+func hasGoModule(files []RenderedFile) bool {
+	for _, file := range files {
+		if file.Path == "go.mod" {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyGoModule resolves dependencies for a generated Go module, then vets and builds it. Runs
+// in the staging directory, so a structure that does not compile is never moved into place.
+func verifyGoModule(dir string) error {
+	steps := [][]string{
+		{"mod", "tidy"},
+		{"vet", "./..."},
+		{"build", "-o", os.DevNull, "./..."},
+	}
+	for _, args := range steps {
+		if err := runGo(dir, args...); err != nil {
+			return fmt.Errorf("generated structure failed go %s: %v", args[0], err)
+		}
+	}
+	return nil
+}
+
+func runGo(dir string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", verifyTimeout)
+		}
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
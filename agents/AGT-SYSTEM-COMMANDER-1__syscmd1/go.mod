@@ -3,6 +3,8 @@ module agt-system-commander
 go 1.21
 
 require (
+	centerfire/shared/config v0.0.0
+	github.com/alicebob/miniredis/v2 v2.35.0
 	github.com/go-redis/redis/v8 v8.11.5
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -10,4 +12,7 @@ require (
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 )
+
+replace centerfire/shared/config => ../../shared/config
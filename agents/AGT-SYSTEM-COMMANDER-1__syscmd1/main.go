@@ -3,16 +3,18 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/go-redis/redis/v8"
-	"gopkg.in/yaml.v2"
 )
 
 type SystemCommander struct {
@@ -73,15 +75,32 @@ type CommandRequest struct {
 	Mode        string            `json:"mode,omitempty"`        // "direct", "tmux", "parallel", "sequence"
 	ShellID     string            `json:"shell_id,omitempty"`    // Specific shell to use
 	Purpose     string            `json:"purpose,omitempty"`     // "build", "test", "monitor", etc.
-	Parallel    []ParallelCommand `json:"parallel,omitempty"`    // Multiple commands to run in parallel
-	DependsOn   []string          `json:"depends_on,omitempty"` // Shell IDs this command depends on
+	Parallel    []ParallelCommand `json:"parallel,omitempty"`    // Multiple commands to run in parallel, or orchestration steps
 	Timeout     int               `json:"timeout,omitempty"`    // Command timeout in seconds
+	OnFailure   string            `json:"on_failure,omitempty"` // Orchestration: "fail_fast" (default) or "continue"
+}
+
+// defaultShellTimeout bounds a tmux command when the request sets no timeout
+const defaultShellTimeout = 60 * time.Second
+
+// shellTimeout is how long a tmux command may run before it is interrupted
+func (req CommandRequest) shellTimeout() time.Duration {
+	if req.Timeout > 0 {
+		return time.Duration(req.Timeout) * time.Second
+	}
+	return defaultShellTimeout
 }
 
 type ParallelCommand struct {
 	Command string `json:"command"`
 	ShellID string `json:"shell_id,omitempty"`
 	Purpose string `json:"purpose,omitempty"`
+	// Orchestration step fields
+	Name      string            `json:"name,omitempty"`       // Defaults to step_<n>
+	DependsOn []string          `json:"depends_on,omitempty"` // Names of steps that must succeed first
+	Timeout   int               `json:"timeout,omitempty"`    // Seconds per attempt; defaults to the request's
+	Retries   int               `json:"retries,omitempty"`    // Extra attempts after a failure
+	Env       map[string]string `json:"env,omitempty"`        // Variables set for this step's command only
 }
 
 type CommandResponse struct {
@@ -106,24 +125,18 @@ type ParallelCommandResult struct {
 	Error     string `json:"error,omitempty"`
 	ExitCode  int    `json:"exit_code"`
 	Duration  int64  `json:"duration_ms"`
+	// Orchestration fields
+	Name      string     `json:"name,omitempty"`
+	Status    StepStatus `json:"status,omitempty"`
+	Attempts  int        `json:"attempts,omitempty"`
 }
 
-type Contract struct {
-	Clients map[string]ClientPermissions `yaml:"clients"`
-}
-
-type ClientPermissions struct {
-	Commands []string `yaml:"commands"`
-	TTY      bool     `yaml:"tty"`
-	Sessions bool     `yaml:"sessions"`
-}
-
-func NewSystemCommander() *SystemCommander {
+func NewSystemCommander(endpoints *config.Config) *SystemCommander {
 	ctx := context.Background()
 	
 	// Connect to Redis
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
@@ -143,64 +156,6 @@ func NewSystemCommander() *SystemCommander {
 	}
 }
 
-func (sc *SystemCommander) loadContract() (*Contract, error) {
-	// Try to load contract, default to allow-all if not found
-	contractFile := "./contract.yaml"
-	
-	if _, err := os.Stat(contractFile); os.IsNotExist(err) {
-		// Create default allow-all contract
-		defaultContract := &Contract{
-			Clients: map[string]ClientPermissions{
-				"claude_code": {
-					Commands: []string{"*"}, // Allow all commands
-					TTY:      true,
-					Sessions: true,
-				},
-			},
-		}
-		
-		// Save default contract
-		data, _ := yaml.Marshal(defaultContract)
-		os.WriteFile(contractFile, data, 0644)
-		return defaultContract, nil
-	}
-
-	// Load existing contract
-	data, err := os.ReadFile(contractFile)
-	if err != nil {
-		return nil, err
-	}
-
-	var contract Contract
-	err = yaml.Unmarshal(data, &contract)
-	return &contract, err
-}
-
-func (sc *SystemCommander) isCommandAuthorized(clientID, command string) bool {
-	contract, err := sc.loadContract()
-	if err != nil {
-		log.Printf("Failed to load contract: %v", err)
-		return false
-	}
-
-	permissions, exists := contract.Clients[clientID]
-	if !exists {
-		return false
-	}
-
-	// Check if command is authorized
-	for _, allowedCmd := range permissions.Commands {
-		if allowedCmd == "*" {
-			return true // Allow all
-		}
-		if strings.HasPrefix(command, allowedCmd) {
-			return true
-		}
-	}
-	
-	return false
-}
-
 func (sc *SystemCommander) getOrCreateSession(clientID string) string {
 	sc.sessionsMutex.Lock()
 	defer sc.sessionsMutex.Unlock()
@@ -226,16 +181,6 @@ func (sc *SystemCommander) getOrCreateSession(clientID string) string {
 }
 
 func (sc *SystemCommander) executeCommand(req CommandRequest) CommandResponse {
-	// Check authorization
-	if !sc.isCommandAuthorized(req.ClientID, req.Command) {
-		return CommandResponse{
-			Success:   false,
-			Error:     fmt.Sprintf("Command not authorized for client: %s", req.ClientID),
-			RequestID: req.RequestID,
-			ExitCode:  -1,
-		}
-	}
-
 	// Determine execution mode
 	mode := req.Mode
 	if mode == "" {
@@ -246,6 +191,16 @@ func (sc *SystemCommander) executeCommand(req CommandRequest) CommandResponse {
 		}
 	}
 
+	// Check authorization of every command the request would run
+	if allowed, reason := sc.authorizeRequest(req, mode); !allowed {
+		return CommandResponse{
+			Success:   false,
+			Error:     fmt.Sprintf("Command not authorized for client %s: %s", req.ClientID, reason),
+			RequestID: req.RequestID,
+			ExitCode:  -1,
+		}
+	}
+
 	// Execute based on mode
 	switch mode {
 	case "direct":
@@ -266,6 +221,42 @@ func (sc *SystemCommander) executeCommand(req CommandRequest) CommandResponse {
 	}
 }
 
+// authorizeRequest checks req's command, or each of its parallel commands, against the contract.
+// Parallel and orchestrated commands run in tmux shells; orchestration steps' env is checked too.
+func (sc *SystemCommander) authorizeRequest(req CommandRequest, mode string) (bool, string) {
+	commands := []string{req.Command}
+	commandMode := "direct"
+	switch mode {
+	case "parallel", "orchestration":
+		commands = nil
+		for _, cmd := range req.Parallel {
+			commands = append(commands, cmd.Command)
+		}
+		commandMode = "tmux"
+	case "tmux":
+		commandMode = "tmux"
+	case "direct":
+	default:
+		if req.TTY {
+			commandMode = "tmux"
+		}
+	}
+
+	for _, command := range commands {
+		if allowed, reason := sc.isCommandAuthorized(req.ClientID, command, commandMode); !allowed {
+			return false, reason
+		}
+	}
+	if mode == "orchestration" {
+		for _, step := range req.Parallel {
+			if allowed, reason := sc.isEnvAuthorized(req.ClientID, step.Env); !allowed {
+				return false, reason
+			}
+		}
+	}
+	return true, ""
+}
+
 func (sc *SystemCommander) executeDirectly(req CommandRequest) CommandResponse {
 	// Execute command directly using exec.Command
 	log.Printf("DEBUG executeDirectly: Command: '%s', Length: %d", req.Command, len(req.Command))
@@ -307,8 +298,8 @@ func (sc *SystemCommander) executeDirectly(req CommandRequest) CommandResponse {
 func (sc *SystemCommander) executeInTmux(req CommandRequest) CommandResponse {
 	// Use shell pool for orchestrated execution
 	if req.ShellID != "" {
-		if shell, exists := sc.shellPool.GetShell(req.ShellID); exists {
-			output, err := sc.shellPool.ExecuteInShell(shell, req.Command)
+		if shell, exists := sc.shellPool.GetShell(req.ShellID); exists && shell.ClientID == req.ClientID {
+			output, exitCode, err := sc.shellPool.ExecuteInShell(shell, req.Command, req.shellTimeout())
 			return CommandResponse{
 				Success:     err == nil,
 				Output:      output,
@@ -316,7 +307,7 @@ func (sc *SystemCommander) executeInTmux(req CommandRequest) CommandResponse {
 				RequestID:   req.RequestID,
 				ShellID:     req.ShellID,
 				Mode:        "tmux",
-				ExitCode:    func() int { if err != nil { return -1 } else { return 0 } }(),
+				ExitCode:    exitCode,
 			}
 		} else {
 			return CommandResponse{
@@ -345,7 +336,7 @@ func (sc *SystemCommander) executeInTmux(req CommandRequest) CommandResponse {
 	}
 
 	// Execute command in shell
-	output, err := sc.shellPool.ExecuteInShell(shell, req.Command)
+	output, exitCode, err := sc.shellPool.ExecuteInShell(shell, req.Command, req.shellTimeout())
 	return CommandResponse{
 		Success:     err == nil,
 		Output:      output,
@@ -354,7 +345,7 @@ func (sc *SystemCommander) executeInTmux(req CommandRequest) CommandResponse {
 		ShellID:     shell.ID,
 		SessionName: shell.SessionName,
 		Mode:        "tmux",
-		ExitCode:    func() int { if err != nil { return -1 } else { return 0 } }(),
+		ExitCode:    exitCode,
 	}
 }
 
@@ -367,6 +358,12 @@ func NewShellPool(maxShells int, cleanupInterval time.Duration) *ShellPool {
 	}
 }
 
+// errNoFreeShell means every suitable shell is in use; one may free up once a command finishes
+var errNoFreeShell = errors.New("no free shell")
+
+// GetOrCreateShell returns a ready shell of the client's with purpose, creating one if there is
+// none. The shell is handed out in the waiting state so concurrent callers do not pick it too;
+// the caller's ExecuteInShell returns it to ready.
 func (sp *ShellPool) GetOrCreateShell(clientID, purpose string) (*Shell, error) {
 	sp.mutex.Lock()
 	defer sp.mutex.Unlock()
@@ -374,6 +371,7 @@ func (sp *ShellPool) GetOrCreateShell(clientID, purpose string) (*Shell, error)
 	// Look for existing available shell with same purpose
 	for _, shell := range sp.shells {
 		if shell.ClientID == clientID && shell.Purpose == purpose && shell.State == ShellStateReady && !shell.Busy {
+			shell.State = ShellStateWaiting
 			shell.LastUsed = time.Now()
 			return shell, nil
 		}
@@ -381,7 +379,7 @@ func (sp *ShellPool) GetOrCreateShell(clientID, purpose string) (*Shell, error)
 	
 	// Check if we can create a new shell
 	if len(sp.shells) >= sp.maxShells {
-		return nil, fmt.Errorf("maximum shells (%d) reached", sp.maxShells)
+		return nil, fmt.Errorf("%w: maximum shells (%d) reached", errNoFreeShell, sp.maxShells)
 	}
 	
 	// Create new shell
@@ -402,7 +400,7 @@ func (sp *ShellPool) GetOrCreateShell(clientID, purpose string) (*Shell, error)
 		Purpose:     purpose,
 		Created:     time.Now(),
 		LastUsed:    time.Now(),
-		State:       ShellStateReady,
+		State:       ShellStateWaiting,
 		Busy:        false,
 	}
 	
@@ -418,40 +416,142 @@ func (sp *ShellPool) GetShell(shellID string) (*Shell, bool) {
 	return shell, exists
 }
 
-func (sp *ShellPool) ExecuteInShell(shell *Shell, command string) (string, error) {
-	shell.mutex.Lock()
-	defer shell.mutex.Unlock()
-	
+// ReserveShell hands out the client's named shell like GetOrCreateShell does, or errNoFreeShell
+// while it is running or has been handed out for another command
+func (sp *ShellPool) ReserveShell(clientID, shellID string) (*Shell, error) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	shell, exists := sp.shells[shellID]
+	if !exists || shell.ClientID != clientID {
+		return nil, fmt.Errorf("Shell %s not found", shellID)
+	}
+	if shell.State != ShellStateReady || shell.Busy {
+		return nil, fmt.Errorf("%w: shell %s is busy", errNoFreeShell, shellID)
+	}
+	shell.State = ShellStateWaiting
+	shell.LastUsed = time.Now()
+	return shell, nil
+}
+
+// startCommand marks shell busy. Shell state only changes under the pool's lock, which is also
+// held while the pool picks shells to hand out.
+func (sp *ShellPool) startCommand(shell *Shell) error {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
 	if shell.Busy {
-		return "", fmt.Errorf("shell %s is busy", shell.ID)
+		return fmt.Errorf("shell %s is busy", shell.ID)
 	}
-	
 	shell.Busy = true
 	shell.State = ShellStateBusy
-	defer func() {
-		shell.Busy = false
-		shell.State = ShellStateReady
-		shell.LastUsed = time.Now()
-	}()
+	return nil
+}
+
+// releaseShell returns shell to the pool once its command has finished
+func (sp *ShellPool) releaseShell(shell *Shell) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+	shell.Busy = false
+	shell.State = ShellStateReady
+	shell.LastUsed = time.Now()
+}
+
+// ExecuteInShell runs command in shell and waits for it to finish or for timeout, when it is
+// interrupted. It returns only this command's output and its exit status; a non-zero status is
+// also reported as an error.
+func (sp *ShellPool) ExecuteInShell(shell *Shell, command string, timeout time.Duration) (string, int, error) {
+	// shell.mutex keeps one command at a time typing into the pane
+	shell.mutex.Lock()
+	defer shell.mutex.Unlock()
 	
-	// Send command to tmux session
-	cmd := exec.Command("tmux", "send-keys", "-t", shell.SessionName, command, "Enter")
-	err := cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("failed to send command to tmux: %v", err)
+	if err := sp.startCommand(shell); err != nil {
+		return "", -1, err
 	}
+	defer sp.releaseShell(shell)
 	
-	// Wait for command to execute
-	time.Sleep(500 * time.Millisecond)
+	// Bracket the command with sentinels. The empty quotes keep the typed line, which tmux echoes
+	// into the pane, from matching the markers the shell prints.
+	id := fmt.Sprintf("%d", time.Now().UnixNano())
+	beginMarker := "__SC_BEGIN_" + id + "__"
+	endMarker := "__SC_END_" + id + "__:"
+	wrapped := fmt.Sprintf(`echo __SC_BEGIN""_%s__; %s; echo __SC_END""_%s__:$?`, id, command, id)
 	
-	// Capture output
-	captureCmd := exec.Command("tmux", "capture-pane", "-t", shell.SessionName, "-p")
-	output, err := captureCmd.Output()
-	if err != nil {
-		return "(failed to capture output)", nil
+	// -l sends the text literally, so words like "Enter" or "C-c" in the command are not key names
+	if err := exec.Command("tmux", "send-keys", "-t", shell.SessionName, "-l", wrapped).Run(); err != nil {
+		return "", -1, fmt.Errorf("failed to send command to tmux: %v", err)
+	}
+	if err := exec.Command("tmux", "send-keys", "-t", shell.SessionName, "Enter").Run(); err != nil {
+		return "", -1, fmt.Errorf("failed to send command to tmux: %v", err)
 	}
 	
-	return string(output), nil
+	deadline := time.Now().Add(timeout)
+	for {
+		pane, err := capturePane(shell.SessionName)
+		if err != nil {
+			return "", -1, fmt.Errorf("failed to capture output: %v", err)
+		}
+		output, exitCode, done := extractCommandOutput(pane, beginMarker, endMarker)
+		if done {
+			if exitCode != 0 {
+				return output, exitCode, fmt.Errorf("exit status %d", exitCode)
+			}
+			return output, 0, nil
+		}
+		if time.Now().After(deadline) {
+			// Interrupt the command so the shell is usable again; the end marker is never printed
+			exec.Command("tmux", "send-keys", "-t", shell.SessionName, "C-c").Run()
+			return output, -1, fmt.Errorf("command timed out after %s", timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// capturePane returns the pane's whole scrollback with wrapped lines joined
+func capturePane(sessionName string) (string, error) {
+	output, err := exec.Command("tmux", "capture-pane", "-t", sessionName, "-p", "-J", "-S", "-").Output()
+	return string(output), err
+}
+
+// truncatedNotice replaces output that scrolled out of the pane's history
+const truncatedNotice = "... (earlier output exceeded the shell's scrollback)\n"
+
+// extractCommandOutput finds the lines between beginMarker and the end marker line, which carries
+// the exit status after endMarker. Until the end marker appears it returns the output so far with
+// done false.
+func extractCommandOutput(pane, beginMarker, endMarker string) (output string, exitCode int, done bool) {
+	lines := strings.Split(pane, "\n")
+	begin := -1
+	for i, line := range lines {
+		line = strings.TrimRight(line, " ")
+		if line == beginMarker {
+			begin = i
+			continue
+		}
+		if strings.HasPrefix(line, endMarker) {
+			code, err := strconv.Atoi(strings.TrimPrefix(line, endMarker))
+			if err != nil {
+				code = -1
+			}
+			if begin < 0 {
+				// The begin marker has scrolled away; everything still visible is this command's
+				return truncatedNotice + joinOutput(lines[:i]), code, true
+			}
+			return joinOutput(lines[begin+1 : i]), code, true
+		}
+	}
+	if begin < 0 {
+		return "", 0, false
+	}
+	return joinOutput(lines[begin+1:]), 0, false
+}
+
+func joinOutput(lines []string) string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
 }
 
 func (sp *ShellPool) ListShells() []*Shell {
@@ -465,6 +565,13 @@ func (sp *ShellPool) ListShells() []*Shell {
 	return shells
 }
 
+// shellUsage reads the fields of shell that change while it is in use
+func (sp *ShellPool) shellUsage(shell *Shell) (ShellState, bool, time.Time) {
+	sp.mutex.RLock()
+	defer sp.mutex.RUnlock()
+	return shell.State, shell.Busy, shell.LastUsed
+}
+
 func (sp *ShellPool) CleanupIdleShells(idleTimeout time.Duration) {
 	sp.mutex.Lock()
 	defer sp.mutex.Unlock()
@@ -634,7 +741,7 @@ func (sc *SystemCommander) executeParallel(req CommandRequest) CommandResponse {
 			}
 			
 			// Execute command
-			output, err := sc.shellPool.ExecuteInShell(shell, cmd.Command)
+			output, exitCode, err := sc.shellPool.ExecuteInShell(shell, cmd.Command, req.shellTimeout())
 			
 			results[index] = ParallelCommandResult{
 				Command:  cmd.Command,
@@ -642,7 +749,7 @@ func (sc *SystemCommander) executeParallel(req CommandRequest) CommandResponse {
 				Success:  err == nil,
 				Output:   output,
 				Error:    func() string { if err != nil { return err.Error() } else { return "" } }(),
-				ExitCode: func() int { if err != nil { return -1 } else { return 0 } }(),
+				ExitCode: exitCode,
 				Duration: time.Since(startTime).Milliseconds(),
 			}
 		}(i, parallelCmd)
@@ -678,14 +785,6 @@ func (sc *SystemCommander) executeParallel(req CommandRequest) CommandResponse {
 	}
 }
 
-func (sc *SystemCommander) executeOrchestration(req CommandRequest) CommandResponse {
-	// For now, orchestration mode just delegates to parallel
-	// In Phase 3, this will handle dependencies and signaling
-	log.Printf("Orchestration mode requested - delegating to parallel execution for now")
-	req.Mode = "parallel"
-	return sc.executeParallel(req)
-}
-
 // Status and management methods
 func (sc *SystemCommander) getShellStatus() CommandResponse {
 	shells := sc.shellPool.ListShells()
@@ -698,10 +797,11 @@ func (sc *SystemCommander) getShellStatus() CommandResponse {
 		statusOutput.WriteString(fmt.Sprintf("Shell ID: %s\n", shell.ID))
 		statusOutput.WriteString(fmt.Sprintf("  Client: %s\n", shell.ClientID))
 		statusOutput.WriteString(fmt.Sprintf("  Purpose: %s\n", shell.Purpose))
-		statusOutput.WriteString(fmt.Sprintf("  State: %s\n", shell.State))
-		statusOutput.WriteString(fmt.Sprintf("  Busy: %t\n", shell.Busy))
+		state, busy, lastUsed := sc.shellPool.shellUsage(shell)
+		statusOutput.WriteString(fmt.Sprintf("  State: %s\n", state))
+		statusOutput.WriteString(fmt.Sprintf("  Busy: %t\n", busy))
 		statusOutput.WriteString(fmt.Sprintf("  Created: %s\n", shell.Created.Format(time.RFC3339)))
-		statusOutput.WriteString(fmt.Sprintf("  Last Used: %s\n", shell.LastUsed.Format(time.RFC3339)))
+		statusOutput.WriteString(fmt.Sprintf("  Last Used: %s\n", lastUsed.Format(time.RFC3339)))
 		statusOutput.WriteString("\n")
 	}
 	
@@ -838,9 +938,18 @@ func (sc *SystemCommander) enableConversationMonitoring(sessionName string) {
 }
 
 func main() {
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
 	log.Println("Starting AGT-SYSTEM-COMMANDER-1...")
 
-	sc := NewSystemCommander()
+	sc := NewSystemCommander(endpoints)
 	
 	// Register with manager
 	sc.registerWithManager()
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// orchestrationEventsChannel carries a StepEvent each time an orchestration step changes status.
+// The final report is published on agent.system.response like any other response.
+const orchestrationEventsChannel = "agent.system.events"
+
+// retryDelay is the pause before a failed step is attempted again
+const retryDelay = time.Second
+
+// shellWaitInterval is how often a step waiting for a free shell checks again
+const shellWaitInterval = 250 * time.Millisecond
+
+// Failure policies for an orchestration request
+const (
+	FailurePolicyFailFast = "fail_fast" // start no further steps once one has failed
+	FailurePolicyContinue = "continue"  // keep running every step whose dependencies succeeded
+)
+
+type StepStatus string
+
+const (
+	StepRunning   StepStatus = "running"
+	StepRetrying  StepStatus = "retrying"
+	StepSucceeded StepStatus = "succeeded"
+	StepFailed    StepStatus = "failed"
+	StepSkipped   StepStatus = "skipped"
+)
+
+// StepEvent reports one status change of an orchestration step
+type StepEvent struct {
+	RequestID string     `json:"request_id"`
+	ClientID  string     `json:"client_id"`
+	Step      string     `json:"step"`
+	Status    StepStatus `json:"status"`
+	Attempt   int        `json:"attempt,omitempty"`
+	ShellID   string     `json:"shell_id,omitempty"`
+	ExitCode  int        `json:"exit_code"`
+	Error     string     `json:"error,omitempty"`
+	Duration  int64      `json:"duration_ms"`
+	Timestamp string     `json:"timestamp"`
+}
+
+// executeOrchestration runs req.Parallel as a graph of named steps. A step starts once every step
+// in its depends_on has succeeded and is skipped if any of them failed or was skipped. Under
+// fail_fast, the default, a failure also skips every step that has not started yet; steps already
+// running are left to finish. Independent steps run concurrently, each in a shell of its own while
+// it runs.
+func (sc *SystemCommander) executeOrchestration(req CommandRequest) CommandResponse {
+	steps, dependencies, err := planSteps(req)
+	if err != nil {
+		return CommandResponse{
+			Success:   false,
+			Error:     err.Error(),
+			RequestID: req.RequestID,
+			Mode:      "orchestration",
+			ExitCode:  -1,
+		}
+	}
+	policy := req.OnFailure
+	if policy == "" {
+		policy = FailurePolicyFailFast
+	}
+	failFast := policy == FailurePolicyFailFast
+
+	log.Printf("Orchestrating %d steps for %s (on_failure: %s)", len(steps), req.ClientID, policy)
+	startTime := time.Now()
+
+	results := make([]ParallelCommandResult, len(steps))
+	done := make([]chan struct{}, len(steps))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	// aborted is closed when a fail_fast failure stops the run; abortReason is set before it is
+	var (
+		aborted     = make(chan struct{})
+		abortOnce   sync.Once
+		abortReason string
+	)
+
+	var wg sync.WaitGroup
+	for i := range steps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+
+			step := steps[i]
+			for _, dep := range dependencies[i] {
+				<-done[dep]
+			}
+
+			skipReason := ""
+			for _, dep := range dependencies[i] {
+				if results[dep].Status != StepSucceeded {
+					skipReason = fmt.Sprintf("dependency %s %s", steps[dep].Name, results[dep].Status)
+					break
+				}
+			}
+			if skipReason == "" {
+				select {
+				case <-aborted:
+					skipReason = abortReason
+				default:
+				}
+			}
+			if skipReason != "" {
+				results[i] = ParallelCommandResult{
+					Command:  step.Command,
+					ShellID:  step.ShellID,
+					Error:    skipReason,
+					ExitCode: -1,
+					Name:     step.Name,
+					Status:   StepSkipped,
+				}
+				sc.publishStepEvent(req, results[i], 0)
+				return
+			}
+
+			results[i] = sc.runStep(req, step, aborted)
+			if results[i].Status == StepFailed && failFast {
+				abortOnce.Do(func() {
+					abortReason = fmt.Sprintf("step %s failed", step.Name)
+					close(aborted)
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	counts := make(map[StepStatus]int)
+	var report strings.Builder
+	for _, result := range results {
+		counts[result.Status]++
+		report.WriteString(fmt.Sprintf("=== [%s] %s: %s ===\n", result.Status, result.Name, result.Command))
+		switch result.Status {
+		case StepSucceeded:
+			report.WriteString(result.Output)
+		case StepFailed:
+			report.WriteString(result.Output)
+			report.WriteString(fmt.Sprintf("ERROR: %s\n", result.Error))
+		case StepSkipped:
+			report.WriteString(fmt.Sprintf("Skipped: %s\n", result.Error))
+		}
+		if result.Attempts > 0 {
+			report.WriteString(fmt.Sprintf("Attempts: %d, Duration: %dms, Shell: %s\n", result.Attempts, result.Duration, result.ShellID))
+		}
+		report.WriteString("\n")
+	}
+	overallSuccess := counts[StepSucceeded] == len(results)
+	exitCode := 0
+	if !overallSuccess {
+		exitCode = 1
+	}
+	report.WriteString(fmt.Sprintf("=== Orchestration finished in %dms: %d succeeded, %d failed, %d skipped ===\n",
+		time.Since(startTime).Milliseconds(), counts[StepSucceeded], counts[StepFailed], counts[StepSkipped]))
+
+	return CommandResponse{
+		Success:      overallSuccess,
+		Output:       report.String(),
+		RequestID:    req.RequestID,
+		Mode:         "orchestration",
+		Results:      results,
+		ActiveShells: len(sc.shellPool.ListShells()),
+		ExitCode:     exitCode,
+	}
+}
+
+// runStep runs step until it succeeds or its retries are used up. Retries stop early once the run
+// has been aborted.
+func (sc *SystemCommander) runStep(req CommandRequest, step ParallelCommand, aborted <-chan struct{}) ParallelCommandResult {
+	startTime := time.Now()
+	result := ParallelCommandResult{
+		Command: step.Command,
+		ShellID: step.ShellID,
+		Name:    step.Name,
+	}
+
+	timeout := req.shellTimeout()
+	if step.Timeout > 0 {
+		timeout = time.Duration(step.Timeout) * time.Second
+	}
+	command := withEnv(step.Command, step.Env)
+
+	for attempt := 1; ; attempt++ {
+		result.Attempts = attempt
+		result.Status = StepRunning
+		sc.publishStepEvent(req, result, time.Since(startTime))
+
+		shell, err := sc.stepShell(req.ClientID, step, aborted)
+		if err == nil {
+			result.ShellID = shell.ID
+			result.Output, result.ExitCode, err = sc.shellPool.ExecuteInShell(shell, command, timeout)
+		} else {
+			result.Output, result.ExitCode = "", -1
+		}
+		if err == nil {
+			result.Success = true
+			result.Error = ""
+			result.Status = StepSucceeded
+			break
+		}
+		result.Error = err.Error()
+
+		retry := attempt <= step.Retries
+		if retry {
+			select {
+			case <-aborted:
+				retry = false
+			default:
+			}
+		}
+		if !retry {
+			result.Status = StepFailed
+			break
+		}
+		result.Status = StepRetrying
+		sc.publishStepEvent(req, result, time.Since(startTime))
+		time.Sleep(retryDelay)
+	}
+
+	result.Duration = time.Since(startTime).Milliseconds()
+	sc.publishStepEvent(req, result, time.Since(startTime))
+	return result
+}
+
+// stepShell is the shell step names, or a free one from the pool for the step's purpose. It waits
+// while that shell is busy or the pool is full; waiting is not an attempt, so it costs no retry.
+// It gives up once the run is aborted.
+func (sc *SystemCommander) stepShell(clientID string, step ParallelCommand, aborted <-chan struct{}) (*Shell, error) {
+	// Steps without a purpose share the orchestration shells. The pool hands a shell to one
+	// command at a time, so concurrent steps get different shells.
+	purpose := step.Purpose
+	if purpose == "" {
+		purpose = "orchestration"
+	}
+
+	for {
+		var shell *Shell
+		var err error
+		if step.ShellID != "" {
+			shell, err = sc.shellPool.ReserveShell(clientID, step.ShellID)
+		} else {
+			shell, err = sc.shellPool.GetOrCreateShell(clientID, purpose)
+		}
+		if err == nil {
+			return shell, nil
+		}
+		if !errors.Is(err, errNoFreeShell) {
+			return nil, fmt.Errorf("Failed to get shell: %v", err)
+		}
+
+		select {
+		case <-aborted:
+			return nil, fmt.Errorf("Failed to get shell: %v; run aborted", err)
+		case <-sc.ctx.Done():
+			return nil, fmt.Errorf("Failed to get shell: %v", sc.ctx.Err())
+		case <-time.After(shellWaitInterval):
+		}
+	}
+}
+
+func (sc *SystemCommander) publishStepEvent(req CommandRequest, result ParallelCommandResult, elapsed time.Duration) {
+	event := StepEvent{
+		RequestID: req.RequestID,
+		ClientID:  req.ClientID,
+		Step:      result.Name,
+		Status:    result.Status,
+		Attempt:   result.Attempts,
+		ShellID:   result.ShellID,
+		ExitCode:  result.ExitCode,
+		Error:     result.Error,
+		Duration:  elapsed.Milliseconds(),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	log.Printf("Step %s/%s %s (attempt %d)", req.RequestID, result.Name, result.Status, result.Attempts)
+
+	data, _ := json.Marshal(event)
+	if err := sc.RedisClient.Publish(sc.ctx, orchestrationEventsChannel, string(data)).Err(); err != nil {
+		log.Printf("Failed to publish step event: %v", err)
+	}
+}
+
+// planSteps names req's steps and resolves each step's dependencies to indexes. It rejects
+// duplicate names, unknown dependencies and cycles before anything runs.
+func planSteps(req CommandRequest) ([]ParallelCommand, [][]int, error) {
+	if len(req.Parallel) == 0 {
+		return nil, nil, fmt.Errorf("No orchestration steps specified")
+	}
+	switch req.OnFailure {
+	case "", FailurePolicyFailFast, FailurePolicyContinue:
+	default:
+		return nil, nil, fmt.Errorf("unknown on_failure policy %q (want %s or %s)", req.OnFailure, FailurePolicyFailFast, FailurePolicyContinue)
+	}
+
+	steps := make([]ParallelCommand, len(req.Parallel))
+	index := make(map[string]int, len(steps))
+	for i, step := range req.Parallel {
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step_%d", i+1)
+		}
+		if _, exists := index[step.Name]; exists {
+			return nil, nil, fmt.Errorf("duplicate step name %q", step.Name)
+		}
+		if step.Timeout < 0 || step.Retries < 0 {
+			return nil, nil, fmt.Errorf("step %s: timeout and retries must not be negative", step.Name)
+		}
+		index[step.Name] = i
+		steps[i] = step
+	}
+
+	dependencies := make([][]int, len(steps))
+	for i, step := range steps {
+		for _, name := range step.DependsOn {
+			dep, exists := index[name]
+			if !exists {
+				return nil, nil, fmt.Errorf("step %s depends on unknown step %q", step.Name, name)
+			}
+			dependencies[i] = append(dependencies[i], dep)
+		}
+	}
+
+	// Repeatedly retire steps whose dependencies have all been retired; whatever remains is on
+	// or behind a cycle
+	remaining := make([]int, len(steps))
+	dependents := make([][]int, len(steps))
+	var ready []int
+	for i, deps := range dependencies {
+		remaining[i] = len(deps)
+		for _, dep := range deps {
+			dependents[dep] = append(dependents[dep], i)
+		}
+		if len(deps) == 0 {
+			ready = append(ready, i)
+		}
+	}
+	retired := 0
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		retired++
+		for _, dependent := range dependents[i] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	if retired < len(steps) {
+		var blocked []string
+		for i, n := range remaining {
+			if n > 0 {
+				blocked = append(blocked, steps[i].Name)
+			}
+		}
+		return nil, nil, fmt.Errorf("dependency cycle among steps: %s", strings.Join(blocked, ", "))
+	}
+
+	return steps, dependencies, nil
+}
+
+// withEnv prefixes command with single-quoted assignments, which the shell applies to that command
+// alone. isEnvAuthorized has already checked the names.
+func withEnv(command string, env map[string]string) string {
+	if len(env) == 0 {
+		return command
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(fmt.Sprintf("%s='%s' ", name, strings.ReplaceAll(env[name], "'", `'\''`)))
+	}
+	b.WriteString(command)
+	return b.String()
+}
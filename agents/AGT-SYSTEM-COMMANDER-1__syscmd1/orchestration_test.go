@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestCommander returns a commander on an in-memory Redis whose shells run on a tmux server of
+// the test's own
+func newTestCommander(t *testing.T, maxShells int) *SystemCommander {
+	t.Helper()
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	t.Setenv("TMUX", "")
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		exec.Command("tmux", "kill-server").Run()
+		client.Close()
+	})
+
+	return &SystemCommander{
+		RedisClient: client,
+		ctx:         context.Background(),
+		agentID:     "AGT-SYSTEM-COMMANDER-1",
+		shellPool:   NewShellPool(maxShells, time.Minute),
+		sessions:    make(map[string]*TmuxSession),
+	}
+}
+
+// Orchestration steps share a small pool from several requests at once while the pool is read and
+// swept. Run with -race.
+func TestOrchestrationConcurrentSteps(t *testing.T) {
+	sc := newTestCommander(t, 3)
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			sc.getShellStatus()
+			sc.shellPool.CleanupIdleShells(time.Hour)
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	// More steps than shells, so steps wait for each other's shells
+	run := func(request int, steps []ParallelCommand) CommandResponse {
+		return sc.executeOrchestration(CommandRequest{
+			ClientID:  "claude_code",
+			RequestID: fmt.Sprintf("req-%d", request),
+			Mode:      "orchestration",
+			Parallel:  steps,
+			Timeout:   30,
+		})
+	}
+	var wg sync.WaitGroup
+	responses := make([]CommandResponse, 3)
+	for r := range responses {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			var steps []ParallelCommand
+			for i := 0; i < 4; i++ {
+				steps = append(steps, ParallelCommand{Name: fmt.Sprintf("s%d", i), Command: fmt.Sprintf("echo out-%d-%d", r, i)})
+			}
+			steps = append(steps, ParallelCommand{Name: "last", Command: fmt.Sprintf("echo out-%d-last", r), DependsOn: []string{"s0", "s1"}})
+			responses[r] = run(r, steps)
+		}(r)
+	}
+	wg.Wait()
+
+	// Steps naming the same shell take turns on it
+	shells := sc.shellPool.ListShells()
+	if len(shells) == 0 || len(shells) > 3 {
+		t.Fatalf("pool holds %d shells, want 1 to 3", len(shells))
+	}
+	var pinned []ParallelCommand
+	for i := 0; i < 3; i++ {
+		pinned = append(pinned, ParallelCommand{Name: fmt.Sprintf("p%d", i), Command: fmt.Sprintf("echo pinned-%d", i), ShellID: shells[0].ID})
+	}
+	pinnedResponse := run(len(responses), pinned)
+
+	close(done)
+	readers.Wait()
+
+	for r, response := range append(responses, pinnedResponse) {
+		if !response.Success {
+			t.Errorf("request %d failed:\n%s", r, response.Output)
+			continue
+		}
+		for _, result := range response.Results {
+			want := strings.TrimPrefix(result.Command, "echo ")
+			if strings.TrimSpace(result.Output) != want {
+				t.Errorf("request %d step %s: output %q, want %q", r, result.Name, result.Output, want)
+			}
+		}
+	}
+	for _, shell := range sc.shellPool.ListShells() {
+		if state, busy, _ := sc.shellPool.shellUsage(shell); state != ShellStateReady || busy {
+			t.Errorf("shell %s left %s (busy=%t)", shell.ID, state, busy)
+		}
+	}
+}
+
+func TestReserveShellChecksClient(t *testing.T) {
+	pool := NewShellPool(1, time.Minute)
+	pool.shells["s1"] = &Shell{ID: "s1", ClientID: "claude_code", State: ShellStateReady}
+
+	if _, err := pool.ReserveShell("personal_agent", "s1"); err == nil || errors.Is(err, errNoFreeShell) {
+		t.Errorf("another client's reservation answered %v, want not found", err)
+	}
+	if _, err := pool.ReserveShell("claude_code", "s1"); err != nil {
+		t.Fatalf("owner's reservation failed: %v", err)
+	}
+	if _, err := pool.ReserveShell("claude_code", "s1"); !errors.Is(err, errNoFreeShell) {
+		t.Errorf("second reservation answered %v, want errNoFreeShell", err)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// contractFile holds the per-client command policy. Without it every command is refused.
+const contractFile = "./contract.yaml"
+
+type Contract struct {
+	Clients map[string]ClientPermissions `yaml:"clients"`
+}
+
+// ClientPermissions is one client's policy. Each rule is an executable followed by argument globs
+// in path.Match syntax, e.g. "git log *", where * also matches "/" since arguments are often paths.
+// Arguments match one for one, except that an argument pattern of just "*" matches any number of
+// arguments, none included: "git log *" allows any git log, "go * -exec* *" names -exec anywhere
+// in a go command. A rule of just "*" allows every command. Deny rules win over allow rules and also
+// match the executable's base name, so /bin/rm cannot sidestep a rule for rm. Arguments checked
+// against a path-shaped pattern (one containing "/") are cleaned first, so
+// "/var/log/../../etc/shadow" does not pass for "/var/log/*".
+//
+// Env lists the exact variable names an orchestration step may set for its command. Any other
+// name is refused: too many variables (PATH, LD_PRELOAD, GIT_SSH_COMMAND, PAGER, GOFLAGS, ...)
+// make a permitted command run another program for a denylist to keep up with.
+type ClientPermissions struct {
+	Commands []string `yaml:"commands"`
+	Deny     []string `yaml:"deny"`
+	Env      []string `yaml:"env"`
+	TTY      bool     `yaml:"tty"`
+	Sessions bool     `yaml:"sessions"`
+}
+
+// shellMetacharacters are refused in commands typed into a tmux shell, where they would chain,
+// substitute, redirect or expand into commands the policy never saw; globs and ~ would expand into
+// paths the policy never saw. Direct mode runs the executable without a shell, so there they are
+// plain argument text.
+const shellMetacharacters = ";&|`$()<>{}!\\'\"\n\r*?[]~"
+
+func (sc *SystemCommander) loadContract() (*Contract, error) {
+	data, err := os.ReadFile(contractFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var contract Contract
+	if err := yaml.Unmarshal(data, &contract); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", contractFile, err)
+	}
+
+	// A rule that cannot match would silently weaken a deny list, so refuse the whole contract
+	for clientID, permissions := range contract.Clients {
+		for _, rule := range append(append([]string(nil), permissions.Commands...), permissions.Deny...) {
+			for _, pattern := range strings.Fields(rule) {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("%s: client %s: bad rule %q: %v", contractFile, clientID, rule, err)
+				}
+			}
+		}
+		for _, name := range permissions.Env {
+			if !envName.MatchString(name) {
+				return nil, fmt.Errorf("%s: client %s: invalid environment variable name %q", contractFile, clientID, name)
+			}
+		}
+	}
+	return &contract, nil
+}
+
+// isCommandAuthorized checks command against the client's contract for the mode it will run in
+// ("direct" or "tmux") and logs the decision. The reason says which rule decided, or why the
+// command was refused.
+func (sc *SystemCommander) isCommandAuthorized(clientID, command, mode string) (bool, string) {
+	allowed, reason := sc.checkPolicy(clientID, command, mode)
+
+	decision := "DENY"
+	if allowed {
+		decision = "ALLOW"
+	}
+	log.Printf("Policy %s client=%s mode=%s command=%q: %s", decision, clientID, mode, command, reason)
+	return allowed, reason
+}
+
+func (sc *SystemCommander) checkPolicy(clientID, command, mode string) (bool, string) {
+	contract, err := sc.loadContract()
+	if err != nil {
+		return false, fmt.Sprintf("no usable contract: %v", err)
+	}
+
+	permissions, exists := contract.Clients[clientID]
+	if !exists {
+		return false, fmt.Sprintf("no contract for client %s", clientID)
+	}
+
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false, "empty command"
+	}
+
+	if mode == "tmux" {
+		if !permissions.TTY {
+			return false, fmt.Sprintf("client %s may not use tmux shells", clientID)
+		}
+		if i := strings.IndexAny(command, shellMetacharacters); i >= 0 {
+			return false, fmt.Sprintf("shell metacharacter %q is not allowed in tmux mode", command[i])
+		}
+		// VAR=value cmd would run cmd while the policy checked "VAR=value"
+		if strings.Contains(fields[0], "=") {
+			return false, "environment assignments are not allowed in tmux mode"
+		}
+	}
+
+	for _, rule := range permissions.Deny {
+		if matchRule(rule, fields, true) {
+			return false, fmt.Sprintf("denied by rule %q", rule)
+		}
+	}
+	for _, rule := range permissions.Commands {
+		if matchRule(rule, fields, false) {
+			return true, fmt.Sprintf("allowed by rule %q", rule)
+		}
+	}
+	return false, "no allow rule matches"
+}
+
+// matchRule reports whether the command's fields match rule. Deny rules match more loosely: the
+// executable may also match by its base name and arguments also match as written.
+func matchRule(rule string, fields []string, deny bool) bool {
+	patterns := strings.Fields(rule)
+	if len(patterns) == 0 {
+		return false
+	}
+	if len(patterns) == 1 && patterns[0] == "*" {
+		return true
+	}
+
+	executable := fields[0]
+	if !argMatch(patterns[0], executable, deny) && !(deny && globMatch(patterns[0], filepath.Base(executable))) {
+		return false
+	}
+
+	return matchArgs(patterns[1:], fields[1:], deny)
+}
+
+// matchArgs matches arguments against argument patterns, where "*" spans any number of arguments
+func matchArgs(patterns, args []string, deny bool) bool {
+	if len(patterns) == 0 {
+		return len(args) == 0
+	}
+	if patterns[0] == "*" {
+		for i := 0; i <= len(args); i++ {
+			if matchArgs(patterns[1:], args[i:], deny) {
+				return true
+			}
+		}
+		return false
+	}
+	return len(args) > 0 && argMatch(patterns[0], args[0], deny) && matchArgs(patterns[1:], args[1:], deny)
+}
+
+// argMatch matches one field against a pattern. A path-shaped pattern is compared with the cleaned
+// field, since "/var/log/../../etc/shadow" names /etc/shadow; loose also accepts the field as written,
+// so a deny rule catches either form.
+func argMatch(pattern, field string, loose bool) bool {
+	if !strings.Contains(pattern, "/") {
+		return globMatch(pattern, field)
+	}
+	return globMatch(pattern, path.Clean(field)) || (loose && globMatch(pattern, field))
+}
+
+// globMatch is path.Match with "/" treated as an ordinary character
+func globMatch(pattern, s string) bool {
+	const slash = "\x00"
+	matched, err := path.Match(strings.ReplaceAll(pattern, "/", slash), strings.ReplaceAll(s, "/", slash))
+	return err == nil && matched
+}
+
+// envName is a variable name the shell accepts in an assignment
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// isEnvAuthorized checks the variables an orchestration step sets for its command against the
+// client's env list. Values are quoted when the command is typed, so only names and line breaks
+// need checking.
+func (sc *SystemCommander) isEnvAuthorized(clientID string, env map[string]string) (bool, string) {
+	if len(env) == 0 {
+		return true, ""
+	}
+	allowed, reason := sc.checkEnv(clientID, env)
+	if !allowed {
+		log.Printf("Policy DENY client=%s env: %s", clientID, reason)
+	}
+	return allowed, reason
+}
+
+func (sc *SystemCommander) checkEnv(clientID string, env map[string]string) (bool, string) {
+	contract, err := sc.loadContract()
+	if err != nil {
+		return false, fmt.Sprintf("no usable contract: %v", err)
+	}
+	permissions, exists := contract.Clients[clientID]
+	if !exists {
+		return false, fmt.Sprintf("no contract for client %s", clientID)
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names) // report the same variable on every attempt
+
+	for _, name := range names {
+		switch {
+		case !envName.MatchString(name):
+			return false, fmt.Sprintf("invalid environment variable name %q", name)
+		case !slices.Contains(permissions.Env, name):
+			return false, fmt.Sprintf("environment variable %s is not in client %s's env list", name, clientID)
+		case strings.ContainsAny(env[name], "\n\r"):
+			return false, fmt.Sprintf("environment variable %s contains a line break", name)
+		}
+	}
+	return true, ""
+}
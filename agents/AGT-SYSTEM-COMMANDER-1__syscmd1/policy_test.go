@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		command string
+		deny    bool
+		want    bool
+	}{
+		{"git status", "git status", false, true},
+		{"git status", "git status -s", false, false},
+		{"git log *", "git log", false, true},
+		{"git log *", "git log --oneline -5", false, true},
+		{"git log *", "git show HEAD", false, false},
+		{"*", "anything at all", false, true},
+		{"date", "date", false, true},
+
+		// Deny rules also match the executable's base name
+		{"rm *", "/bin/rm -rf /", true, true},
+		{"rm *", "/bin/rm -rf /", false, false},
+		{"mkfs* *", "/sbin/mkfs.ext4 /dev/sda1", true, true},
+		{"rm *", "/usr/bin/rmdir x", true, false},
+
+		// A "*" argument spans any number of arguments
+		{"go * -exec* *", "go test -exec=/tmp/run ./...", true, true},
+		{"go * -exec* *", "go test ./... -count=1 -exec /tmp/run", true, true},
+		{"go * -exec* *", "go test ./...", true, false},
+		{"git * --ou* *", "git log --output=/tmp/x", true, true},
+		{"git * --ou* *", "git log --oneline", true, false},
+
+		// Path-shaped arguments are cleaned; deny rules also match them as written
+		{"cat /var/log/*", "cat /var/log/syslog", false, true},
+		{"cat /var/log/*", "cat /var/log/../../etc/shadow", false, false},
+		{"cat /etc/*", "cat /var/log/../../etc/shadow", true, true},
+		{"cat /etc/*", "cat /etc/../home/user/notes", true, true},
+	}
+	for _, tt := range tests {
+		if got := matchRule(tt.rule, strings.Fields(tt.command), tt.deny); got != tt.want {
+			t.Errorf("matchRule(%q, %q, deny=%t) = %t, want %t", tt.rule, tt.command, tt.deny, got, tt.want)
+		}
+	}
+}
+
+func TestArgMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		field   string
+		loose   bool
+		want    bool
+	}{
+		{"*", "a/b/c", false, true},
+		{"-exec*", "-exec=/tmp/run", false, true},
+		{"-exec*", "--exec", false, false},
+		{"/var/log/*", "/var/log/nginx/access.log", false, true},
+		{"/var/log/*", "/var/log/../../etc/shadow", false, false},
+		{"/var/log/*", "/var/log/../../etc/shadow", true, true},
+		{"/etc/*", "/var/../etc/passwd", false, true},
+		{"/etc/*", "/etc/../var/x", false, false},
+		{"/etc/*", "/etc/../var/x", true, true},
+	}
+	for _, tt := range tests {
+		if got := argMatch(tt.pattern, tt.field, tt.loose); got != tt.want {
+			t.Errorf("argMatch(%q, %q, loose=%t) = %t, want %t", tt.pattern, tt.field, tt.loose, got, tt.want)
+		}
+	}
+}
+
+// The shipped contract.yaml refuses commands that would run or write more than the rule allows
+func TestCheckPolicy(t *testing.T) {
+	sc := &SystemCommander{}
+	tests := []struct {
+		client  string
+		command string
+		mode    string
+		want    bool
+	}{
+		{"claude_code", "git status", "tmux", true},
+		{"claude_code", "go test ./...", "tmux", true},
+		{"claude_code", "git log --oneline -5", "tmux", true},
+		{"claude_code", "echo a;b", "direct", true},
+		{"unknown_client", "ls", "direct", false},
+		{"personal_agent", "ls", "tmux", false},
+		{"claude_code", "", "direct", false},
+
+		// Shell metacharacters and globs in tmux shells
+		{"claude_code", "ls; rm -rf /", "tmux", false},
+		{"claude_code", "ls | sh", "tmux", false},
+		{"claude_code", "echo $(whoami)", "tmux", false},
+		{"claude_code", "echo `id`", "tmux", false},
+		{"claude_code", "cat secrets > /tmp/x", "tmux", false},
+		{"claude_code", "ls *", "tmux", false},
+		{"claude_code", "cat ~/.ssh/id_rsa", "tmux", false},
+
+		// A leading assignment would run the command with it
+		{"claude_code", "GIT_PAGER=sh git log", "tmux", false},
+		{"claude_code", "PATH=/tmp git status", "tmux", false},
+
+		// Flags that run other programs or write files
+		{"claude_code", "go test -exec /tmp/run ./...", "tmux", false},
+		{"claude_code", "go test --exec=/tmp/run ./...", "direct", false},
+		{"claude_code", "go build -toolexec=/tmp/run .", "tmux", false},
+		{"claude_code", "go vet -vettool=/tmp/run ./...", "tmux", false},
+		{"claude_code", "git fetch --upload-pack=/tmp/run origin", "tmux", false},
+		{"claude_code", "git pull --upl=/tmp/run origin main", "tmux", false},
+		{"claude_code", "git log --output=/tmp/x", "tmux", false},
+		{"claude_code", "git diff --ou /tmp/x", "tmux", false},
+		{"claude_code", "/bin/rm x", "direct", false},
+		{"claude_code", "git -c core.pager=sh log", "tmux", false},
+	}
+	for _, tt := range tests {
+		if got, reason := sc.checkPolicy(tt.client, tt.command, tt.mode); got != tt.want {
+			t.Errorf("checkPolicy(%s, %q, %s) = %t (%s), want %t", tt.client, tt.command, tt.mode, got, reason, tt.want)
+		}
+	}
+}
+
+// Only variables on the client's env list may be set, so nothing outside it can swap the program
+// a permitted command runs
+func TestIsEnvAuthorized(t *testing.T) {
+	sc := &SystemCommander{}
+	for _, name := range []string{
+		"PATH", "LD_PRELOAD", "BASH_ENV",
+		"GIT_SSH_COMMAND", "GIT_EXTERNAL_DIFF", "GIT_PAGER", "PAGER",
+		"GIT_CONFIG_COUNT", "GIT_CONFIG_KEY_0", "GIT_CONFIG_VALUE_0", "GIT_CONFIG_PARAMETERS",
+		"GOFLAGS",
+	} {
+		if allowed, _ := sc.isEnvAuthorized("claude_code", map[string]string{name: "/tmp/run"}); allowed {
+			t.Errorf("%s may be set", name)
+		}
+	}
+
+	tests := []struct {
+		client string
+		env    map[string]string
+		want   bool
+	}{
+		{"claude_code", nil, true},
+		{"claude_code", map[string]string{"GOOS": "linux", "GOARCH": "arm64"}, true},
+		{"claude_code", map[string]string{"GOOS": "linux", "GOFLAGS": "-toolexec=/tmp/run"}, false},
+		{"claude_code", map[string]string{"TZ": "UTC\nrm -rf /"}, false},
+		{"claude_code", map[string]string{"GOOS;id": "linux"}, false},
+		{"personal_agent", map[string]string{"GOOS": "linux"}, false},
+		{"unknown_client", map[string]string{"GOOS": "linux"}, false},
+	}
+	for _, tt := range tests {
+		if got, reason := sc.isEnvAuthorized(tt.client, tt.env); got != tt.want {
+			t.Errorf("isEnvAuthorized(%s, %v) = %t (%s), want %t", tt.client, tt.env, got, reason, tt.want)
+		}
+	}
+}
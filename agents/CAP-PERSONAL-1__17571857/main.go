@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/go-redis/redis/v8"
 	"gopkg.in/yaml.v2"
 )
@@ -29,6 +30,7 @@ type PersonalAgent struct {
 	sessionID     string
 	sessionStart  time.Time
 	ciContext     string  // CI agent manifest context
+	ollamaURL     string
 	conversationHistory []ConversationTurn
 	mutex         sync.RWMutex
 }
@@ -133,8 +135,8 @@ func generateULID8() string {
 }
 
 // NewPersonalAgent creates a new configurable personal agent
-func NewPersonalAgent(configPath string) (*PersonalAgent, error) {
-	config := &AgentConfig{}
+func NewPersonalAgent(configPath string, endpoints *config.Config) (*PersonalAgent, error) {
+	agentConfig := &AgentConfig{}
 	
 	// Load configuration
 	configFile, err := os.ReadFile(configPath)
@@ -142,13 +144,20 @@ func NewPersonalAgent(configPath string) (*PersonalAgent, error) {
 		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
 	
-	if err := yaml.Unmarshal(configFile, config); err != nil {
+	if err := yaml.Unmarshal(configFile, agentConfig); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %v", err)
 	}
 	
+	// The integrations section plays the part of agent.yaml: it overrides the defaults and
+	// deployment.env, while environment variables and flags override it
+	agentConfig.Integrations.RedisEndpoint = integrationEndpoint(endpoints, config.RedisAddr,
+		agentConfig.Integrations.RedisEndpoint)
+	agentConfig.Integrations.WeaviateEndpoint = integrationEndpoint(endpoints, config.WeaviateURL,
+		agentConfig.Integrations.WeaviateEndpoint)
+
 	// Create Redis client
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     config.Integrations.RedisEndpoint,
+		Addr:     agentConfig.Integrations.RedisEndpoint,
 		Password: "",
 		DB:       0,
 	})
@@ -165,12 +174,13 @@ func NewPersonalAgent(configPath string) (*PersonalAgent, error) {
 	sessionID := fmt.Sprintf("CAP-PERSONAL-1:%s", generateULID8())
 	
 	agent := &PersonalAgent{
-		Config:       config,
+		Config:       agentConfig,
 		RedisClient:  redisClient,
 		httpClient:   &http.Client{Timeout: 30 * time.Second},
 		ctx:          context.Background(),
 		sessionID:    sessionID,
 		sessionStart: sessionStart,
+		ollamaURL:    endpoints.Get(config.OllamaURL),
 		Memory:       &ConversationMemory{},
 	}
 	
@@ -192,6 +202,19 @@ func NewPersonalAgent(configPath string) (*PersonalAgent, error) {
 	return agent, nil
 }
 
+// integrationEndpoint resolves s, with the value from config.yaml taking precedence over the
+// default and deployment.env
+func integrationEndpoint(endpoints *config.Config, s config.Setting, fromYAML string) string {
+	switch endpoints.Source(s) {
+	case config.SourceEnv, config.SourceFlag:
+		return endpoints.Get(s)
+	}
+	if fromYAML != "" {
+		return fromYAML
+	}
+	return endpoints.Get(s)
+}
+
 // ProcessUserInput - Main entry point for user requests
 func (pa *PersonalAgent) ProcessUserInput(input string) (string, error) {
 	pa.mutex.Lock()
@@ -566,7 +589,7 @@ User: %s`,
 	}
 	
 	reqBody, _ := json.Marshal(requestData)
-	resp, err := http.Post("http://localhost:11434/api/generate", "application/json", bytes.NewBuffer(reqBody))
+	resp, err := http.Post(to.agent.ollamaURL+"/api/generate", "application/json", bytes.NewBuffer(reqBody))
 	if err != nil {
 		return "", err
 	}
@@ -771,16 +794,24 @@ func (pa *PersonalAgent) AddTurnWithStreaming(user, assistant string) {
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: ./apollo <config-path>")
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+	if len(endpoints.Args()) < 1 {
+		log.Fatal("Usage: ./apollo [flags] <config-path>")
 	}
 	
-	configPath := os.Args[1]
+	configPath := endpoints.Args()[0]
 	
 	log.Printf("🚀 Starting Personal AI Agent...")
 	log.Printf("📁 Loading config from: %s", configPath)
 	
-	agent, err := NewPersonalAgent(configPath)
+	agent, err := NewPersonalAgent(configPath, endpoints)
 	if err != nil {
 		log.Fatalf("Failed to create agent: %v", err)
 	}
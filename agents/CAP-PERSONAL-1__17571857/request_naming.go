@@ -5,15 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/go-redis/redis/v8"
 )
 
-func requestSemanticNameAllocation() error {
+func requestSemanticNameAllocation(redisAddr string) error {
 	// Connect to Redis
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     redisAddr,
 		Password: "",
 		DB:       0,
 	})
@@ -106,10 +108,19 @@ func requestSemanticNameAllocation() error {
 }
 
 func main() {
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		log.Fatalf("❌ Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
 	fmt.Println("🤖 Requesting semantic name allocation from AGT-NAMING-1...")
 	fmt.Println("═══════════════════════════════════════════════════════════")
 	
-	if err := requestSemanticNameAllocation(); err != nil {
+	if err := requestSemanticNameAllocation(endpoints.Get(config.RedisAddr)); err != nil {
 		log.Fatalf("❌ Request failed: %v", err)
 	}
 }
\ No newline at end of file
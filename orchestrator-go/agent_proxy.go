@@ -6,6 +6,8 @@ import (
 	"net"
 	"sync"
 	"time"
+
+	"centerfire/shared/config"
 )
 
 // AgentProxy manages connections to socket-based agents and forwards requests
@@ -14,6 +16,7 @@ type AgentProxy struct {
 	socketConnections map[string]*net.Conn
 	connectionTimeout time.Duration
 	requestTimeout    time.Duration
+	endpoints         *config.Config
 }
 
 // AgentResponse represents a response from an agent
@@ -43,11 +46,12 @@ type AgentStatus struct {
 }
 
 // NewAgentProxy creates a new agent proxy
-func NewAgentProxy() *AgentProxy {
+func NewAgentProxy(endpoints *config.Config) *AgentProxy {
 	return &AgentProxy{
 		socketConnections: make(map[string]*net.Conn),
 		connectionTimeout: 10 * time.Second,
 		requestTimeout:    30 * time.Second,
+		endpoints:         endpoints,
 	}
 }
 
@@ -127,7 +131,7 @@ func (ap *AgentProxy) getSocketConnection(agent string) (*net.Conn, error) {
 	}
 	
 	// Create new connection
-	socketPath := fmt.Sprintf("/tmp/orchestrator-%s.sock", agent)
+	socketPath := ap.endpoints.SocketPath("orchestrator-" + agent)
 	conn, err := net.DialTimeout("unix", socketPath, ap.connectionTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to socket %s: %v", socketPath, err)
@@ -190,7 +194,7 @@ func (ap *AgentProxy) removeConnection(agent string) {
 // HealthCheckAgent performs a health check on an agent
 func (ap *AgentProxy) HealthCheckAgent(agent string) *AgentStatus {
 	startTime := time.Now()
-	socketPath := fmt.Sprintf("/tmp/orchestrator-%s.sock", agent)
+	socketPath := ap.endpoints.SocketPath("orchestrator-" + agent)
 	
 	status := &AgentStatus{
 		Name:       agent,
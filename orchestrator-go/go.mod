@@ -3,6 +3,9 @@ module orchestrator
 go 1.25.1
 
 require (
+	centerfire/shared/config v0.0.0
 	github.com/gorilla/websocket v1.5.3
 	gopkg.in/yaml.v2 v2.4.0
 )
+
+replace centerfire/shared/config => ../shared/config
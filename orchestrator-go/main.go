@@ -17,6 +17,7 @@ import (
 	"syscall"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/gorilla/websocket"
 )
 
@@ -76,6 +77,7 @@ type Orchestrator struct {
 	httpServer   *http.Server
 	wsUpgrader   websocket.Upgrader
 	llmRouter    *LLMRouter
+	endpoints    *config.Config
 	ctx          context.Context
 	cancel       context.CancelFunc
 }
@@ -99,7 +101,16 @@ type Response struct {
 }
 
 func main() {
-	orchestrator := NewOrchestrator()
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	orchestrator := NewOrchestrator(endpoints)
 	
 	// Start orchestrator services
 	if err := orchestrator.Start(); err != nil {
@@ -111,7 +122,7 @@ func main() {
 }
 
 // NewOrchestrator creates a new orchestrator instance
-func NewOrchestrator() *Orchestrator {
+func NewOrchestrator(endpoints *config.Config) *Orchestrator {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	return &Orchestrator{
@@ -124,11 +135,17 @@ func NewOrchestrator() *Orchestrator {
 			},
 		},
 		llmRouter: newLLMRouter(),
+		endpoints: endpoints,
 		ctx:       ctx,
 		cancel:    cancel,
 	}
 }
 
+// socketPath is where agentName connects, e.g. /tmp/orchestrator-naming.sock
+func (o *Orchestrator) socketPath(agentName string) string {
+	return o.endpoints.SocketPath("orchestrator-" + agentName)
+}
+
 // Start initializes all orchestrator services
 func (o *Orchestrator) Start() error {
 	log.Println("🚀 Starting Socket-Based Multi-Interface Orchestrator")
@@ -143,8 +160,8 @@ func (o *Orchestrator) Start() error {
 	go o.startLLMRouter()
 	
 	log.Println("✅ Orchestrator started successfully")
-	log.Println("📡 HTTP Server: http://localhost:8090")
-	log.Println("🔌 Agent Sockets: /tmp/orchestrator-*.sock")
+	log.Printf("📡 HTTP Server: http://localhost:%s", o.endpoints.Get(config.GatewayPort))
+	log.Printf("🔌 Agent Sockets: %s", o.socketPath("*"))
 	
 	return nil
 }
@@ -155,7 +172,7 @@ func (o *Orchestrator) startAgentSocketListeners() {
 	
 	for _, agent := range agents {
 		go func(agentName string) {
-			socketPath := fmt.Sprintf("/tmp/orchestrator-%s.sock", agentName)
+			socketPath := o.socketPath(agentName)
 			
 			// Remove existing socket file
 			os.Remove(socketPath)
@@ -217,11 +234,11 @@ func (o *Orchestrator) startHTTPServer() {
 	mux.Handle("/", http.FileServer(http.Dir("./web/")))
 	
 	o.httpServer = &http.Server{
-		Addr:    ":8090",
+		Addr:    ":" + o.endpoints.Get(config.GatewayPort),
 		Handler: mux,
 	}
 	
-	log.Println("🌐 Starting HTTP/WebSocket server on :8090")
+	log.Printf("🌐 Starting HTTP/WebSocket server on %s", o.httpServer.Addr)
 	if err := o.httpServer.ListenAndServe(); err != http.ErrServerClosed {
 		log.Printf("❌ HTTP server error: %v", err)
 	}
@@ -709,7 +726,7 @@ func (o *Orchestrator) WaitForShutdown() {
 	// Clean up socket files
 	agents := []string{"naming", "struct", "semantic", "manager"}
 	for _, agent := range agents {
-		os.Remove(fmt.Sprintf("/tmp/orchestrator-%s.sock", agent))
+		os.Remove(o.socketPath(agent))
 	}
 	
 	log.Println("✅ Orchestrator shutdown complete")
@@ -5,12 +5,20 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"os"
 	"time"
+
+	"centerfire/shared/config"
 )
 
 func main() {
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
 	// Connect to the naming agent socket
-	conn, err := net.Dial("unix", "/tmp/orchestrator-naming.sock")
+	conn, err := net.Dial("unix", endpoints.SocketPath("orchestrator-naming"))
 	if err != nil {
 		log.Fatalf("Failed to connect to orchestrator socket: %v", err)
 	}
@@ -0,0 +1,358 @@
+// Package agent is the runtime shared by Centerfire agents: it loads agent.yaml, writes the PID and
+// health files, registers with AGT-MANAGER-1 and heartbeats, dispatches requests arriving on the
+// configured transports (Redis request channel, Unix socket) to registered handlers, keeps
+// per-action metrics, and shuts down cleanly on SIGINT/SIGTERM.
+//
+// A minimal agent is:
+//
+//	cfg, _ := agent.LoadConfigArgs(os.Args[1:], "agent.yaml")
+//	a := agent.New(cfg)
+//	a.Handle("echo", func(req *agent.Request) (map[string]interface{}, error) {
+//		return req.ParamsMap(), nil
+//	})
+//	log.Fatal(a.Run())
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"centerfire/shared/config"
+	"centerfire/shared/messages"
+	"github.com/redis/go-redis/v9"
+)
+
+// HeartbeatInterval matches the cadence AGT-MANAGER-1's heartbeat monitor expects
+const HeartbeatInterval = 25 * time.Second
+
+// Request is a decoded request handed to a handler
+type Request struct {
+	*messages.Envelope
+	Context context.Context
+}
+
+// HandlerFunc handles one action. A returned error becomes {"error": ...} in the response.
+type HandlerFunc func(req *Request) (map[string]interface{}, error)
+
+// MapHandler adapts handlers written against the map request format
+// ({"action", "params", "request_id", ...}) that predates typed envelopes
+func MapHandler(handler func(request map[string]interface{}) map[string]interface{}) HandlerFunc {
+	return func(req *Request) (map[string]interface{}, error) {
+		return handler(req.AsMap()), nil
+	}
+}
+
+// Agent is a running agent process
+type Agent struct {
+	Config *Config
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	redis   *redis.Client
+	started time.Time
+	metrics *metrics
+
+	mu         sync.RWMutex
+	handlers   map[string]HandlerFunc
+	transports []Transport
+	onStart    []func(ctx context.Context) error
+	onStop     []func()
+
+	control   *redis.PubSub // this process's control channel; nil without Redis
+	servingMu sync.Mutex
+	serving   bool // transports are open and taking requests
+}
+
+// New creates an agent for cfg with the transports its config declares: the Redis request
+// channel when redis_channels is set, and the Unix socket when unix_socket is set. Handlers and
+// hooks are registered before Run.
+func New(cfg *Config) *Agent {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Agent{
+		Config:   cfg,
+		ctx:      ctx,
+		cancel:   cancel,
+		metrics:  newMetrics(),
+		handlers: make(map[string]HandlerFunc),
+	}
+	if cfg.RedisAddr == "" {
+		cfg.RedisAddr = cfg.Endpoint(config.RedisAddr)
+	}
+	if cfg.UsesRedis() {
+		a.redis = redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+		a.transports = append(a.transports, &RedisTransport{
+			Client:          a.redis,
+			RequestChannel:  cfg.RequestChannel(),
+			ResponseChannel: cfg.ResponseChannel(),
+		})
+	}
+	if cfg.Communication.UnixSocket != "" {
+		a.transports = append(a.transports, &UnixSocketTransport{Path: cfg.Communication.UnixSocket})
+	}
+
+	a.Handle("health", a.handleHealth)
+	a.Handle("metrics", a.handleMetrics)
+	return a
+}
+
+// Handle registers the handler for action, replacing any previous one
+func (a *Agent) Handle(action string, handler HandlerFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.handlers[action] = handler
+}
+
+// AddTransport serves requests on t in addition to the configured transports
+func (a *Agent) AddTransport(t Transport) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.transports = append(a.transports, t)
+}
+
+// OnStart runs fn after Redis is reachable and before transports accept requests. An error
+// aborts startup.
+func (a *Agent) OnStart(fn func(ctx context.Context) error) {
+	a.onStart = append(a.onStart, fn)
+}
+
+// OnStop runs fn during shutdown, after transports stop and before Redis is closed
+func (a *Agent) OnStop(fn func()) {
+	a.onStop = append(a.onStop, fn)
+}
+
+// Actions lists the registered actions
+func (a *Agent) Actions() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	actions := make([]string, 0, len(a.handlers))
+	for action := range a.handlers {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// Context is cancelled when the agent shuts down
+func (a *Agent) Context() context.Context {
+	return a.ctx
+}
+
+// Redis is the agent's Redis client; nil when the config declares no Redis channels
+func (a *Agent) Redis() *redis.Client {
+	return a.redis
+}
+
+// Run starts the agent and blocks until SIGINT/SIGTERM or Stop. With --print-config it prints the
+// effective configuration instead and returns.
+func (a *Agent) Run() error {
+	if a.Config.Endpoints != nil && a.Config.Endpoints.PrintRequested() {
+		a.Config.Endpoints.Print(os.Stdout)
+		return nil
+	}
+
+	a.started = time.Now()
+	if err := a.writePIDFile(); err != nil {
+		return fmt.Errorf("failed to write PID file: %v", err)
+	}
+	a.updateHealthFile("starting")
+
+	if err := a.start(); err != nil {
+		a.cleanup()
+		return err
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	select {
+	case sig := <-sigChan:
+		log.Printf("%s: received %s, shutting down", a.Config.AgentID, sig)
+	case <-a.ctx.Done():
+		log.Printf("%s: stopped, shutting down", a.Config.AgentID)
+	}
+
+	a.cleanup()
+	return nil
+}
+
+// start connects, runs start hooks and opens the transports. A standby instance leaves them closed
+// until the manager resumes it.
+func (a *Agent) start() error {
+	if a.redis != nil {
+		if err := a.redis.Ping(a.ctx).Err(); err != nil {
+			return fmt.Errorf("failed to connect to Redis at %s: %v", a.Config.RedisAddr, err)
+		}
+	}
+
+	for _, fn := range a.onStart {
+		if err := fn(a.ctx); err != nil {
+			return err
+		}
+	}
+
+	if a.redis != nil {
+		if err := a.startControl(); err != nil {
+			return err
+		}
+	}
+	if a.Config.Standby && a.redis != nil {
+		log.Printf("%s: standing by until the manager resumes request transports", a.Config.AgentID)
+	} else if err := a.startTransports(); err != nil {
+		return err
+	}
+
+	if a.redis != nil {
+		a.registerWithManager()
+		go a.startHeartbeat()
+	}
+	go a.startHealthReporter()
+
+	a.updateHealthFile("healthy")
+	log.Printf("%s: started (PID %d)", a.Config.AgentID, os.Getpid())
+	return nil
+}
+
+// Stop makes Run return as if the process had been signalled
+func (a *Agent) Stop() {
+	a.cancel()
+}
+
+// dispatchPayload is the Dispatcher handed to transports
+func (a *Agent) dispatchPayload(payload []byte) (*messages.Envelope, map[string]interface{}) {
+	env, err := messages.Decode(payload)
+	if err != nil {
+		log.Printf("%s: rejected request: %v", a.Config.AgentID, err)
+		return nil, map[string]interface{}{
+			"success":        false,
+			"error":          err.Error(),
+			"agent":          a.Config.AgentID,
+			"schema_version": messages.SchemaVersion,
+		}
+	}
+	return env, a.Dispatch(env)
+}
+
+// Dispatch runs the handler for env's action and returns the response, stamped with the agent,
+// request_id, schema_version and, unless the handler set it, success
+func (a *Agent) Dispatch(env *messages.Envelope) map[string]interface{} {
+	started := time.Now()
+	response := a.dispatch(env)
+	_, failed := response["error"]
+	a.metrics.observe(a.metricsLabel(env.Action), time.Since(started), failed)
+	if _, hasSuccess := response["success"]; !hasSuccess {
+		response["success"] = !failed
+	}
+
+	response["agent"] = a.Config.AgentID
+	if env.RequestID != "" {
+		response["request_id"] = env.RequestID
+	}
+	response["schema_version"] = messages.SchemaVersion
+	return response
+}
+
+// metricsLabel keeps unregistered action names from growing the metrics map without bound
+func (a *Agent) metricsLabel(action string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if _, ok := a.handlers[action]; ok {
+		return action
+	}
+	return "unknown"
+}
+
+func (a *Agent) dispatch(env *messages.Envelope) (response map[string]interface{}) {
+	a.mu.RLock()
+	handler, ok := a.handlers[env.Action]
+	a.mu.RUnlock()
+	if !ok {
+		return map[string]interface{}{"error": fmt.Sprintf("Unknown action: %s", env.Action)}
+	}
+
+	if err := env.Validate(); err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	// A panicking handler fails its request, not the agent
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("%s: %s handler panicked: %v", a.Config.AgentID, env.Action, r)
+			response = map[string]interface{}{"error": fmt.Sprintf("internal error handling %s", env.Action)}
+		}
+	}()
+
+	result, err := handler(&Request{Envelope: env, Context: a.ctx})
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	if result == nil {
+		result = map[string]interface{}{}
+	}
+	return result
+}
+
+// handleHealth is the built-in health action
+func (a *Agent) handleHealth(req *Request) (map[string]interface{}, error) {
+	_, requests := a.metrics.snapshot()
+
+	a.mu.RLock()
+	transports := make([]string, 0, len(a.transports))
+	for _, t := range a.transports {
+		transports = append(transports, t.Name())
+	}
+	a.mu.RUnlock()
+
+	return map[string]interface{}{
+		"status":     "healthy",
+		"pid":        os.Getpid(),
+		"uptime":     time.Since(a.started).Round(time.Second).String(),
+		"actions":    a.Actions(),
+		"transports": transports,
+		"requests":   requests,
+		"timestamp":  time.Now().UTC(),
+	}, nil
+}
+
+// handleMetrics is the built-in metrics action: per-action request counts, errors and latency
+func (a *Agent) handleMetrics(req *Request) (map[string]interface{}, error) {
+	actions, requests := a.metrics.snapshot()
+	return map[string]interface{}{
+		"uptime_seconds": int64(time.Since(a.started).Seconds()),
+		"requests":       requests,
+		"actions":        actions,
+	}, nil
+}
+
+// cleanup stops transports, runs stop hooks, unregisters and removes the runtime files
+func (a *Agent) cleanup() {
+	a.updateHealthFile("shutting_down")
+
+	a.stopTransports()
+	if a.control != nil {
+		a.control.Close()
+	}
+
+	for _, fn := range a.onStop {
+		fn()
+	}
+
+	if a.redis != nil {
+		a.unregisterFromManager()
+	}
+	a.cancel()
+	if a.redis != nil {
+		a.redis.Close()
+	}
+
+	a.removePIDFile()
+	a.updateHealthFile("stopped")
+	log.Printf("%s: shutdown complete", a.Config.AgentID)
+}
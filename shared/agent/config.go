@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"centerfire/shared/config"
+	"gopkg.in/yaml.v2"
+)
+
+// ManagerChannel is where AGT-MANAGER-1 listens for registrations and heartbeats
+const ManagerChannel = "centerfire:agent:manager"
+
+// Config is the subset of agent.yaml the runtime understands. Agents with extra settings
+// unmarshal the same file into their own struct.
+type Config struct {
+	AgentID      string   `yaml:"agent_id"`
+	CID          string   `yaml:"cid"`
+	FriendlyName string   `yaml:"friendly_name"`
+	Namespace    string   `yaml:"namespace"`
+	Capabilities []string `yaml:"capabilities"`
+	RedisAddr    string   `yaml:"-"` // resolved by Endpoints; agent.yaml may set redis_addr
+
+	Communication struct {
+		RedisChannels []string `yaml:"redis_channels"`
+		UnixSocket    string   `yaml:"unix_socket"`
+	} `yaml:"communication"`
+
+	Monitoring struct {
+		RegisterWithMonitor bool   `yaml:"register_with_monitor"`
+		HealthCheckPath     string `yaml:"health_check_path"`
+	} `yaml:"monitoring"`
+
+	// Standby starts the agent with its transports closed; AGT-MANAGER-1 sets it for the
+	// replacement instance of a blue/green restart and resumes it on its control channel
+	Standby bool `yaml:"-"`
+
+	// Path is the agent.yaml the config was read from, for agents that read their own keys from it
+	Path string `yaml:"-"`
+	// Endpoints holds every layered setting, including the shared infrastructure endpoints
+	Endpoints *config.Config `yaml:"-"`
+}
+
+// Runtime settings that may also come from the environment or flags
+var (
+	unixSocketSetting = config.Setting{Key: "communication.unix_socket", Env: "AGENT_UNIX_SOCKET",
+		Flag: "unix-socket", Usage: "Unix socket to serve requests on"}
+	healthFileSetting = config.Setting{Key: "monitoring.health_check_path", Env: "AGENT_HEALTH_FILE",
+		Flag: "health-file", Usage: "health file path"}
+	standbySetting = config.Setting{Key: "standby", Env: "AGENT_STANDBY", Flag: "standby",
+		Usage: "start without serving requests until the manager resumes the agent", Default: "false"}
+)
+
+// LoadConfig reads agent.yaml, layering deployment.env and environment overrides over it
+func LoadConfig(path string, extra ...config.Setting) (*Config, error) {
+	return loadConfig(path, nil, extra)
+}
+
+// LoadConfigArgs is LoadConfig for main: args (usually os.Args[1:]) may override any setting with
+// a flag, request --print-config, and name the agent.yaml to use in place of defaultPath.
+// Flags come before the path.
+func LoadConfigArgs(args []string, defaultPath string, extra ...config.Setting) (*Config, error) {
+	loader := newLoader(extra)
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	loader.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	path := defaultPath
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	return loadConfig(path, loader, nil)
+}
+
+func newLoader(extra []config.Setting) *config.Loader {
+	settings := append([]config.Setting(nil), config.Standard...)
+	settings = append(settings, unixSocketSetting, healthFileSetting, standbySetting)
+	return config.NewLoader(append(settings, extra...)...)
+}
+
+func loadConfig(path string, loader *config.Loader, extra []config.Setting) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+	if cfg.AgentID == "" {
+		return nil, fmt.Errorf("%s: agent_id is required", path)
+	}
+
+	if loader == nil {
+		loader = newLoader(extra)
+	}
+	endpoints, err := loader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Path = path
+	cfg.Endpoints = endpoints
+	cfg.RedisAddr = endpoints.Get(config.RedisAddr)
+	cfg.Communication.UnixSocket = endpoints.Get(unixSocketSetting)
+	cfg.Monitoring.HealthCheckPath = endpoints.Get(healthFileSetting)
+	if cfg.Standby, err = strconv.ParseBool(endpoints.Get(standbySetting)); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", standbySetting.Env, err)
+	}
+	return &cfg, nil
+}
+
+// Endpoint returns the effective value of a shared or agent-specific setting
+func (c *Config) Endpoint(s config.Setting) string {
+	if c.Endpoints == nil {
+		return s.Default
+	}
+	return c.Endpoints.Get(s)
+}
+
+// UsesRedis reports whether the agent serves a Redis channel. Agents without one run on the Unix
+// socket and files alone, and skip manager registration.
+func (c *Config) UsesRedis() bool {
+	return len(c.Communication.RedisChannels) > 0
+}
+
+// RequestChannel is the configured *.request channel, or agent.<domain>.request
+func (c *Config) RequestChannel() string {
+	return c.channel(".request")
+}
+
+// ResponseChannel is the configured *.response channel, or agent.<domain>.response
+func (c *Config) ResponseChannel() string {
+	return c.channel(".response")
+}
+
+func (c *Config) channel(suffix string) string {
+	for _, ch := range c.Communication.RedisChannels {
+		if strings.HasSuffix(ch, suffix) {
+			return ch
+		}
+	}
+	return "agent." + strings.ToLower(Domain(c.AgentID)) + suffix
+}
+
+// HealthFile is the configured health file path, or /tmp/<agent_id>.health
+func (c *Config) HealthFile() string {
+	if c.Monitoring.HealthCheckPath != "" {
+		return c.Monitoring.HealthCheckPath
+	}
+	return fmt.Sprintf("/tmp/%s.health", c.AgentID)
+}
+
+// PIDFile is /tmp/<agent_id>.pid, where AGT-MANAGER-1 and the scripts look for it
+func (c *Config) PIDFile() string {
+	return fmt.Sprintf("/tmp/%s.pid", c.AgentID)
+}
+
+// Domain extracts the domain from an agent slug or directory: AGT-HTTP-GATEWAY-1__01K4EAF1 -> HTTP-GATEWAY
+func Domain(agentID string) string {
+	name := agentID
+	if idx := strings.LastIndex(name, "__"); idx > 0 {
+		name = name[:idx]
+	}
+	name = strings.TrimPrefix(name, "AGT-")
+	if idx := strings.LastIndex(name, "-"); idx > 0 && strings.Trim(name[idx+1:], "0123456789") == "" {
+		name = name[:idx]
+	}
+	return name
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Control commands let AGT-MANAGER-1 hand request delivery from one instance of an agent to the
+// next during a blue/green restart. Every process listens on its own control channel, named after
+// its PID, so the old and the new instance can be addressed separately while both are up.
+
+// Control commands
+const (
+	ControlPing   = "ping"   // reply with the PID and whether requests are being served
+	ControlPause  = "pause"  // stop taking requests; requests already being handled finish
+	ControlResume = "resume" // start taking requests
+)
+
+// ControlChannel is the channel the agent process with pid takes control commands on
+func ControlChannel(agentID string, pid int) string {
+	return fmt.Sprintf("centerfire:agent:control:%s:%d", agentID, pid)
+}
+
+// controlMessage is one command on the control channel; the reply goes to ReplyTo
+type controlMessage struct {
+	Command string `json:"command"`
+	ReplyTo string `json:"reply_to"`
+}
+
+// startControl subscribes to the process's control channel. Commands are handled one at a time
+// so a pause and the following resume cannot overtake each other.
+func (a *Agent) startControl() error {
+	channel := ControlChannel(a.Config.AgentID, os.Getpid())
+	pubsub := a.redis.Subscribe(a.ctx, channel)
+	if _, err := pubsub.Receive(a.ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe to %s: %v", channel, err)
+	}
+	a.control = pubsub
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			a.handleControl(msg)
+		}
+	}()
+	return nil
+}
+
+func (a *Agent) handleControl(msg *redis.Message) {
+	var command controlMessage
+	if err := json.Unmarshal([]byte(msg.Payload), &command); err != nil {
+		log.Printf("%s: rejected control message: %v", a.Config.AgentID, err)
+		return
+	}
+
+	var err error
+	switch command.Command {
+	case ControlPing:
+	case ControlPause:
+		log.Printf("%s: pausing request transports", a.Config.AgentID)
+		err = a.stopTransports()
+	case ControlResume:
+		log.Printf("%s: resuming request transports", a.Config.AgentID)
+		if err = a.startTransports(); err == nil {
+			// A replacement that was rolled back may have taken and removed the PID file
+			err = a.writePIDFile()
+		}
+	default:
+		err = fmt.Errorf("unknown control command %q", command.Command)
+	}
+
+	if command.ReplyTo == "" {
+		return
+	}
+	reply := map[string]interface{}{
+		"agent":   a.Config.AgentID,
+		"pid":     os.Getpid(),
+		"command": command.Command,
+		"serving": a.isServing(),
+	}
+	if err != nil {
+		reply["error"] = err.Error()
+	}
+	data, _ := json.Marshal(reply)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := a.redis.Publish(ctx, command.ReplyTo, data).Err(); err != nil {
+		log.Printf("%s: failed to answer %s control command: %v", a.Config.AgentID, command.Command, err)
+	}
+}
+
+// startTransports opens every transport; a no-op when they are already serving
+func (a *Agent) startTransports() error {
+	a.servingMu.Lock()
+	defer a.servingMu.Unlock()
+	if a.serving {
+		return nil
+	}
+
+	a.mu.RLock()
+	transports := append([]Transport(nil), a.transports...)
+	a.mu.RUnlock()
+	for i, t := range transports {
+		if err := t.Start(a.ctx, a.dispatchPayload); err != nil {
+			for _, started := range transports[:i] {
+				started.Close()
+			}
+			return fmt.Errorf("failed to start %s: %v", t.Name(), err)
+		}
+		log.Printf("%s: serving on %s", a.Config.AgentID, t.Name())
+	}
+	a.serving = true
+	return nil
+}
+
+// stopTransports closes every transport; a no-op when they are not serving
+func (a *Agent) stopTransports() error {
+	a.servingMu.Lock()
+	defer a.servingMu.Unlock()
+	if !a.serving {
+		return nil
+	}
+	a.serving = false
+
+	a.mu.RLock()
+	transports := append([]Transport(nil), a.transports...)
+	a.mu.RUnlock()
+	var firstErr error
+	for _, t := range transports {
+		if err := t.Close(); err != nil {
+			log.Printf("%s: failed to close %s: %v", a.Config.AgentID, t.Name(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (a *Agent) isServing() bool {
+	a.servingMu.Lock()
+	defer a.servingMu.Unlock()
+	return a.serving
+}
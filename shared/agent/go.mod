@@ -0,0 +1,20 @@
+module centerfire/shared/agent
+
+go 1.21
+
+require (
+	centerfire/shared/config v0.0.0
+	centerfire/shared/messages v0.0.0
+	github.com/redis/go-redis/v9 v9.0.5
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+)
+
+replace (
+	centerfire/shared/config => ../config
+	centerfire/shared/messages => ../messages
+)
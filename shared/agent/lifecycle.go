@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// registerWithManager announces the running process to AGT-MANAGER-1. The host qualifies the PID:
+// managers only check PIDs from their own host.
+func (a *Agent) registerWithManager() {
+	host, _ := os.Hostname()
+	a.publishToManager(a.ctx, "register_running", map[string]interface{}{
+		"host":         host,
+		"pid":          os.Getpid(),
+		"cid":          a.Config.CID,
+		"capabilities": a.Config.Capabilities,
+		"channel":      a.Config.RequestChannel(),
+		"health_file":  a.Config.HealthFile(),
+		"control":      ControlChannel(a.Config.AgentID, os.Getpid()),
+		"standby":      a.Config.Standby,
+		"started_at":   a.started.UTC().Format(time.RFC3339),
+	})
+}
+
+// unregisterFromManager runs during shutdown, after the agent context may be cancelled
+func (a *Agent) unregisterFromManager() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	a.publishToManager(ctx, "unregister_running", map[string]interface{}{
+		"pid": os.Getpid(),
+	})
+}
+
+// startHeartbeat sends periodic heartbeats to the manager until shutdown
+func (a *Agent) startHeartbeat() {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.publishToManager(a.ctx, "heartbeat", map[string]interface{}{
+				"pid": os.Getpid(),
+			})
+		}
+	}
+}
+
+// healthInterval is how often the health file is refreshed while running
+const healthInterval = 10 * time.Second
+
+// startHealthReporter keeps the health file's timestamp fresh so monitors can spot hung agents
+func (a *Agent) startHealthReporter() {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.updateHealthFile("healthy")
+		}
+	}
+}
+
+func (a *Agent) publishToManager(ctx context.Context, requestType string, sessionData map[string]interface{}) {
+	request := map[string]interface{}{
+		"request_type": requestType,
+		"agent_name":   a.Config.AgentID,
+		"session_data": sessionData,
+	}
+	data, _ := json.Marshal(request)
+	if err := a.redis.Publish(ctx, ManagerChannel, data).Err(); err != nil {
+		log.Printf("%s: failed to send %s to manager: %v", a.Config.AgentID, requestType, err)
+	}
+}
+
+func (a *Agent) writePIDFile() error {
+	return os.WriteFile(a.Config.PIDFile(), []byte(fmt.Sprintf("%d", os.Getpid())), 0644)
+}
+
+// removePIDFile removes the PID file only while it still names this process; during a rolling
+// restart the replacement has rewritten it by the time the old instance exits
+func (a *Agent) removePIDFile() {
+	data, err := os.ReadFile(a.Config.PIDFile())
+	if err != nil {
+		return
+	}
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid == os.Getpid() {
+		os.Remove(a.Config.PIDFile())
+	}
+}
+
+// updateHealthFile writes the file-based health status the monitor reads
+func (a *Agent) updateHealthFile(status string) {
+	_, requests := a.metrics.snapshot()
+	health := map[string]interface{}{
+		"status":    status,
+		"timestamp": time.Now().UTC(),
+		"pid":       os.Getpid(),
+		"agent":     a.Config.AgentID,
+		"namespace": a.Config.Namespace,
+		"requests":  requests,
+	}
+	data, _ := json.Marshal(health)
+	if err := os.WriteFile(a.Config.HealthFile(), data, 0644); err != nil {
+		log.Printf("%s: failed to write health file: %v", a.Config.AgentID, err)
+	}
+}
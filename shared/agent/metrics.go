@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// actionStats accumulates request counts and latency for one action
+type actionStats struct {
+	Requests int64
+	Errors   int64
+	total    time.Duration
+	max      time.Duration
+}
+
+// ActionMetrics is the reported view of one action's stats
+type ActionMetrics struct {
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+	AvgMillis float64 `json:"avg_ms"`
+	MaxMillis float64 `json:"max_ms"`
+}
+
+// metrics tracks per-action request stats for the built-in metrics action
+type metrics struct {
+	mu      sync.Mutex
+	actions map[string]*actionStats
+}
+
+func newMetrics() *metrics {
+	return &metrics{actions: make(map[string]*actionStats)}
+}
+
+func (m *metrics) observe(action string, elapsed time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.actions[action]
+	if !ok {
+		stats = &actionStats{}
+		m.actions[action] = stats
+	}
+	stats.Requests++
+	if failed {
+		stats.Errors++
+	}
+	stats.total += elapsed
+	if elapsed > stats.max {
+		stats.max = elapsed
+	}
+}
+
+// snapshot returns per-action metrics and the request total
+func (m *metrics) snapshot() (map[string]ActionMetrics, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]ActionMetrics, len(m.actions))
+	var total int64
+	for action, stats := range m.actions {
+		total += stats.Requests
+		out[action] = ActionMetrics{
+			Requests:  stats.Requests,
+			Errors:    stats.Errors,
+			AvgMillis: float64(stats.total) / float64(stats.Requests) / float64(time.Millisecond),
+			MaxMillis: float64(stats.max) / float64(time.Millisecond),
+		}
+	}
+	return out, total
+}
@@ -0,0 +1,207 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+
+	"centerfire/shared/messages"
+	"github.com/redis/go-redis/v9"
+)
+
+// Dispatcher handles one raw request. env is nil when the payload could not be decoded.
+type Dispatcher func(payload []byte) (env *messages.Envelope, response map[string]interface{})
+
+// Transport delivers requests to the agent and carries responses back
+type Transport interface {
+	// Name identifies the transport in logs and metrics, e.g. "redis:agent.naming.request"
+	Name() string
+	// Start binds the transport and serves requests in the background until ctx is cancelled
+	Start(ctx context.Context, dispatch Dispatcher) error
+	// Close stops accepting requests and releases the transport's resources
+	Close() error
+}
+
+// RedisTransport serves requests published on a Redis channel. Responses go to the request's
+// reply_to channel, or ResponseChannel.
+type RedisTransport struct {
+	Client          *redis.Client
+	RequestChannel  string
+	ResponseChannel string
+
+	pubsub *redis.PubSub
+}
+
+func (t *RedisTransport) Name() string {
+	return "redis:" + t.RequestChannel
+}
+
+func (t *RedisTransport) Start(ctx context.Context, dispatch Dispatcher) error {
+	pubsub := t.Client.Subscribe(ctx, t.RequestChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe to %s: %v", t.RequestChannel, err)
+	}
+	t.pubsub = pubsub
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			go t.serve(ctx, []byte(msg.Payload), dispatch)
+		}
+	}()
+	return nil
+}
+
+func (t *RedisTransport) serve(ctx context.Context, payload []byte, dispatch Dispatcher) {
+	env, response := dispatch(payload)
+
+	replyTo := t.ResponseChannel
+	if env != nil && env.ReplyTo != "" {
+		replyTo = env.ReplyTo
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("%s: failed to encode response: %v", t.Name(), err)
+		return
+	}
+	if err := t.Client.Publish(ctx, replyTo, data).Err(); err != nil {
+		log.Printf("%s: failed to publish response on %s: %v", t.Name(), replyTo, err)
+	}
+}
+
+func (t *RedisTransport) Close() error {
+	if t.pubsub == nil {
+		return nil
+	}
+	err := t.pubsub.Close()
+	t.pubsub = nil
+	return err
+}
+
+// UnixSocketTransport serves newline-delimited JSON requests on a Unix socket, answering each on
+// the same connection
+type UnixSocketTransport struct {
+	Path string
+
+	listener net.Listener
+	socket   os.FileInfo // the socket file this process created, removed on Close only if still there
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+}
+
+func (t *UnixSocketTransport) Name() string {
+	return "unix:" + t.Path
+}
+
+func (t *UnixSocketTransport) Start(ctx context.Context, dispatch Dispatcher) error {
+	if err := removeStaleSocket(t.Path); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("unix", t.Path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", t.Path, err)
+	}
+	// Close must not unlink the path blindly: by then it may belong to the next instance
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	t.socket, _ = os.Stat(t.Path)
+	t.listener = listener
+	t.conns = make(map[net.Conn]struct{})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+					log.Printf("%s: accept failed: %v", t.Name(), err)
+				}
+				return
+			}
+			t.mu.Lock()
+			t.conns[conn] = struct{}{}
+			t.mu.Unlock()
+			t.wg.Add(1)
+			go t.serve(conn, dispatch)
+		}
+	}()
+	return nil
+}
+
+func (t *UnixSocketTransport) serve(conn net.Conn, dispatch Dispatcher) {
+	defer t.wg.Done()
+	defer func() {
+		t.mu.Lock()
+		delete(t.conns, conn)
+		t.mu.Unlock()
+		conn.Close()
+	}()
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Printf("%s: failed to read request: %v", t.Name(), err)
+			}
+			return
+		}
+		_, response := dispatch(raw)
+		if err := encoder.Encode(response); err != nil {
+			log.Printf("%s: failed to write response: %v", t.Name(), err)
+			return
+		}
+	}
+}
+
+func (t *UnixSocketTransport) Close() error {
+	if t.listener == nil {
+		return nil
+	}
+	err := t.listener.Close()
+	t.listener = nil
+
+	// Idle clients would otherwise hold shutdown open until they disconnect
+	t.mu.Lock()
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+
+	if t.socket != nil {
+		if current, statErr := os.Stat(t.Path); statErr == nil && os.SameFile(current, t.socket) {
+			os.Remove(t.Path)
+		}
+		t.socket = nil
+	}
+	return err
+}
+
+// removeStaleSocket clears a socket left behind by a crashed process, which would make Listen
+// fail. A socket that still accepts connections belongs to a live process and is left alone.
+func removeStaleSocket(path string) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("failed to check %s: %v", path, err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale %s: %v", path, err)
+	}
+	return nil
+}
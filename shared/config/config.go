@@ -0,0 +1,288 @@
+// Package config resolves the endpoints and paths Centerfire components connect to. Every value
+// is layered, later sources overriding earlier ones:
+//
+//	built-in default < deployment.env < agent.yaml < environment variable < command-line flag
+//
+// and the effective values, with the source each came from, are printed by --print-config.
+//
+//	loader := config.NewLoader(config.Standard...)
+//	loader.RegisterFlags(flag.CommandLine)
+//	flag.Parse()
+//	cfg, err := loader.Load("agent.yaml")
+//	if cfg.PrintRequested() {
+//		cfg.Print(os.Stdout)
+//		return
+//	}
+//	rdb := redis.NewClient(&redis.Options{Addr: cfg.Get(config.RedisAddr)})
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Source identifies the layer a value came from
+type Source string
+
+const (
+	SourceDefault       Source = "default"
+	SourceDeploymentEnv Source = "deployment.env"
+	SourceAgentYAML     Source = "agent.yaml"
+	SourceEnv           Source = "env"
+	SourceFlag          Source = "flag"
+)
+
+// Setting declares one configurable value
+type Setting struct {
+	Key     string // agent.yaml key; dots address nested keys, e.g. communication.unix_socket
+	Env     string // environment variable, also read from deployment.env
+	Flag    string // command-line flag; derived from Key when empty
+	Default string
+	Usage   string
+	Secret  bool // masked by Print
+}
+
+// FlagName is the command-line flag for s: redis_addr -> redis-addr
+func (s Setting) FlagName() string {
+	if s.Flag != "" {
+		return s.Flag
+	}
+	return strings.NewReplacer("_", "-", ".", "-").Replace(s.Key)
+}
+
+// WithDefault returns a copy of s with a component-specific default
+func (s Setting) WithDefault(value string) Setting {
+	s.Default = value
+	return s
+}
+
+// WithFlag returns a copy of s under a different flag name, for tools that already had one
+func (s Setting) WithFlag(name string) Setting {
+	s.Flag = name
+	return s
+}
+
+// Shared infrastructure endpoints. Components add their own settings (listen ports and the like)
+// alongside these.
+var (
+	RedisAddr = Setting{Key: "redis_addr", Env: "REDIS_ADDR", Default: "localhost:6380",
+		Usage: "Redis address (host:port)"}
+	WeaviateURL = Setting{Key: "weaviate_url", Env: "WEAVIATE_URL", Default: "http://localhost:8080",
+		Usage: "Weaviate base URL"}
+	OllamaURL = Setting{Key: "ollama_url", Env: "OLLAMA_URL", Default: "http://localhost:11434",
+		Usage: "Ollama base URL"}
+	Neo4jURI = Setting{Key: "neo4j_uri", Env: "NEO4J_URI", Default: "bolt://localhost:7687",
+		Usage: "Neo4j bolt URI"}
+	Neo4jHTTPURL = Setting{Key: "neo4j_http_url", Env: "NEO4J_HTTP_URL", Default: "http://localhost:7474",
+		Usage: "Neo4j HTTP (browser) URL, used for health checks"}
+	Neo4jUser = Setting{Key: "neo4j_user", Env: "NEO4J_USER", Default: "neo4j",
+		Usage: "Neo4j user"}
+	Neo4jPassword = Setting{Key: "neo4j_password", Env: "NEO4J_PASSWORD", Default: "centerfire123",
+		Usage: "Neo4j password", Secret: true}
+	ClickHouseURL = Setting{Key: "clickhouse_url", Env: "CLICKHOUSE_URL", Default: "http://localhost:8123",
+		Usage: "ClickHouse HTTP URL"}
+	ManagerAddr = Setting{Key: "manager_addr", Env: "MANAGER_ADDR", Default: "localhost:8380",
+		Usage: "AGT-MANAGER-1 HTTP discovery address (host:port)"}
+	GatewayPort = Setting{Key: "gateway_port", Env: "GATEWAY_PORT", Default: "8090",
+		Usage: "first port the HTTP gateway and orchestrator try to listen on"}
+	SocketDir = Setting{Key: "socket_dir", Env: "CENTERFIRE_SOCKET_DIR", Default: "/tmp",
+		Usage: "directory for Unix sockets"}
+)
+
+// Standard lists the shared endpoints; every component resolves and prints these
+var Standard = []Setting{
+	RedisAddr, WeaviateURL, OllamaURL, Neo4jURI, Neo4jHTTPURL, Neo4jUser, Neo4jPassword,
+	ClickHouseURL, ManagerAddr, GatewayPort, SocketDir,
+}
+
+// DeploymentEnvVar names an explicit deployment.env path; otherwise the file is looked for in the
+// working directory and its parents
+const DeploymentEnvVar = "CENTERFIRE_DEPLOYMENT_ENV"
+
+// Loader collects settings and flag values, then resolves them with Load
+type Loader struct {
+	settings    []Setting
+	flagValues  map[string]*string
+	flagSet     *flag.FlagSet
+	printConfig *bool
+}
+
+// NewLoader creates a loader for settings. Settings with the same Key as an earlier one replace it,
+// so a component can pass Standard followed by its own overrides.
+func NewLoader(settings ...Setting) *Loader {
+	l := &Loader{flagValues: make(map[string]*string)}
+	for _, s := range settings {
+		l.add(s)
+	}
+	return l
+}
+
+func (l *Loader) add(s Setting) {
+	for i, existing := range l.settings {
+		if existing.Key == s.Key {
+			l.settings[i] = s
+			return
+		}
+	}
+	l.settings = append(l.settings, s)
+}
+
+// RegisterFlags adds a flag per setting, plus --print-config, to fs. Call before fs.Parse.
+func (l *Loader) RegisterFlags(fs *flag.FlagSet) {
+	l.flagSet = fs
+	for _, s := range l.settings {
+		usage := s.Usage
+		if s.Env != "" {
+			usage = fmt.Sprintf("%s (env %s)", usage, s.Env)
+		}
+		l.flagValues[s.Key] = fs.String(s.FlagName(), "", usage)
+	}
+	l.printConfig = fs.Bool("print-config", false, "print the effective configuration and its sources, then exit")
+}
+
+// value is one resolved setting
+type value struct {
+	Value  string
+	Source Source
+	Origin string // file path or variable name the value came from
+}
+
+// Config holds resolved values
+type Config struct {
+	settings []Setting
+	values   map[string]value
+	print    bool
+	args     []string
+}
+
+// Load resolves every setting. agentYAML may be empty for components without one; a missing
+// deployment.env is not an error. Flags must already be parsed.
+func (l *Loader) Load(agentYAML string) (*Config, error) {
+	c := &Config{settings: l.settings, values: make(map[string]value, len(l.settings))}
+	for _, s := range l.settings {
+		c.values[s.Key] = value{Value: s.Default, Source: SourceDefault}
+	}
+
+	if path := findDeploymentEnv(); path != "" {
+		vars, err := readEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range l.settings {
+			if v, ok := vars[s.Env]; ok && s.Env != "" {
+				c.values[s.Key] = value{Value: v, Source: SourceDeploymentEnv, Origin: path}
+			}
+		}
+	}
+
+	if agentYAML != "" {
+		doc, err := readYAML(agentYAML)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range l.settings {
+			if v, ok := lookupYAML(doc, s.Key); ok {
+				c.values[s.Key] = value{Value: v, Source: SourceAgentYAML, Origin: agentYAML}
+			}
+		}
+	}
+
+	for _, s := range l.settings {
+		if s.Env == "" {
+			continue
+		}
+		if v, ok := os.LookupEnv(s.Env); ok && v != "" {
+			c.values[s.Key] = value{Value: v, Source: SourceEnv, Origin: s.Env}
+		}
+	}
+
+	if l.flagSet != nil {
+		set := make(map[string]bool)
+		l.flagSet.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		for _, s := range l.settings {
+			if set[s.FlagName()] {
+				c.values[s.Key] = value{Value: *l.flagValues[s.Key], Source: SourceFlag, Origin: "--" + s.FlagName()}
+			}
+		}
+		c.print = *l.printConfig
+		c.args = l.flagSet.Args()
+	}
+	return c, nil
+}
+
+// Load resolves the Standard settings plus extra from args and agentYAML. It is the one-call form
+// for components without flags of their own; args are usually os.Args[1:].
+func Load(agentYAML string, args []string, extra ...Setting) (*Config, error) {
+	loader := NewLoader(append(append([]Setting(nil), Standard...), extra...)...)
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	loader.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return loader.Load(agentYAML)
+}
+
+// Get returns the effective value of s
+func (c *Config) Get(s Setting) string {
+	if v, ok := c.values[s.Key]; ok {
+		return v.Value
+	}
+	return s.Default
+}
+
+// Int returns the effective value of s as an integer
+func (c *Config) Int(s Setting) (int, error) {
+	n, err := strconv.Atoi(c.Get(s))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %q is not a number", s.Key, c.Get(s))
+	}
+	return n, nil
+}
+
+// Source returns where the value of s came from
+func (c *Config) Source(s Setting) Source {
+	if v, ok := c.values[s.Key]; ok {
+		return v.Source
+	}
+	return SourceDefault
+}
+
+// SocketPath is <socket_dir>/<name>.sock
+func (c *Config) SocketPath(name string) string {
+	return filepath.Join(c.Get(SocketDir), name+".sock")
+}
+
+// Args returns the arguments left after flags
+func (c *Config) Args() []string {
+	return c.args
+}
+
+// PrintRequested reports whether --print-config was given
+func (c *Config) PrintRequested() bool {
+	return c.print
+}
+
+// Print writes each setting's effective value and source
+func (c *Config) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range c.settings {
+		v := c.values[s.Key]
+		shown := v.Value
+		if s.Secret && shown != "" {
+			shown = "********"
+		}
+		source := string(v.Source)
+		if v.Origin != "" {
+			source = fmt.Sprintf("%s (%s)", v.Source, v.Origin)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, shown, source)
+	}
+	tw.Flush()
+}
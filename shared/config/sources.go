@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// findDeploymentEnv returns $CENTERFIRE_DEPLOYMENT_ENV, or the nearest deployment.env in the
+// working directory or its parents, or "" when there is none
+func findDeploymentEnv() string {
+	if path := os.Getenv(DeploymentEnvVar); path != "" {
+		return path
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, "deployment.env")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readEnvFile parses KEY=VALUE lines as written for `source deployment.env`. Comments, blank lines
+// and an `export ` prefix are allowed; values are taken literally apart from surrounding quotes,
+// so shell expansions are not evaluated.
+func readEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	defer f.Close()
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+			val = val[1 : len(val)-1]
+		}
+		vars[strings.TrimSpace(key)] = val
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return vars, nil
+}
+
+func readYAML(path string) (map[interface{}]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	var doc map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+	return doc, nil
+}
+
+// lookupYAML finds a dotted key such as communication.unix_socket. Empty and null values count as
+// unset so templates can leave placeholders for the defaults.
+func lookupYAML(doc map[interface{}]interface{}, key string) (string, bool) {
+	var node interface{} = doc
+	for _, part := range strings.Split(key, ".") {
+		m, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return "", false
+		}
+		if node, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+	switch v := node.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, v != ""
+	case map[interface{}]interface{}, []interface{}:
+		return "", false
+	default:
+		return fmt.Sprint(v), true
+	}
+}
@@ -0,0 +1,189 @@
+// Package messages defines the request envelope and typed action payloads agents exchange over
+// Redis pub/sub and Unix sockets.
+//
+// Every request is an Envelope carrying schema_version. Version 0 is the untyped map format agents
+// used before this package; Decode upgrades those payloads through per-action shims so old senders
+// keep working while both ends validate against the same structs.
+package messages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the envelope version produced by this package
+const SchemaVersion = 1
+
+// ErrUnsupportedVersion is returned for envelopes newer than this package understands
+var ErrUnsupportedVersion = errors.New("unsupported schema_version")
+
+// Envelope wraps every agent request
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	RequestID     string          `json:"request_id,omitempty"`
+	Action        string          `json:"action"`
+	Params        json.RawMessage `json:"params,omitempty"`
+	ReplyTo       string          `json:"reply_to,omitempty"` // channel for the response; empty means the agent's default
+	Source        string          `json:"source,omitempty"`
+	ClientID      string          `json:"client_id,omitempty"`
+}
+
+// NewRequest builds a current-version envelope, validating params when they are a known payload
+func NewRequest(action string, params interface{}) (*Envelope, error) {
+	if action == "" {
+		return nil, errors.New("action is required")
+	}
+	if payload, ok := params.(Payload); ok {
+		if err := payload.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s params: %w", action, err)
+		}
+	}
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s params: %w", action, err)
+	}
+	return &Envelope{
+		SchemaVersion: SchemaVersion,
+		RequestID:     fmt.Sprintf("req_%d", time.Now().UnixNano()),
+		Action:        action,
+		Params:        raw,
+	}, nil
+}
+
+// Marshal encodes the envelope for publishing
+func (e *Envelope) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Decode parses an envelope. Legacy (version 0) params are upgraded to the current payload shape.
+func Decode(data []byte) (*Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		// Legacy senders sometimes used numeric request IDs
+		var legacy struct {
+			Envelope
+			RequestID interface{} `json:"request_id"`
+		}
+		if json.Unmarshal(data, &legacy) != nil {
+			return nil, fmt.Errorf("invalid JSON format: %w", err)
+		}
+		env = legacy.Envelope
+		if legacy.RequestID != nil {
+			env.RequestID = fmt.Sprint(legacy.RequestID)
+		}
+	}
+	if env.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("%w: %d (supported: %d)", ErrUnsupportedVersion, env.SchemaVersion, SchemaVersion)
+	}
+	if env.Action == "" {
+		return nil, errors.New("no action specified")
+	}
+
+	if env.SchemaVersion == 0 {
+		if err := env.upgrade(data); err != nil {
+			return nil, err
+		}
+	}
+	return &env, nil
+}
+
+// envelopeFields are the top-level keys that belong to the envelope rather than to params
+var envelopeFields = map[string]bool{
+	"schema_version": true, "request_id": true, "action": true, "params": true,
+	"reply_to": true, "source": true, "client_id": true,
+}
+
+// upgrade applies the legacy shim for the action, if any, and stamps the current version. Legacy
+// senders that put arguments beside action ({"action": "get_context", "topic": ...}) have them
+// moved into params.
+func (e *Envelope) upgrade(data []byte) error {
+	if len(e.Params) == 0 {
+		var request map[string]json.RawMessage
+		if err := json.Unmarshal(data, &request); err == nil {
+			for key := range envelopeFields {
+				delete(request, key)
+			}
+			if len(request) > 0 {
+				raw, err := json.Marshal(request)
+				if err != nil {
+					return err
+				}
+				e.Params = raw
+			}
+		}
+	}
+
+	if shim, ok := legacyShims[e.Action]; ok && len(e.Params) > 0 {
+		var params map[string]interface{}
+		if err := json.Unmarshal(e.Params, &params); err != nil {
+			return fmt.Errorf("invalid params: %w", err)
+		}
+		raw, err := json.Marshal(shim(params))
+		if err != nil {
+			return err
+		}
+		e.Params = raw
+	}
+	e.SchemaVersion = SchemaVersion
+	return nil
+}
+
+// DecodeParams unmarshals params into v and validates it when v is a Payload
+func (e *Envelope) DecodeParams(v interface{}) error {
+	if len(e.Params) == 0 {
+		return errors.New("no params provided")
+	}
+	if err := json.Unmarshal(e.Params, v); err != nil {
+		return fmt.Errorf("invalid %s params: %w", e.Action, err)
+	}
+	if payload, ok := v.(Payload); ok {
+		if err := payload.Validate(); err != nil {
+			return fmt.Errorf("invalid %s params: %w", e.Action, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks params against the registered payload type for the action. Actions without a
+// registered payload are accepted as-is.
+func (e *Envelope) Validate() error {
+	factory, ok := payloadTypes[e.Action]
+	if !ok {
+		return nil
+	}
+	return e.DecodeParams(factory())
+}
+
+// ParamsMap returns params as a generic map for handlers that have not moved to typed payloads
+func (e *Envelope) ParamsMap() map[string]interface{} {
+	params := map[string]interface{}{}
+	if len(e.Params) > 0 {
+		json.Unmarshal(e.Params, &params)
+	}
+	return params
+}
+
+// AsMap returns the envelope in the map form map-based handlers expect
+func (e *Envelope) AsMap() map[string]interface{} {
+	request := map[string]interface{}{
+		"schema_version": e.SchemaVersion,
+		"action":         e.Action,
+		"params":         e.ParamsMap(),
+	}
+	if e.RequestID != "" {
+		request["request_id"] = e.RequestID
+	}
+	if e.ReplyTo != "" {
+		request["reply_to"] = e.ReplyTo
+	}
+	if e.Source != "" {
+		request["source"] = e.Source
+	}
+	if e.ClientID != "" {
+		request["client_id"] = e.ClientID
+	}
+	return request
+}
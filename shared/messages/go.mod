@@ -0,0 +1,3 @@
+module centerfire/shared/messages
+
+go 1.21
@@ -0,0 +1,35 @@
+package messages
+
+// legacyShims rewrite version 0 params into the current payload shape, per action
+var legacyShims = map[string]func(map[string]interface{}) map[string]interface{}{
+	ActionCreateStructure:    upgradeCreateStructure,
+	ActionAllocateCapability: upgradeAllocateCapability,
+}
+
+// upgradeCreateStructure maps AGT-NAMING's old slug/directory/domain/purpose delegation onto
+// name/type. Those requests only ever described capabilities.
+func upgradeCreateStructure(params map[string]interface{}) map[string]interface{} {
+	if _, ok := params["name"]; !ok {
+		if directory, ok := params["directory"].(string); ok && directory != "" {
+			params["name"] = directory
+		} else if slug, ok := params["slug"].(string); ok {
+			params["name"] = slug
+		}
+	}
+	if _, ok := params["type"]; !ok {
+		if _, fromNaming := params["slug"]; fromNaming {
+			params["type"] = "capability"
+		}
+	}
+	return params
+}
+
+// upgradeAllocateCapability accepts description as the purpose, as the naming agent always has
+func upgradeAllocateCapability(params map[string]interface{}) map[string]interface{} {
+	if _, ok := params["purpose"]; !ok {
+		if description, ok := params["description"]; ok {
+			params["purpose"] = description
+		}
+	}
+	return params
+}
@@ -0,0 +1,183 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Actions with typed payloads
+const (
+	ActionAllocateCapability  = "allocate_capability"
+	ActionAllocateModule      = "allocate_module"
+	ActionAllocateFunction    = "allocate_function"
+	ActionAllocateNamespace   = "allocate_namespace"
+	ActionReserveName         = "reserve_name"
+	ActionConfirmName         = "confirm_name"
+	ActionCreateStructure     = "create_structure"
+	ActionCreateDocumentation = "create_documentation"
+	ActionListTemplates       = "list_templates"
+	ActionValidateStructure   = "validate_structure"
+	ActionResolveCID          = "resolve_cid"
+)
+
+// Payload is a typed action params struct
+type Payload interface {
+	Validate() error
+}
+
+// payloadTypes maps actions to their params type for receiver-side validation
+var payloadTypes = map[string]func() Payload{
+	ActionAllocateCapability:  func() Payload { return &AllocateCapabilityParams{} },
+	ActionAllocateModule:      func() Payload { return &AllocateScopedParams{} },
+	ActionAllocateFunction:    func() Payload { return &AllocateScopedParams{} },
+	ActionAllocateNamespace:   func() Payload { return &AllocateNamespaceParams{} },
+	ActionConfirmName:         func() Payload { return &NameRefParams{} },
+	ActionCreateStructure:     func() Payload { return &CreateStructureParams{} },
+	ActionCreateDocumentation: func() Payload { return &CreateDocumentationParams{} },
+	ActionValidateStructure:   func() Payload { return &ValidateStructureParams{} },
+}
+
+// StructureTypes are the structure kinds AGT-STRUCT creates
+var StructureTypes = []string{"capability", "agent", "module"}
+
+// AllocateCapabilityParams requests a CAP-<DOMAIN>-<N> name
+type AllocateCapabilityParams struct {
+	Domain      string `json:"domain"`
+	Purpose     string `json:"purpose,omitempty"`
+	Description string `json:"description,omitempty"` // legacy alias of purpose
+}
+
+func (p *AllocateCapabilityParams) Validate() error {
+	if p.Domain == "" {
+		return errors.New("domain is required")
+	}
+	return nil
+}
+
+// AllocateScopedParams requests a module or function name under a capability
+type AllocateScopedParams struct {
+	Capability string `json:"capability"` // slug or CID
+	Module     string `json:"module,omitempty"`
+	Name       string `json:"name"`
+	Purpose    string `json:"purpose,omitempty"`
+}
+
+func (p *AllocateScopedParams) Validate() error {
+	if p.Capability == "" {
+		return errors.New("capability is required")
+	}
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+// AllocateNamespaceParams requests a <project>.<env>.ns<N> namespace
+type AllocateNamespaceParams struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	ClassType   string `json:"class_type,omitempty"`
+}
+
+func (p *AllocateNamespaceParams) Validate() error {
+	if p.Project == "" {
+		return errors.New("project is required")
+	}
+	if p.Environment == "" {
+		return errors.New("environment is required")
+	}
+	return nil
+}
+
+// NameRefParams identifies an allocated name by CID or slug
+type NameRefParams struct {
+	CID  string `json:"cid,omitempty"`
+	Slug string `json:"slug,omitempty"`
+	Kind string `json:"kind,omitempty"`
+}
+
+func (p *NameRefParams) Validate() error {
+	if p.CID == "" && p.Slug == "" {
+		return errors.New("cid or slug is required")
+	}
+	return nil
+}
+
+// CreateStructureParams asks AGT-STRUCT to create the directory for an allocated name
+type CreateStructureParams struct {
+	Name     string `json:"name"` // directory name, e.g. CAP-AUTH-1__01J9F7Z8
+	Type     string `json:"type"`
+	CID      string `json:"cid"`
+	Template string `json:"template,omitempty"`
+	Slug     string `json:"slug,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Purpose  string `json:"purpose,omitempty"`
+	Status   string `json:"status,omitempty"` // "reserved" asks AGT-STRUCT to confirm the name once created
+
+	Variables map[string]string `json:"variables,omitempty"` // values for the template's declared variables
+	DryRun    bool              `json:"dry_run,omitempty"`   // render and return the file tree without writing
+
+	Environment string `json:"environment,omitempty"` // selects the configured project root; defaults to dev
+	Force       bool   `json:"force,omitempty"`       // replace an existing structure directory
+}
+
+func (p *CreateStructureParams) Validate() error {
+	var problems []string
+	if p.Name == "" {
+		problems = append(problems, "name is required")
+	}
+	if p.CID == "" {
+		problems = append(problems, "cid is required")
+	}
+	if !contains(StructureTypes, p.Type) {
+		problems = append(problems, fmt.Sprintf("type must be one of %s", strings.Join(StructureTypes, ", ")))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ValidateStructureParams asks AGT-STRUCT to check an existing structure against its template
+type ValidateStructureParams struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Template    string `json:"template,omitempty"` // defaults to the template recorded in the structure
+	Environment string `json:"environment,omitempty"`
+	Repair      bool   `json:"repair,omitempty"` // restore missing files and fix repairable drift
+}
+
+func (p *ValidateStructureParams) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if !contains(StructureTypes, p.Type) {
+		return fmt.Errorf("type must be one of %s", strings.Join(StructureTypes, ", "))
+	}
+	return nil
+}
+
+// CreateDocumentationParams asks AGT-SEMDOC to document a created structure
+type CreateDocumentationParams struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	CID    string `json:"cid"`
+	Source string `json:"source,omitempty"`
+}
+
+func (p *CreateDocumentationParams) Validate() error {
+	if p.Name == "" || p.CID == "" {
+		return errors.New("name and cid are required")
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -7,9 +7,11 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/go-redis/redis/v8"
 )
 
@@ -34,10 +36,13 @@ type ConversationData struct {
 	TurnCount  int    `json:"turn_count"`
 }
 
-func NewClickHouseConsumer() *ClickHouseConsumer {
+// clickhouseURL carries the consumer's ClickHouse user
+var clickhouseURL = config.ClickHouseURL.WithDefault("http://centerfire:@localhost:8123")
+
+func NewClickHouseConsumer(endpoints *config.Config) *ClickHouseConsumer {
 	ctx := context.Background()
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
@@ -52,7 +57,7 @@ func NewClickHouseConsumer() *ClickHouseConsumer {
 		consumerGroup: "clickhouse-consumers",
 		consumerName:  fmt.Sprintf("ch-consumer-%d", time.Now().UnixNano()),
 		httpClient:    httpClient,
-		clickhouseURL: "http://centerfire:@localhost:8123",
+		clickhouseURL: endpoints.Get(clickhouseURL),
 		batchSize:     100,          // Process 100 conversations at once
 		batchTimeout:  30 * time.Second, // Max wait time before processing batch
 		isClickHouseUp: false,
@@ -340,7 +345,16 @@ func (cc *ClickHouseConsumer) requestClickHouseStartup() {
 }
 
 func main() {
-	consumer := NewClickHouseConsumer()
+	endpoints, err := config.Load("", os.Args[1:], clickhouseURL)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	consumer := NewClickHouseConsumer(endpoints)
 
 	// Create consumer group
 	if err := consumer.createConsumerGroup(); err != nil {
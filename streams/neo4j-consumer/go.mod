@@ -3,6 +3,7 @@ module centerfire/streams/neo4j-consumer
 go 1.25.1
 
 require (
+	centerfire/shared/config v0.0.0
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/gorilla/mux v1.8.1
 	github.com/neo4j/neo4j-go-driver/v5 v5.28.3
@@ -11,4 +12,7 @@ require (
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+replace centerfire/shared/config => ../../shared/config
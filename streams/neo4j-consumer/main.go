@@ -12,6 +12,7 @@ import (
 	"syscall"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
@@ -22,12 +23,12 @@ const (
 	StreamName     = "centerfire.learning.conversations"
 	ConsumerGroup  = "neo4j_consumers"
 	ConsumerName   = "neo4j_consumer_1"
-	RedisAddr      = "mem0-redis:6380"
-	Neo4jURI       = "bolt://centerfire-neo4j:7687"
-	Neo4jUsername  = "neo4j"
-	Neo4jPassword  = "centerfire123"
 )
 
+// ListenAddr is the consumer's monitoring API address
+var ListenAddr = config.Setting{Key: "listen_addr", Env: "LISTEN_ADDR", Default: ":8082",
+	Usage: "HTTP monitoring API listen address"}
+
 // ConversationEvent represents the stream event structure
 type ConversationEvent struct {
 	Timestamp    time.Time              `json:"timestamp"`
@@ -75,6 +76,8 @@ type Outcome struct {
 type Neo4jConsumer struct {
 	redisClient *redis.Client
 	neo4jDriver neo4j.DriverWithContext
+	redisAddr   string
+	neo4jURI    string
 	mu          sync.RWMutex
 	stats       ConsumerStats
 	stopChan    chan bool
@@ -89,17 +92,17 @@ type ConsumerStats struct {
 	LastProcessed       time.Time `json:"last_processed"`
 }
 
-func NewNeo4jConsumer() *Neo4jConsumer {
+func NewNeo4jConsumer(endpoints *config.Config) *Neo4jConsumer {
 	// Initialize Redis client
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     RedisAddr,
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
 
 	// Initialize Neo4j driver
-	driver, err := neo4j.NewDriverWithContext(Neo4jURI, 
-		neo4j.BasicAuth(Neo4jUsername, Neo4jPassword, ""))
+	driver, err := neo4j.NewDriverWithContext(endpoints.Get(config.Neo4jURI),
+		neo4j.BasicAuth(endpoints.Get(config.Neo4jUser), endpoints.Get(config.Neo4jPassword), ""))
 	if err != nil {
 		log.Fatalf("Failed to create Neo4j driver: %v", err)
 	}
@@ -107,6 +110,8 @@ func NewNeo4jConsumer() *Neo4jConsumer {
 	return &Neo4jConsumer{
 		redisClient: rdb,
 		neo4jDriver: driver,
+		redisAddr:   endpoints.Get(config.RedisAddr),
+		neo4jURI:    endpoints.Get(config.Neo4jURI),
 		stats: ConsumerStats{
 			StartTime: time.Now(),
 		},
@@ -118,16 +123,16 @@ func (nc *Neo4jConsumer) Connect(ctx context.Context) error {
 	// Test Redis connection
 	_, err := nc.redisClient.Ping(ctx).Result()
 	if err != nil {
-		return fmt.Errorf("failed to connect to Redis at %s: %v", RedisAddr, err)
+		return fmt.Errorf("failed to connect to Redis at %s: %v", nc.redisAddr, err)
 	}
 
 	// Test Neo4j connection
 	err = nc.neo4jDriver.VerifyConnectivity(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to connect to Neo4j at %s: %v", Neo4jURI, err)
+		return fmt.Errorf("failed to connect to Neo4j at %s: %v", nc.neo4jURI, err)
 	}
 
-	log.Printf("Connected to Redis at %s and Neo4j at %s", RedisAddr, Neo4jURI)
+	log.Printf("Connected to Redis at %s and Neo4j at %s", nc.redisAddr, nc.neo4jURI)
 	return nil
 }
 
@@ -572,9 +577,18 @@ func (nc *Neo4jConsumer) handleHealthCheck(w http.ResponseWriter, r *http.Reques
 }
 
 func main() {
+	endpoints, err := config.Load("", os.Args[1:], ListenAddr)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
 	log.Println("Starting Centerfire Learning Neo4j Consumer...")
 
-	consumer := NewNeo4jConsumer()
+	consumer := NewNeo4jConsumer(endpoints)
 
 	// Connect to services
 	ctx := context.Background()
@@ -593,13 +607,13 @@ func main() {
 	router.HandleFunc("/health", consumer.handleHealthCheck).Methods("GET")
 
 	server := &http.Server{
-		Addr:    ":8082",
+		Addr:    endpoints.Get(ListenAddr),
 		Handler: router,
 	}
 
 	// Start HTTP server in goroutine
 	go func() {
-		log.Println("Neo4j consumer HTTP API listening on :8082")
+		log.Printf("Neo4j consumer HTTP API listening on %s", server.Addr)
 		log.Println("Endpoints:")
 		log.Println("  GET /stats  - Get consumer statistics")
 		log.Println("  GET /health - Health check")
@@ -12,6 +12,7 @@ import (
 	"syscall"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
 )
@@ -19,9 +20,12 @@ import (
 const (
 	// Semantic namespace for learning streams
 	StreamName = "centerfire.learning.conversations"
-	RedisAddr  = "mem0-redis:6380"
 )
 
+// ListenAddr is the producer's HTTP API address
+var ListenAddr = config.Setting{Key: "listen_addr", Env: "LISTEN_ADDR", Default: ":8080",
+	Usage: "HTTP API listen address"}
+
 // ConversationEvent represents a structured conversation log entry
 type ConversationEvent struct {
 	Timestamp    time.Time              `json:"timestamp"`
@@ -67,7 +71,8 @@ type Outcome struct {
 
 // StreamProducer handles Redis stream operations
 type StreamProducer struct {
-	client *redis.Client
+	client    *redis.Client
+	redisAddr string
 	mu     sync.RWMutex
 	stats  ProducerStats
 }
@@ -78,15 +83,16 @@ type ProducerStats struct {
 	StartTime       time.Time `json:"start_time"`
 }
 
-func NewStreamProducer() *StreamProducer {
+func NewStreamProducer(redisAddr string) *StreamProducer {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     RedisAddr,
+		Addr:     redisAddr,
 		Password: "",
 		DB:       0,
 	})
 
 	return &StreamProducer{
-		client: rdb,
+		client:    rdb,
+		redisAddr: redisAddr,
 		stats: ProducerStats{
 			StartTime: time.Now(),
 		},
@@ -97,10 +103,10 @@ func (sp *StreamProducer) Connect(ctx context.Context) error {
 	// Test connection
 	_, err := sp.client.Ping(ctx).Result()
 	if err != nil {
-		return fmt.Errorf("failed to connect to Redis at %s: %v", RedisAddr, err)
+		return fmt.Errorf("failed to connect to Redis at %s: %v", sp.redisAddr, err)
 	}
 
-	log.Printf("Connected to Redis at %s", RedisAddr)
+	log.Printf("Connected to Redis at %s", sp.redisAddr)
 	return nil
 }
 
@@ -264,9 +270,18 @@ func (sp *StreamProducer) generateExampleEvent() ConversationEvent {
 }
 
 func main() {
+	endpoints, err := config.Load("", os.Args[1:], ListenAddr)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
 	log.Println("Starting Centerfire Learning Stream Producer...")
 
-	producer := NewStreamProducer()
+	producer := NewStreamProducer(endpoints.Get(config.RedisAddr))
 
 	// Connect to Redis
 	ctx := context.Background()
@@ -299,13 +314,13 @@ func main() {
 	}).Methods("POST")
 
 	server := &http.Server{
-		Addr:    ":8080",
+		Addr:    endpoints.Get(ListenAddr),
 		Handler: router,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Println("Stream producer HTTP API listening on :8080")
+		log.Printf("Stream producer HTTP API listening on %s", server.Addr)
 		log.Println("Endpoints:")
 		log.Println("  POST /publish - Publish conversation event")
 		log.Println("  GET  /stats   - Get producer statistics")
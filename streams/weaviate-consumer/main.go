@@ -6,12 +6,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
@@ -25,13 +27,15 @@ const (
 	StreamName    = "centerfire.learning.conversations"
 	ConsumerGroup = "weaviate_consumers"
 	ConsumerName  = "weaviate_consumer_1"
-	RedisAddr     = "mem0-redis:6380"
-	WeaviateURL   = "http://centerfire-weaviate:8080"
 	
 	// Weaviate class name following semantic namespace
 	WeaviateClass = "Centerfire_Learning_Conversation"
 )
 
+// ListenAddr is the consumer's monitoring API address
+var ListenAddr = config.Setting{Key: "listen_addr", Env: "LISTEN_ADDR", Default: ":8081",
+	Usage: "HTTP monitoring API listen address"}
+
 // ConversationEvent represents the stream event structure
 type ConversationEvent struct {
 	Timestamp    time.Time              `json:"timestamp"`
@@ -79,6 +83,8 @@ type Outcome struct {
 type WeaviateConsumer struct {
 	redisClient   *redis.Client
 	weaviateClient *weaviate.Client
+	redisAddr    string
+	weaviateURL  string
 	mu           sync.RWMutex
 	stats        ConsumerStats
 	stopChan     chan bool
@@ -92,18 +98,22 @@ type ConsumerStats struct {
 	LastProcessed   time.Time `json:"last_processed"`
 }
 
-func NewWeaviateConsumer() *WeaviateConsumer {
+func NewWeaviateConsumer(endpoints *config.Config) *WeaviateConsumer {
 	// Initialize Redis client
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     RedisAddr,
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
 
 	// Initialize Weaviate client
+	weaviateURL, err := url.Parse(endpoints.Get(config.WeaviateURL))
+	if err != nil {
+		log.Fatalf("Invalid Weaviate URL: %v", err)
+	}
 	cfg := weaviate.Config{
-		Host:   "centerfire-weaviate:8080",
-		Scheme: "http",
+		Host:   weaviateURL.Host,
+		Scheme: weaviateURL.Scheme,
 	}
 	
 	weaviateClient, err := weaviate.NewClient(cfg)
@@ -114,6 +124,8 @@ func NewWeaviateConsumer() *WeaviateConsumer {
 	return &WeaviateConsumer{
 		redisClient:    rdb,
 		weaviateClient: weaviateClient,
+		redisAddr:      endpoints.Get(config.RedisAddr),
+		weaviateURL:    weaviateURL.String(),
 		stats: ConsumerStats{
 			StartTime: time.Now(),
 		},
@@ -125,19 +137,19 @@ func (wc *WeaviateConsumer) Connect(ctx context.Context) error {
 	// Test Redis connection
 	_, err := wc.redisClient.Ping(ctx).Result()
 	if err != nil {
-		return fmt.Errorf("failed to connect to Redis at %s: %v", RedisAddr, err)
+		return fmt.Errorf("failed to connect to Redis at %s: %v", wc.redisAddr, err)
 	}
 
 	// Test Weaviate connection
 	ready, err := wc.weaviateClient.Misc().ReadyChecker().Do(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to connect to Weaviate at %s: %v", WeaviateURL, err)
+		return fmt.Errorf("failed to connect to Weaviate at %s: %v", wc.weaviateURL, err)
 	}
 	if !ready {
-		return fmt.Errorf("Weaviate at %s is not ready", WeaviateURL)
+		return fmt.Errorf("Weaviate at %s is not ready", wc.weaviateURL)
 	}
 
-	log.Printf("Connected to Redis at %s and Weaviate at %s", RedisAddr, WeaviateURL)
+	log.Printf("Connected to Redis at %s and Weaviate at %s", wc.redisAddr, wc.weaviateURL)
 	return nil
 }
 
@@ -513,9 +525,18 @@ func (wc *WeaviateConsumer) handleHealthCheck(w http.ResponseWriter, r *http.Req
 }
 
 func main() {
+	endpoints, err := config.Load("", os.Args[1:], ListenAddr)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
 	log.Println("Starting Centerfire Learning Weaviate Consumer...")
 
-	consumer := NewWeaviateConsumer()
+	consumer := NewWeaviateConsumer(endpoints)
 
 	// Connect to services
 	ctx := context.Background()
@@ -534,13 +555,13 @@ func main() {
 	router.HandleFunc("/health", consumer.handleHealthCheck).Methods("GET")
 
 	server := &http.Server{
-		Addr:    ":8081",
+		Addr:    endpoints.Get(ListenAddr),
 		Handler: router,
 	}
 
 	// Start HTTP server in goroutine
 	go func() {
-		log.Println("Weaviate consumer HTTP API listening on :8081")
+		log.Printf("Weaviate consumer HTTP API listening on %s", server.Addr)
 		log.Println("Endpoints:")
 		log.Println("  GET /stats  - Get consumer statistics")
 		log.Println("  GET /health - Health check")
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -26,9 +28,9 @@ type SemanticNameEvent struct {
 	EventType string `json:"event_type"`
 }
 
-func NewBackfillUtility() *BackfillUtility {
+func NewBackfillUtility(redisAddr string) *BackfillUtility {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     redisAddr,
 		Password: "",
 		DB:       0,
 	})
@@ -145,7 +147,16 @@ func getIntValue(data map[string]interface{}, key string) int64 {
 }
 
 func main() {
-	utility := NewBackfillUtility()
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	utility := NewBackfillUtility(endpoints.Get(config.RedisAddr))
 	if err := utility.BackfillSemanticNames(); err != nil {
 		log.Fatalf("Backfill failed: %v", err)
 	}
@@ -0,0 +1,17 @@
+module centerfire/utils/cid-audit
+
+go 1.25.1
+
+require (
+	centerfire/shared/config v0.0.0
+	github.com/oklog/ulid/v2 v2.1.1
+	github.com/redis/go-redis/v9 v9.13.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
+
+replace centerfire/shared/config => ../../shared/config
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"centerfire/shared/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Keys shared with AGT-NAMING-2
+const (
+	cidGuardPrefix      = "centerfire.cids:"             // each CID maps to the name record that owns it
+	historyKeyPrefix    = "centerfire.dev.history:"      // list of transitions per CID
+	nameIndexVersionKey = "centerfire.dev.index:version" // deleting it makes the agent rebuild its indexes
+	namingChannel       = "agent.naming.request"
+)
+
+// namePatterns are the Redis keys where the naming agent stores allocations carrying a CID
+var namePatterns = []string{
+	"centerfire.dev.names:*",
+	"centerfire.*.namespaces:*",
+}
+
+type CIDAudit struct {
+	redisClient *redis.Client
+	ctx         context.Context
+	root        string // tree searched for .id files holding reassigned CIDs
+}
+
+// allocation is one stored name record
+type allocation struct {
+	Key       string
+	CID       string
+	Allocated string
+	Data      map[string]interface{}
+}
+
+func NewCIDAudit(addr, root string) *CIDAudit {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: "",
+		DB:       0,
+	})
+
+	return &CIDAudit{
+		redisClient: rdb,
+		ctx:         context.Background(),
+		root:        root,
+	}
+}
+
+// loadAllocations reads every name record and groups them by CID
+func (c *CIDAudit) loadAllocations() (map[string][]allocation, int, error) {
+	byCID := make(map[string][]allocation)
+	total := 0
+
+	for _, pattern := range namePatterns {
+		iter := c.redisClient.Scan(c.ctx, 0, pattern, 500).Iterator()
+		for iter.Next(c.ctx) {
+			key := iter.Val()
+			data, err := c.redisClient.Get(c.ctx, key).Result()
+			if err != nil {
+				log.Printf("Error getting data for key %s: %v", key, err)
+				continue
+			}
+
+			var nameData map[string]interface{}
+			if err := json.Unmarshal([]byte(data), &nameData); err != nil {
+				log.Printf("Error parsing JSON for key %s: %v", key, err)
+				continue
+			}
+
+			cid := getStringValue(nameData, "cid")
+			if cid == "" {
+				continue
+			}
+			total++
+			byCID[cid] = append(byCID[cid], allocation{
+				Key:       key,
+				CID:       cid,
+				Allocated: getStringValue(nameData, "allocated"),
+				Data:      nameData,
+			})
+		}
+		if err := iter.Err(); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan %s: %v", pattern, err)
+		}
+	}
+
+	return byCID, total, nil
+}
+
+// Run reports duplicate CIDs; with claim it seeds guard keys, with fix it re-mints duplicates
+func (c *CIDAudit) Run(claim, fix bool) (int, error) {
+	if _, err := c.redisClient.Ping(c.ctx).Result(); err != nil {
+		return 0, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	byCID, total, err := c.loadAllocations()
+	if err != nil {
+		return 0, err
+	}
+	fmt.Printf("Scanned %d allocations with %d distinct CIDs\n", total, len(byCID))
+
+	cids := make([]string, 0, len(byCID))
+	for cid := range byCID {
+		cids = append(cids, cid)
+	}
+	sort.Strings(cids)
+
+	duplicates, reassigned := 0, 0
+	idFiles := c.findIDFiles()
+	for _, cid := range cids {
+		owners := byCID[cid]
+		// Earliest allocation keeps the CID
+		sort.Slice(owners, func(i, j int) bool { return owners[i].Allocated < owners[j].Allocated })
+
+		if claim || fix {
+			c.claimGuard(cid, owners[0].Key)
+		}
+		if len(owners) == 1 {
+			continue
+		}
+
+		duplicates++
+		fmt.Printf("\nDUPLICATE %s shared by %d allocations:\n", cid, len(owners))
+		for i, owner := range owners {
+			marker := "  keep "
+			if i > 0 {
+				marker = "  clash"
+			}
+			fmt.Printf("%s %s (allocated %s)\n", marker, owner.Key, owner.Allocated)
+		}
+
+		if !fix {
+			continue
+		}
+		for _, owner := range owners[1:] {
+			newCID, err := c.reassign(owner, owners[0])
+			if err != nil {
+				log.Printf("Error reassigning %s: %v", owner.Key, err)
+				continue
+			}
+			reassigned++
+			fmt.Printf("  fixed %s -> %s\n", owner.Key, newCID)
+			reportIDFiles(idFiles[cid], getStringValue(owner.Data, "slug"), newCID)
+		}
+	}
+
+	if reassigned > 0 {
+		c.requestReindex()
+	}
+	fmt.Printf("\nAudit complete: %d duplicate CIDs\n", duplicates)
+	return duplicates, nil
+}
+
+// claimGuard records the owner of an existing CID unless another owner already holds it
+func (c *CIDAudit) claimGuard(cid, owner string) {
+	claimed, err := c.redisClient.SetNX(c.ctx, cidGuardPrefix+cid, owner, 0).Result()
+	if err != nil {
+		log.Printf("Error claiming guard for %s: %v", cid, err)
+		return
+	}
+	if !claimed {
+		if holder, _ := c.redisClient.Get(c.ctx, cidGuardPrefix+cid).Result(); holder != owner {
+			fmt.Printf("NOTE guard for %s is held by %s, not %s\n", cid, holder, owner)
+		}
+	}
+}
+
+// reassign gives a clashing allocation a fresh ULID-based CID, keeping the old one for reference.
+// The old CID's guard stays with keeper, and the history entries of owner's slugs move to the new
+// CID. Directory names and .id files on disk are left alone; Run reports the .id files to rewrite.
+func (c *CIDAudit) reassign(owner, keeper allocation) (string, error) {
+	prefix := owner.CID[:strings.LastIndex(owner.CID, ":")+1]
+
+	var newCID string
+	for attempt := 0; attempt < 3 && newCID == ""; attempt++ {
+		candidate := prefix + ulid.Make().String()
+		claimed, err := c.redisClient.SetNX(c.ctx, cidGuardPrefix+candidate, owner.Key, 0).Result()
+		if err != nil {
+			return "", err
+		}
+		if claimed {
+			newCID = candidate
+		}
+	}
+	if newCID == "" {
+		return "", fmt.Errorf("could not mint a unique CID")
+	}
+
+	history, err := c.redisClient.LRange(c.ctx, historyKeyPrefix+owner.CID, 0, -1).Result()
+	if err != nil {
+		c.redisClient.Del(c.ctx, cidGuardPrefix+newCID)
+		return "", err
+	}
+	kept, moved := splitHistory(history, ownerSlugs(owner.Data))
+
+	owner.Data["cid"] = newCID
+	owner.Data["previous_cid"] = owner.CID
+	owner.Data["cid_reassigned"] = time.Now().Format(time.RFC3339)
+	nameJSON, _ := json.Marshal(owner.Data)
+	entryJSON, _ := json.Marshal(map[string]interface{}{
+		"action":    "cid_reassigned",
+		"slug":      getStringValue(owner.Data, "slug"),
+		"status":    getStringValue(owner.Data, "status"),
+		"timestamp": owner.Data["cid_reassigned"],
+		"source":    "cid-audit",
+		"details":   map[string]interface{}{"previous_cid": owner.CID},
+	})
+
+	// The record, both guards and both histories change together
+	pipe := c.redisClient.TxPipeline()
+	pipe.Set(c.ctx, owner.Key, nameJSON, redis.KeepTTL)
+	if holder, _ := c.redisClient.Get(c.ctx, cidGuardPrefix+owner.CID).Result(); holder == owner.Key {
+		pipe.Set(c.ctx, cidGuardPrefix+owner.CID, keeper.Key, 0)
+	}
+	if len(moved) > 0 {
+		pipe.Del(c.ctx, historyKeyPrefix+owner.CID)
+		if len(kept) > 0 {
+			pipe.RPush(c.ctx, historyKeyPrefix+owner.CID, kept...)
+		}
+		pipe.RPush(c.ctx, historyKeyPrefix+newCID, moved...)
+	}
+	pipe.RPush(c.ctx, historyKeyPrefix+newCID, entryJSON)
+	if _, err := pipe.Exec(c.ctx); err != nil {
+		c.redisClient.Del(c.ctx, cidGuardPrefix+newCID)
+		return "", err
+	}
+
+	eventJSON, _ := json.Marshal(map[string]interface{}{
+		"key":          owner.Key,
+		"slug":         getStringValue(owner.Data, "slug"),
+		"cid":          newCID,
+		"previous_cid": owner.CID,
+		"event_type":   "cid_reassigned",
+	})
+	c.redisClient.XAdd(c.ctx, &redis.XAddArgs{
+		Stream: "centerfire:semantic:names",
+		Values: map[string]interface{}{
+			"data":      string(eventJSON),
+			"timestamp": time.Now().Unix(),
+			"source":    "cid-audit",
+		},
+	})
+
+	return newCID, nil
+}
+
+// ownerSlugs is a record's slug and the aliases earlier renames left behind
+func ownerSlugs(data map[string]interface{}) map[string]bool {
+	slugs := map[string]bool{getStringValue(data, "slug"): true}
+	if aliases, ok := data["aliases"].([]interface{}); ok {
+		for _, alias := range aliases {
+			if slug, ok := alias.(string); ok {
+				slugs[slug] = true
+			}
+		}
+	}
+	return slugs
+}
+
+// splitHistory separates the history entries recorded under one of slugs from the rest
+func splitHistory(history []string, slugs map[string]bool) (kept, moved []interface{}) {
+	for _, raw := range history {
+		var entry map[string]interface{}
+		if json.Unmarshal([]byte(raw), &entry) == nil && slugs[getStringValue(entry, "slug")] {
+			moved = append(moved, raw)
+		} else {
+			kept = append(kept, raw)
+		}
+	}
+	return kept, moved
+}
+
+// requestReindex has AGT-NAMING-2 rebuild its CID-keyed indexes, which still merge the entries
+// of the records that shared a CID. Without the version key a restarted agent rebuilds too.
+func (c *CIDAudit) requestReindex() {
+	c.redisClient.Del(c.ctx, nameIndexVersionKey)
+	request, _ := json.Marshal(map[string]interface{}{
+		"action":     "reindex_names",
+		"source":     "cid-audit",
+		"request_id": ulid.Make().String(),
+	})
+	receivers, err := c.redisClient.Publish(c.ctx, namingChannel, request).Result()
+	switch {
+	case err != nil:
+		log.Printf("Error requesting reindex: %v", err)
+	case receivers == 0:
+		fmt.Println("\nNOTE AGT-NAMING-2 is not running; it rebuilds its indexes on next start")
+	default:
+		fmt.Println("\nRequested an index rebuild from AGT-NAMING-2")
+	}
+}
+
+// findIDFiles maps each CID found in a .id file under the root to the files holding it
+func (c *CIDAudit) findIDFiles() map[string][]string {
+	files := make(map[string][]string)
+	if c.root == "" {
+		return files
+	}
+	filepath.WalkDir(c.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && (d.Name() == ".git" || d.Name() == "node_modules") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || d.Name() != ".id" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		cid := strings.TrimSpace(string(data))
+		files[cid] = append(files[cid], path)
+		return nil
+	})
+	return files
+}
+
+// reportIDFiles lists the .id files still holding a reassigned CID. A directory named after the
+// reassigned slug certainly needs newCID; any other one may belong to the record that kept the CID.
+func reportIDFiles(paths []string, slug, newCID string) {
+	for _, path := range paths {
+		dir := filepath.Base(filepath.Dir(path))
+		if slug != "" && (dir == slug || strings.HasPrefix(dir, slug+"__")) {
+			fmt.Printf("  REWRITE %s with %s\n", path, newCID)
+		} else {
+			fmt.Printf("  CHECK   %s holds the old CID; rewrite it with %s if it belongs to %s\n", path, newCID, slug)
+		}
+	}
+}
+
+func getStringValue(data map[string]interface{}, key string) string {
+	if val, ok := data[key].(string); ok {
+		return val
+	}
+	return ""
+}
+
+// redisAddr keeps the tool's original -redis flag
+var redisAddr = config.RedisAddr.WithFlag("redis")
+
+func main() {
+	loader := config.NewLoader(redisAddr)
+	loader.RegisterFlags(flag.CommandLine)
+	claim := flag.Bool("claim", false, "seed centerfire.cids guard keys for existing CIDs")
+	fix := flag.Bool("fix", false, "re-mint CIDs for all but the earliest allocation of each duplicate (implies -claim)")
+	root := flag.String("root", ".", "tree searched for .id files that still hold reassigned CIDs")
+	flag.Parse()
+
+	endpoints, err := loader.Load("")
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	audit := NewCIDAudit(endpoints.Get(redisAddr), *root)
+	duplicates, err := audit.Run(*claim, *fix)
+	if err != nil {
+		log.Fatalf("CID audit failed: %v", err)
+	}
+	if duplicates > 0 && !*fix {
+		os.Exit(1)
+	}
+}
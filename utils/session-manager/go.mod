@@ -3,6 +3,7 @@ module centerfire/session-manager
 go 1.25.1
 
 require (
+	centerfire/shared/config v0.0.0
 	github.com/gorilla/mux v1.8.1
 	github.com/redis/go-redis/v9 v9.13.0
 )
@@ -10,4 +11,7 @@ require (
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+replace centerfire/shared/config => ../../shared/config
@@ -12,6 +12,7 @@ import (
 	"syscall"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/gorilla/mux"
 	"github.com/redis/go-redis/v9"
 )
@@ -36,19 +37,25 @@ type NamingRequest struct {
 	Params map[string]interface{} `json:"params"`
 }
 
+// ListenAddr is the HTTP API address
+var ListenAddr = config.Setting{Key: "listen_addr", Env: "LISTEN_ADDR", Default: ":8083",
+	Usage: "HTTP API listen address"}
+
 // SessionManager manages Redis-based sessions
 type SessionManager struct {
 	redisClient   *redis.Client
 	ctx           context.Context
 	httpServer    *http.Server
+	listenAddr    string
+	args          []string // CLI command and its arguments
 	requestID     int
 }
 
 // NewSessionManager creates a new session manager
-func NewSessionManager() *SessionManager {
-	// Connect to Redis mem0-redis:6380
+func NewSessionManager(endpoints *config.Config) *SessionManager {
+	// Connect to Redis (mem0-redis, published on 6380 unless configured otherwise)
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380", // mem0-redis container port
+		Addr:     endpoints.Get(config.RedisAddr),
 		Password: "",
 		DB:       0,
 	})
@@ -56,6 +63,8 @@ func NewSessionManager() *SessionManager {
 	return &SessionManager{
 		redisClient: rdb,
 		ctx:         context.Background(),
+		listenAddr:  endpoints.Get(ListenAddr),
+		args:        endpoints.Args(),
 		requestID:   0,
 	}
 }
@@ -412,11 +421,11 @@ func (sm *SessionManager) startHTTPServer() {
 	router.HandleFunc("/sessions", sm.listSessionsHandler).Methods("GET")
 
 	sm.httpServer = &http.Server{
-		Addr:    ":8083",
+		Addr:    sm.listenAddr,
 		Handler: router,
 	}
 
-	fmt.Println("HTTP API server starting on :8083")
+	fmt.Printf("HTTP API server starting on %s\n", sm.listenAddr)
 	if err := sm.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("HTTP server failed: %v", err)
 	}
@@ -425,12 +434,12 @@ func (sm *SessionManager) startHTTPServer() {
 // CLI Interface Functions
 
 func (sm *SessionManager) handleCLI() {
-	if len(os.Args) < 2 {
+	if len(sm.args) < 1 {
 		sm.printUsage()
 		return
 	}
 
-	command := os.Args[1]
+	command := sm.args[0]
 	switch command {
 	case "create":
 		sm.handleCreateCLI()
@@ -465,7 +474,7 @@ func (sm *SessionManager) handleCreateCLI() {
 	context := "CLI session"
 
 	// Parse CLI flags
-	for _, arg := range os.Args[2:] {
+	for _, arg := range sm.args[1:] {
 		if strings.HasPrefix(arg, "--type=") {
 			sessionType = strings.TrimPrefix(arg, "--type=")
 		} else if strings.HasPrefix(arg, "--context=") {
@@ -490,7 +499,7 @@ func (sm *SessionManager) handleUpdateCLI() {
 	var sessionID, progressItem string
 
 	// Parse CLI flags
-	for _, arg := range os.Args[2:] {
+	for _, arg := range sm.args[1:] {
 		if strings.HasPrefix(arg, "--id=") {
 			sessionID = strings.TrimPrefix(arg, "--id=")
 		} else if strings.HasPrefix(arg, "--progress=") {
@@ -524,7 +533,7 @@ func (sm *SessionManager) handleGetCLI() {
 	var sessionID string
 
 	// Parse CLI flags
-	for _, arg := range os.Args[2:] {
+	for _, arg := range sm.args[1:] {
 		if strings.HasPrefix(arg, "--id=") {
 			sessionID = strings.TrimPrefix(arg, "--id=")
 		}
@@ -562,7 +571,7 @@ func (sm *SessionManager) handleExpireCLI() {
 	var sessionID, ttlStr string
 
 	// Parse CLI flags
-	for _, arg := range os.Args[2:] {
+	for _, arg := range sm.args[1:] {
 		if strings.HasPrefix(arg, "--id=") {
 			sessionID = strings.TrimPrefix(arg, "--id=")
 		} else if strings.HasPrefix(arg, "--ttl=") {
@@ -595,14 +604,23 @@ func (sm *SessionManager) handleExpireCLI() {
 }
 
 func main() {
-	sm := NewSessionManager()
+	endpoints, err := config.Load("", os.Args[1:], ListenAddr)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	sm := NewSessionManager(endpoints)
 	
 	if err := sm.Start(); err != nil {
 		log.Fatalf("Failed to start session manager: %v", err)
 	}
 
 	// Handle CLI commands or start server
-	if len(os.Args) > 1 {
+	if len(sm.args) > 0 {
 		sm.handleCLI()
 	} else {
 		// Start HTTP server by default
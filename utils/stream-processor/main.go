@@ -10,6 +10,7 @@ import (
 	"syscall"
 	"time"
 
+	"centerfire/shared/config"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -52,9 +53,9 @@ type SemanticNamespaceEvent struct {
 	Allocated   string `json:"allocated"`
 }
 
-func NewStreamProcessor() *StreamProcessor {
+func NewStreamProcessor(redisAddr string) *StreamProcessor {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6380",
+		Addr:     redisAddr,
 		Password: "",
 		DB:       0,
 	})
@@ -330,9 +331,18 @@ func (sp *StreamProcessor) PublishSemanticNameEvent(event SemanticNameEvent) err
 }
 
 func main() {
-	if len(os.Args) > 1 && os.Args[1] == "publish-test" {
+	endpoints, err := config.Load("", os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if endpoints.PrintRequested() {
+		endpoints.Print(os.Stdout)
+		return
+	}
+
+	if args := endpoints.Args(); len(args) > 0 && args[0] == "publish-test" {
 		// Test publishing an event
-		sp := NewStreamProcessor()
+		sp := NewStreamProcessor(endpoints.Get(config.RedisAddr))
 		event := SemanticNameEvent{
 			Slug:      "CAP-TEST-2",
 			CID:       "cid:centerfire:capability:test123",
@@ -351,6 +361,6 @@ func main() {
 		return
 	}
 
-	processor := NewStreamProcessor()
+	processor := NewStreamProcessor(endpoints.Get(config.RedisAddr))
 	processor.Start()
 }
\ No newline at end of file